@@ -0,0 +1,425 @@
+// Copyright 2026 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/kubeflow/kubeflow/components/access-management/kfam"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// The Profiles are exported with the newest API version, as unstructured
+// objects, so that the bundle keeps the fields this module doesn't know.
+var profileResource = schema.GroupVersionResource{Group: "kubeflow.org", Version: "v1", Resource: "profiles"}
+
+var namespaceResource = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+// bundleResource is a kind of object of the profile namespaces written to the
+// bundle. The objects the profile controller manages are recreated from the
+// Profile, so they aren't exported, except the annotations of its service
+// accounts.
+type bundleResource struct {
+	resource schema.GroupVersionResource
+	kind     string
+	// kfamOnly exports only the objects KFAM created
+	kfamOnly bool
+	// annotationsOnly exports only the annotations of the objects the
+	// profile controller manages
+	annotationsOnly bool
+}
+
+var bundleResources = []bundleResource{
+	{
+		resource: schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "rolebindings"},
+		kind:     "RoleBinding",
+		kfamOnly: true,
+	},
+	{
+		resource: schema.GroupVersionResource{Group: "security.istio.io", Version: "v1beta1", Resource: "authorizationpolicies"},
+		kind:     "AuthorizationPolicy",
+		kfamOnly: true,
+	},
+	{
+		resource: schema.GroupVersionResource{Version: "v1", Resource: "resourcequotas"},
+		kind:     "ResourceQuota",
+	},
+	{
+		resource: schema.GroupVersionResource{Group: "kubeflow.org", Version: "v1alpha1", Resource: "poddefaults"},
+		kind:     "PodDefault",
+	},
+	{
+		resource:        schema.GroupVersionResource{Version: "v1", Resource: "serviceaccounts"},
+		kind:            "ServiceAccount",
+		annotationsOnly: true,
+	},
+}
+
+// getBundleResource returns the bundle resource of the kind of the object.
+func getBundleResource(obj *unstructured.Unstructured) (bundleResource, bool) {
+	for _, r := range bundleResources {
+		if r.kind == obj.GetKind() && r.resource.GroupVersion().String() == obj.GetAPIVersion() {
+			return r, true
+		}
+	}
+	return bundleResource{}, false
+}
+
+// isManagedByProfile returns whether the profile controller manages the
+// object, which is then controlled by the Profile.
+func isManagedByProfile(obj *unstructured.Unstructured) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind == "Profile" && ref.Controller != nil && *ref.Controller {
+			return true
+		}
+	}
+	return false
+}
+
+// exportedObject returns the object as it is written to the bundle, nil if it
+// isn't exported: without its status and the metadata of the cluster.
+func exportedObject(r bundleResource, obj *unstructured.Unstructured) *unstructured.Unstructured {
+	managed := isManagedByProfile(obj)
+	if r.annotationsOnly {
+		if !managed || len(obj.GetAnnotations()) == 0 {
+			return nil
+		}
+		exported := &unstructured.Unstructured{}
+		exported.SetAPIVersion(obj.GetAPIVersion())
+		exported.SetKind(obj.GetKind())
+		exported.SetNamespace(obj.GetNamespace())
+		exported.SetName(obj.GetName())
+		exported.SetAnnotations(obj.GetAnnotations())
+		return exported
+	}
+	if managed {
+		return nil
+	}
+	if _, ok := obj.GetAnnotations()[kfam.USER]; r.kfamOnly && !ok {
+		return nil
+	}
+	exported := obj.DeepCopy()
+	delete(exported.Object, "status")
+	for _, field := range []string{"uid", "resourceVersion", "generation", "creationTimestamp", "selfLink",
+		"managedFields", "ownerReferences", "finalizers"} {
+		unstructured.RemoveNestedField(exported.Object, "metadata", field)
+	}
+	return exported
+}
+
+// exportProfile returns the Profile and the objects of its namespace to write
+// to the bundle.
+func exportProfile(client dynamic.Interface, name string) ([]unstructured.Unstructured, error) {
+	profile, err := client.Resource(profileResource).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	objects := []unstructured.Unstructured{*exportedObject(bundleResource{}, profile)}
+	for _, r := range bundleResources {
+		list, err := client.Resource(r.resource).Namespace(name).List(metav1.ListOptions{})
+		if apierrors.IsNotFound(err) {
+			// The kind isn't installed, e.g. PodDefaults without the admission webhook.
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing %s of profile %s: %v", r.resource.Resource, name, err)
+		}
+		for i := range list.Items {
+			list.Items[i].SetAPIVersion(r.resource.GroupVersion().String())
+			list.Items[i].SetKind(r.kind)
+			if exported := exportedObject(r, &list.Items[i]); exported != nil {
+				objects = append(objects, *exported)
+			}
+		}
+	}
+	return objects, nil
+}
+
+// exportProfiles writes the bundle of the Profiles, of all of them if names is
+// empty, to out. The bundle is a v1 List.
+func exportProfiles(names []string, out io.Writer) error {
+	client, err := dynamicClient()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		profiles, err := client.Resource(profileResource).List(metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for _, p := range profiles.Items {
+			names = append(names, p.GetName())
+		}
+	}
+	bundle := &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "List"}}
+	for _, name := range names {
+		objects, err := exportProfile(client, name)
+		if err != nil {
+			return err
+		}
+		bundle.Items = append(bundle.Items, objects...)
+	}
+	data, err := bundle.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	data, err = yaml.JSONToYAML(data)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+// readBundle reads the objects of a bundle written by exportProfiles.
+func readBundle(path string) ([]unstructured.Unstructured, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err = yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &unstructured.UnstructuredList{}
+	if err := bundle.UnmarshalJSON(data); err != nil {
+		return nil, fmt.Errorf("%s is not a profile bundle: %v", path, err)
+	}
+	return bundle.Items, nil
+}
+
+// userIDMapper maps the user ids of a cluster to the ones of another, whose
+// identity provider can prefix them differently.
+type userIDMapper struct {
+	fromPrefix string
+	toPrefix   string
+	// users maps user ids explicitly, before the prefixes
+	users map[string]string
+}
+
+// newUserIDMapper returns a mapper of the user ids starting with fromPrefix,
+// and of the users mapped with OLD=NEW.
+func newUserIDMapper(fromPrefix, toPrefix string, users []string) (*userIDMapper, error) {
+	m := &userIDMapper{fromPrefix: fromPrefix, toPrefix: toPrefix, users: map[string]string{}}
+	for _, u := range users {
+		i := strings.Index(u, "=")
+		if i <= 0 || i == len(u)-1 {
+			return nil, fmt.Errorf("user mapping must be OLD=NEW, got %q", u)
+		}
+		m.users[u[:i]] = u[i+1:]
+	}
+	return m, nil
+}
+
+// mapID returns the user id on the other cluster.
+func (m *userIDMapper) mapID(id string) string {
+	if mapped, ok := m.users[id]; ok {
+		return mapped
+	}
+	if m.fromPrefix != "" && strings.HasPrefix(id, m.fromPrefix) {
+		return m.toPrefix + strings.TrimPrefix(id, m.fromPrefix)
+	}
+	return id
+}
+
+// mapHeaderValue returns the value of the user id header of the user on the
+// other cluster, which is the user id with the prefix of KFAM.
+func (m *userIDMapper) mapHeaderValue(value, user string) string {
+	if !strings.HasSuffix(value, user) {
+		return m.mapID(value)
+	}
+	prefix := strings.TrimSuffix(value, user)
+	if m.fromPrefix != "" && strings.HasPrefix(prefix, m.fromPrefix) {
+		prefix = m.toPrefix + strings.TrimPrefix(prefix, m.fromPrefix)
+	}
+	return prefix + m.mapID(user)
+}
+
+// mapSubjects maps the names of the User subjects of the field.
+func (m *userIDMapper) mapSubjects(obj map[string]interface{}, fields ...string) {
+	subjects, ok, _ := unstructured.NestedSlice(obj, fields...)
+	if !ok {
+		return
+	}
+	for _, s := range subjects {
+		if subject, ok := s.(map[string]interface{}); ok && subject["kind"] == "User" {
+			if name, ok := subject["name"].(string); ok {
+				subject["name"] = m.mapID(name)
+			}
+		}
+	}
+	unstructured.SetNestedSlice(obj, subjects, fields...)
+}
+
+// remap maps the user ids of the object of the bundle.
+func (m *userIDMapper) remap(obj *unstructured.Unstructured) {
+	annotations := obj.GetAnnotations()
+	user, hasUser := annotations[kfam.USER]
+	switch obj.GetKind() {
+	case "Profile":
+		if owner, ok, _ := unstructured.NestedMap(obj.Object, "spec", "owner"); ok && owner["kind"] == "User" {
+			if name, ok := owner["name"].(string); ok {
+				unstructured.SetNestedField(obj.Object, m.mapID(name), "spec", "owner", "name")
+			}
+		}
+		m.mapSubjects(obj.Object, "spec", "owners")
+		m.mapSubjects(obj.Object, "spec", "contributors")
+	case "RoleBinding":
+		m.mapSubjects(obj.Object, "subjects")
+	case "AuthorizationPolicy":
+		rules, _, _ := unstructured.NestedSlice(obj.Object, "spec", "rules")
+		for _, r := range rules {
+			rule, ok := r.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			conditions, _, _ := unstructured.NestedSlice(rule, "when")
+			for _, c := range conditions {
+				condition, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if key, _ := condition["key"].(string); !strings.HasPrefix(key, "request.headers[") {
+					continue
+				}
+				values, _, _ := unstructured.NestedStringSlice(condition, "values")
+				for i := range values {
+					if hasUser {
+						values[i] = m.mapHeaderValue(values[i], user)
+					} else {
+						values[i] = m.mapID(values[i])
+					}
+				}
+				unstructured.SetNestedStringSlice(condition, values, "values")
+			}
+			unstructured.SetNestedSlice(rule, conditions, "when")
+		}
+		if len(rules) > 0 {
+			unstructured.SetNestedSlice(obj.Object, rules, "spec", "rules")
+		}
+	}
+	if hasUser {
+		annotations[kfam.USER] = m.mapID(user)
+		obj.SetAnnotations(annotations)
+	}
+}
+
+// restoreProfiles creates the Profiles of the bundle and the objects of their
+// namespaces, once the profile controller created them. The existing objects
+// are left as is.
+func restoreProfiles(objects []unstructured.Unstructured, mapper *userIDMapper, timeout time.Duration) error {
+	client, err := dynamicClient()
+	if err != nil {
+		return err
+	}
+	for i := range objects {
+		mapper.remap(&objects[i])
+	}
+	for i := range objects {
+		profile := &objects[i]
+		if profile.GetKind() != "Profile" {
+			continue
+		}
+		_, err := client.Resource(profileResource).Create(profile, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			fmt.Printf("Profile %s already exists\n", profile.GetName())
+		} else if err != nil {
+			return fmt.Errorf("creating profile %s: %v", profile.GetName(), err)
+		} else {
+			fmt.Printf("Profile %s created\n", profile.GetName())
+		}
+	}
+
+	for i := range objects {
+		obj := &objects[i]
+		if obj.GetKind() == "Profile" {
+			continue
+		}
+		r, ok := getBundleResource(obj)
+		if !ok {
+			return fmt.Errorf("unsupported %s %s in bundle", obj.GetKind(), obj.GetName())
+		}
+		if err := waitForObject(client, namespaceResource, "", obj.GetNamespace(), timeout); err != nil {
+			return fmt.Errorf("waiting for namespace %s: %v", obj.GetNamespace(), err)
+		}
+		resource := client.Resource(r.resource).Namespace(obj.GetNamespace())
+		if r.annotationsOnly {
+			if err := waitForObject(client, r.resource, obj.GetNamespace(), obj.GetName(), timeout); err != nil {
+				return fmt.Errorf("waiting for %s %s/%s: %v", r.kind, obj.GetNamespace(), obj.GetName(), err)
+			}
+			patch, err := json.Marshal(map[string]interface{}{
+				"metadata": map[string]interface{}{"annotations": obj.GetAnnotations()},
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := resource.Patch(obj.GetName(), types.MergePatchType, patch, metav1.UpdateOptions{}); err != nil {
+				return fmt.Errorf("annotating %s %s/%s: %v", r.kind, obj.GetNamespace(), obj.GetName(), err)
+			}
+			fmt.Printf("%s %s/%s annotated\n", r.kind, obj.GetNamespace(), obj.GetName())
+			continue
+		}
+		_, err := resource.Create(obj, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			fmt.Printf("%s %s/%s already exists\n", r.kind, obj.GetNamespace(), obj.GetName())
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("creating %s %s/%s: %v", r.kind, obj.GetNamespace(), obj.GetName(), err)
+		}
+		fmt.Printf("%s %s/%s created\n", r.kind, obj.GetNamespace(), obj.GetName())
+	}
+	return nil
+}
+
+// waitForObject waits until the object exists.
+func waitForObject(client dynamic.Interface, resource schema.GroupVersionResource, namespace, name string,
+	timeout time.Duration) error {
+	return wait.PollImmediate(2*time.Second, timeout, func() (bool, error) {
+		_, err := client.Resource(resource).Namespace(namespace).Get(name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+}
+
+func dynamicClient() (dynamic.Interface, error) {
+	restconfig, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	return dynamic.NewForConfig(restconfig)
+}
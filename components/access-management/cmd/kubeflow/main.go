@@ -0,0 +1,115 @@
+// Copyright 2026 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command kubeflow manages notebooks, profiles and their members from the
+// terminal, without writing YAML or calling KFAM by hand.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	istioSecurityClient "istio.io/client-go/pkg/apis/security/v1beta1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+
+	profile "github.com/kubeflow/kubeflow/components/access-management/pkg/apis/kubeflow/v1"
+)
+
+const usage = `Usage: kubeflow [flags] <command> <subcommand> [args]
+
+Commands:
+  nb list                    List the notebooks of a namespace
+  nb start NAME              Start a stopped notebook
+  nb stop NAME               Stop a notebook, like the culler does
+  nb logs NAME               Print the logs of a notebook
+  nb port-forward NAME       Serve a notebook on localhost
+  nb url NAME                Print the URL of a notebook
+  profile create NAME        Create a profile owned by a user
+  profile share NAME         Give a user access to a profile
+  profile members NAME       List the users with access to a profile
+  profile transfer NAME      Make another user the owner of a profile
+  profile export [NAME...]   Write profiles and their bindings to a YAML bundle
+  profile restore FILE       Recreate the profiles of a bundle
+  profile migrate            Store all the profiles as v1, the storage version
+
+Run 'kubeflow <command> <subcommand> -h' for the flags of a subcommand.
+
+Flags:
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	profile.AddToScheme(scheme.Scheme)
+	istioSecurityClient.AddToScheme(scheme.Scheme)
+
+	args := flag.Args()
+	if len(args) < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch args[0] {
+	case "nb", "notebook", "notebooks":
+		err = runNotebookCommand(args[1], args[2:])
+	case "profile", "profiles":
+		err = runProfileCommand(args[1], args[2:])
+	default:
+		err = fmt.Errorf("unknown command %q", args[0])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// defaultNamespace returns the namespace of the current kubeconfig context.
+func defaultNamespace() string {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig := flag.Lookup("kubeconfig"); kubeconfig != nil {
+		rules.ExplicitPath = kubeconfig.Value.String()
+	}
+	loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{})
+	namespace, _, err := loader.Namespace()
+	if err != nil {
+		return "default"
+	}
+	return namespace
+}
+
+// parseNameArgs parses the flags of a subcommand that takes a single NAME
+// argument, which can come before or after the flags.
+func parseNameArgs(fs *flag.FlagSet, args []string) (string, error) {
+	var name string
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		name, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if name == "" && fs.NArg() > 0 {
+		name = fs.Arg(0)
+	}
+	if name == "" {
+		return "", fmt.Errorf("%s: missing NAME", fs.Name())
+	}
+	return name, nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kubeflow/kubeflow/components/access-management/kfam"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+func TestStopPatch(t *testing.T) {
+	now := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	var tests = []struct {
+		stop bool
+		out  string
+	}{
+		{true, `{"metadata":{"annotations":{"kubeflow-resource-stopped":"2022-03-04T05:06:07Z"}}}`},
+		{false, `{"metadata":{"annotations":{"kubeflow-resource-stopped":null}}}`},
+	}
+	for _, tt := range tests {
+		patch, err := stopPatch(tt.stop, now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(patch) != tt.out {
+			t.Errorf("stop=%v: expected %s, got %s", tt.stop, tt.out, patch)
+		}
+	}
+}
+
+func TestNotebookStatus(t *testing.T) {
+	var tests = []struct {
+		name   string
+		object map[string]interface{}
+		out    string
+	}{
+		{"ready", map[string]interface{}{
+			"status": map[string]interface{}{"readyReplicas": int64(1)},
+		}, "Ready"},
+		{"stopped", map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]interface{}{STOP_ANNOTATION: "2022-03-04T05:06:07Z"},
+			},
+		}, "Stopped"},
+		{"waiting", map[string]interface{}{
+			"status": map[string]interface{}{
+				"containerState": map[string]interface{}{
+					"waiting": map[string]interface{}{"reason": "ImagePullBackOff"},
+				},
+			},
+		}, "ImagePullBackOff"},
+		{"starting", map[string]interface{}{}, "Starting"},
+	}
+	for _, tt := range tests {
+		if got := notebookStatus(&unstructured.Unstructured{Object: tt.object}); got != tt.out {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.out, got)
+		}
+	}
+}
+
+func TestNotebookURL(t *testing.T) {
+	got := notebookURL("https://kubeflow.example.com/", "kubeflow-user", "my-notebook")
+	if got != "https://kubeflow.example.com/notebook/kubeflow-user/my-notebook/" {
+		t.Errorf("unexpected url %s", got)
+	}
+}
+
+func TestNewBinding(t *testing.T) {
+	binding, err := newBinding("kubeflow-user", "user@example.com", "view")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if binding.ReferredNamespace != "kubeflow-user" || binding.User.Name != "user@example.com" ||
+		binding.RoleRef.Kind != "ClusterRole" || binding.RoleRef.Name != "view" {
+		t.Errorf("unexpected binding %+v", binding)
+	}
+	if _, err := newBinding("kubeflow-user", "user@example.com", "owner"); err == nil {
+		t.Errorf("expected an error for an unknown role")
+	}
+}
+
+func TestExportedObject(t *testing.T) {
+	controller := true
+	profileRef := []metav1.OwnerReference{{Kind: "Profile", Name: "kubeflow-user", Controller: &controller}}
+	roleBindings, serviceAccounts := bundleResources[0], bundleResources[4]
+
+	kfamBinding := &unstructured.Unstructured{}
+	kfamBinding.SetName("user-other-example-com-clusterrole-edit")
+	kfamBinding.SetAnnotations(map[string]string{kfam.USER: "other@example.com", kfam.ROLE: "edit"})
+	kfamBinding.SetResourceVersion("42")
+	kfamBinding.SetUID("uid")
+	exported := exportedObject(roleBindings, kfamBinding)
+	if exported == nil || exported.GetResourceVersion() != "" || exported.GetUID() != "" ||
+		exported.GetAnnotations()[kfam.USER] != "other@example.com" {
+		t.Errorf("expected the KFAM binding without its cluster metadata, got %+v", exported)
+	}
+
+	kfamBinding.SetOwnerReferences(profileRef)
+	if exported := exportedObject(roleBindings, kfamBinding); exported != nil {
+		t.Errorf("expected the binding of the profile controller not to be exported, got %+v", exported)
+	}
+	otherBinding := &unstructured.Unstructured{}
+	otherBinding.SetName("other")
+	if exported := exportedObject(roleBindings, otherBinding); exported != nil {
+		t.Errorf("expected the binding KFAM didn't create not to be exported, got %+v", exported)
+	}
+
+	serviceAccount := &unstructured.Unstructured{Object: map[string]interface{}{
+		"secrets": []interface{}{map[string]interface{}{"name": "token"}},
+	}}
+	serviceAccount.SetName("default-editor")
+	serviceAccount.SetAnnotations(map[string]string{"iam.gke.io/gcp-service-account": "sa@example.com"})
+	serviceAccount.SetOwnerReferences(profileRef)
+	exported = exportedObject(serviceAccounts, serviceAccount)
+	if exported == nil || exported.Object["secrets"] != nil || len(exported.GetOwnerReferences()) != 0 ||
+		exported.GetAnnotations()["iam.gke.io/gcp-service-account"] != "sa@example.com" {
+		t.Errorf("expected only the annotations of the service account, got %+v", exported)
+	}
+}
+
+func TestUserIDMapper(t *testing.T) {
+	if _, err := newUserIDMapper("", "", []string{"old@example.com"}); err == nil {
+		t.Errorf("expected an error for a mapping without NEW")
+	}
+	mapper, err := newUserIDMapper("accounts.google.com:", "", []string{"old@example.com=new@example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	profile := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "kubeflow.org/v1",
+		"kind":       "Profile",
+		"spec": map[string]interface{}{
+			"owner": map[string]interface{}{"kind": "User", "name": "accounts.google.com:user@example.com"},
+			"contributors": []interface{}{
+				map[string]interface{}{"kind": "User", "name": "old@example.com", "role": "edit"},
+				map[string]interface{}{"kind": "Group", "name": "accounts.google.com:team", "role": "view"},
+			},
+		},
+	}}
+	mapper.remap(profile)
+	owner, _, _ := unstructured.NestedString(profile.Object, "spec", "owner", "name")
+	contributors, _, _ := unstructured.NestedSlice(profile.Object, "spec", "contributors")
+	if owner != "user@example.com" || contributors[0].(map[string]interface{})["name"] != "new@example.com" ||
+		contributors[1].(map[string]interface{})["name"] != "accounts.google.com:team" {
+		t.Errorf("unexpected remapped profile %+v", profile.Object)
+	}
+
+	// KFAM prefixes the user id in the header value of its AuthorizationPolicies.
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "security.istio.io/v1beta1",
+		"kind":       "AuthorizationPolicy",
+		"spec": map[string]interface{}{
+			"rules": []interface{}{map[string]interface{}{
+				"when": []interface{}{map[string]interface{}{
+					"key":    "request.headers[kubeflow-userid]",
+					"values": []interface{}{"accounts.google.com:old@example.com"},
+				}},
+			}},
+		},
+	}}
+	policy.SetAnnotations(map[string]string{kfam.USER: "old@example.com", kfam.ROLE: "edit"})
+	mapper.remap(policy)
+	rules, _, _ := unstructured.NestedSlice(policy.Object, "spec", "rules")
+	when := rules[0].(map[string]interface{})["when"].([]interface{})
+	values, _, _ := unstructured.NestedStringSlice(when[0].(map[string]interface{}), "values")
+	if !reflect.DeepEqual(values, []string{"new@example.com"}) || policy.GetAnnotations()[kfam.USER] != "new@example.com" {
+		t.Errorf("unexpected remapped policy %+v", policy.Object)
+	}
+}
+
+func TestParseNamesArgs(t *testing.T) {
+	fs := flag.NewFlagSet("profile export", flag.ContinueOnError)
+	output := fs.String("o", "-", "")
+	names, err := parseNamesArgs(fs, []string{"a", "b", "-o", "bundle.yaml", "c"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"a", "b", "c"}) || *output != "bundle.yaml" {
+		t.Errorf("unexpected names %v and output %s", names, *output)
+	}
+}
+
+func TestMigrateProfiles(t *testing.T) {
+	profile := `{"apiVersion":"kubeflow.org/v1","kind":"Profile","metadata":{"name":"kubeflow-user"}}`
+	storedVersions := []string{"v1beta1", "v1"}
+	updated := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /apis/kubeflow.org/v1/profiles":
+			w.Write([]byte(`{"apiVersion":"kubeflow.org/v1","kind":"ProfileList","items":[` + profile + `]}`))
+		case "PUT /apis/kubeflow.org/v1/profiles/kubeflow-user":
+			updated = string(body)
+			w.Write(body)
+		case "PATCH /apis/apiextensions.k8s.io/v1/customresourcedefinitions/profiles.kubeflow.org/status":
+			patch := struct {
+				Status struct {
+					StoredVersions []string `json:"storedVersions"`
+				} `json:"status"`
+			}{}
+			json.Unmarshal(body, &patch)
+			storedVersions = patch.Status.StoredVersions
+			fallthrough
+		case "GET /apis/apiextensions.k8s.io/v1/customresourcedefinitions/profiles.kubeflow.org":
+			crd, _ := json.Marshal(map[string]interface{}{
+				"apiVersion": "apiextensions.k8s.io/v1",
+				"kind":       "CustomResourceDefinition",
+				"metadata":   map[string]interface{}{"name": profileCRDName},
+				"status":     map[string]interface{}{"storedVersions": storedVersions},
+			})
+			w.Write(crd)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client, err := dynamic.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateProfiles(client, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated != "" || len(storedVersions) != 2 {
+		t.Errorf("expected a dry run to change nothing, got %s and %v", updated, storedVersions)
+	}
+
+	if err := migrateProfiles(client, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated == "" {
+		t.Errorf("expected the profile to be written back")
+	}
+	if !reflect.DeepEqual(storedVersions, []string{"v1"}) {
+		t.Errorf("expected only v1 to be stored, got %v", storedVersions)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2026 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// profileStorageVersion is the version the API server stores the Profiles in.
+const profileStorageVersion = "v1"
+
+const profileCRDName = "profiles.kubeflow.org"
+
+var crdResource = schema.GroupVersionResource{
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1",
+	Resource: "customresourcedefinitions",
+}
+
+// migrateProfiles writes every Profile back unchanged, so that the API server
+// stores it in the storage version, and then removes the other versions from
+// the stored versions of the CRD. Until then, they can't be removed from the
+// CRD.
+func migrateProfiles(client dynamic.Interface, dryRun bool) error {
+	profiles, err := client.Resource(profileResource).List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("listing profiles: %v", err)
+	}
+	for i := range profiles.Items {
+		name := profiles.Items[i].GetName()
+		if dryRun {
+			fmt.Printf("Profile %s would be migrated\n", name)
+			continue
+		}
+		if err := migrateProfile(client, &profiles.Items[i]); err != nil {
+			return fmt.Errorf("migrating profile %s: %v", name, err)
+		}
+		fmt.Printf("Profile %s migrated\n", name)
+	}
+
+	crd, err := client.Resource(crdResource).Get(profileCRDName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting CRD %s: %v", profileCRDName, err)
+	}
+	storedVersions, _, err := unstructured.NestedStringSlice(crd.Object, "status", "storedVersions")
+	if err != nil {
+		return fmt.Errorf("reading stored versions of CRD %s: %v", profileCRDName, err)
+	}
+	if len(storedVersions) == 1 && storedVersions[0] == profileStorageVersion {
+		fmt.Printf("CRD %s only stores %s\n", profileCRDName, profileStorageVersion)
+		return nil
+	}
+	if dryRun {
+		fmt.Printf("CRD %s stored versions %v would be set to [%s]\n", profileCRDName, storedVersions,
+			profileStorageVersion)
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{"storedVersions": []string{profileStorageVersion}},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := client.Resource(crdResource).Patch(profileCRDName, types.MergePatchType, patch,
+		metav1.UpdateOptions{}, "status"); err != nil {
+		return fmt.Errorf("updating stored versions of CRD %s: %v", profileCRDName, err)
+	}
+	fmt.Printf("CRD %s stored versions set to [%s]\n", profileCRDName, profileStorageVersion)
+	return nil
+}
+
+// migrateProfile updates the profile with its own content, getting it again
+// if it changed in the meantime.
+func migrateProfile(client dynamic.Interface, profile *unstructured.Unstructured) error {
+	resource := client.Resource(profileResource)
+	for retry := 0; ; retry++ {
+		_, err := resource.Update(profile, metav1.UpdateOptions{})
+		if err == nil || apierrors.IsNotFound(err) {
+			return nil
+		}
+		if !apierrors.IsConflict(err) || retry >= 5 {
+			return err
+		}
+		if profile, err = resource.Get(profile.GetName(), metav1.GetOptions{}); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+	}
+}
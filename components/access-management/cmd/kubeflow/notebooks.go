@@ -0,0 +1,252 @@
+// Copyright 2026 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// STOP_ANNOTATION of the notebook controller. The culler sets it on idle
+// Notebooks, and the controller scales the Notebooks that have it to zero.
+const STOP_ANNOTATION = "kubeflow-resource-stopped"
+
+// The v1beta1 Notebooks are handled as unstructured objects, since this
+// module can't depend on the notebook controller and its newer client-go.
+var notebookResource = schema.GroupVersionResource{
+	Group:    "kubeflow.org",
+	Version:  "v1beta1",
+	Resource: "notebooks",
+}
+
+func runNotebookCommand(command string, args []string) error {
+	fs := flag.NewFlagSet("nb "+command, flag.ExitOnError)
+	namespace := fs.String("n", defaultNamespace(), "Namespace of the notebook")
+
+	switch command {
+	case "list":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		return listNotebooks(*namespace)
+	case "start", "stop":
+		name, err := parseNameArgs(fs, args)
+		if err != nil {
+			return err
+		}
+		return setNotebookStopped(*namespace, name, command == "stop")
+	case "logs":
+		follow := fs.Bool("f", false, "Follow the logs")
+		name, err := parseNameArgs(fs, args)
+		if err != nil {
+			return err
+		}
+		return notebookLogs(*namespace, name, *follow)
+	case "port-forward":
+		port := fs.Int("port", 8888, "Local port to serve the notebook on")
+		name, err := parseNameArgs(fs, args)
+		if err != nil {
+			return err
+		}
+		return portForwardNotebook(*namespace, name, *port)
+	case "url":
+		baseURL := fs.String("base-url", os.Getenv("KUBEFLOW_URL"),
+			"URL of the Kubeflow dashboard. Defaults to the KUBEFLOW_URL env var")
+		name, err := parseNameArgs(fs, args)
+		if err != nil {
+			return err
+		}
+		if *baseURL == "" {
+			return fmt.Errorf("set --base-url or the KUBEFLOW_URL env var")
+		}
+		fmt.Println(notebookURL(*baseURL, *namespace, name))
+		return nil
+	}
+	return fmt.Errorf("unknown notebook command %q", command)
+}
+
+func notebookClient(namespace string) (dynamic.ResourceInterface, error) {
+	restconfig, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := dynamic.NewForConfig(restconfig)
+	if err != nil {
+		return nil, err
+	}
+	return client.Resource(notebookResource).Namespace(namespace), nil
+}
+
+func listNotebooks(namespace string) error {
+	client, err := notebookClient(namespace)
+	if err != nil {
+		return err
+	}
+	notebooks, err := client.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tIMAGE")
+	for i := range notebooks.Items {
+		nb := &notebooks.Items[i]
+		fmt.Fprintf(w, "%s\t%s\t%s\n", nb.GetName(), notebookStatus(nb), notebookImage(nb))
+	}
+	return w.Flush()
+}
+
+// notebookStatus summarizes the status of a Notebook like the Jupyter web app.
+func notebookStatus(nb *unstructured.Unstructured) string {
+	if _, ok := nb.GetAnnotations()[STOP_ANNOTATION]; ok {
+		if ready, _, _ := unstructured.NestedInt64(nb.Object, "status", "readyReplicas"); ready > 0 {
+			return "Stopping"
+		}
+		return "Stopped"
+	}
+	if ready, _, _ := unstructured.NestedInt64(nb.Object, "status", "readyReplicas"); ready > 0 {
+		return "Ready"
+	}
+	if reason, ok, _ := unstructured.NestedString(nb.Object, "status", "containerState", "waiting", "reason"); ok {
+		return reason
+	}
+	return "Starting"
+}
+
+func notebookImage(nb *unstructured.Unstructured) string {
+	containers, _, _ := unstructured.NestedSlice(nb.Object, "spec", "template", "spec", "containers")
+	if len(containers) == 0 {
+		return ""
+	}
+	container, _ := containers[0].(map[string]interface{})
+	image, _, _ := unstructured.NestedString(container, "image")
+	return image
+}
+
+// stopPatch returns the merge patch that sets or removes STOP_ANNOTATION.
+func stopPatch(stop bool, now time.Time) ([]byte, error) {
+	var value interface{}
+	if stop {
+		value = now.UTC().Format(time.RFC3339)
+	}
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				STOP_ANNOTATION: value,
+			},
+		},
+	})
+}
+
+func setNotebookStopped(namespace, name string, stop bool) error {
+	client, err := notebookClient(namespace)
+	if err != nil {
+		return err
+	}
+	patch, err := stopPatch(stop, time.Now())
+	if err != nil {
+		return err
+	}
+	if _, err := client.Patch(name, types.MergePatchType, patch, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	if stop {
+		fmt.Printf("Notebook %s/%s is stopping\n", namespace, name)
+	} else {
+		fmt.Printf("Notebook %s/%s is starting\n", namespace, name)
+	}
+	return nil
+}
+
+func notebookLogs(namespace, name string, follow bool) error {
+	restconfig, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
+	kubeClient, err := clientset.NewForConfig(restconfig)
+	if err != nil {
+		return err
+	}
+
+	// The notebook controller runs every Notebook as the only replica of a
+	// StatefulSet, in a container named after the Notebook.
+	logs, err := kubeClient.CoreV1().Pods(namespace).GetLogs(name+"-0", &corev1.PodLogOptions{
+		Container: name,
+		Follow:    follow,
+	}).Stream()
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+	_, err = io.Copy(os.Stdout, logs)
+	return err
+}
+
+// portForwardNotebook serves the Notebook on localhost by proxying requests
+// through the API server to the Service of the Notebook, like the culler
+// does in DEV mode.
+func portForwardNotebook(namespace, name string, port int) error {
+	restconfig, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
+	transport, err := rest.TransportFor(restconfig)
+	if err != nil {
+		return err
+	}
+	target, err := url.Parse(restconfig.Host)
+	if err != nil {
+		return err
+	}
+	target.Path = strings.TrimSuffix(target.Path, "/") + fmt.Sprintf(
+		"/api/v1/namespaces/%s/services/%s:http-%s/proxy", namespace, name, name)
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = transport
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = target.Host
+	}
+
+	address := fmt.Sprintf("localhost:%d", port)
+	fmt.Printf("Serving notebook %s/%s on %s\n", namespace, name, notebookURL("http://"+address, namespace, name))
+	return http.ListenAndServe(address, proxy)
+}
+
+// notebookURL returns the URL under which the Notebook is served behind the
+// Istio gateway at baseURL.
+func notebookURL(baseURL, namespace, name string) string {
+	return fmt.Sprintf("%s/notebook/%s/%s/", strings.TrimSuffix(baseURL, "/"), namespace, name)
+}
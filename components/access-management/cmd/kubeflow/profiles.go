@@ -0,0 +1,256 @@
+// Copyright 2026 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kubeflow/kubeflow/components/access-management/kfam"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func runProfileCommand(command string, args []string) error {
+	fs := flag.NewFlagSet("profile "+command, flag.ExitOnError)
+
+	switch command {
+	case "create":
+		owner := fs.String("owner", "", "User id of the owner of the profile")
+		name, err := parseNameArgs(fs, args)
+		if err != nil {
+			return err
+		}
+		if *owner == "" {
+			return fmt.Errorf("missing --owner")
+		}
+		return createProfile(name, *owner)
+	case "share":
+		user := fs.String("user", "", "User id to share the profile with")
+		role := fs.String("role", "edit", "Role of the user in the profile, edit or view")
+		name, err := parseNameArgs(fs, args)
+		if err != nil {
+			return err
+		}
+		if *user == "" {
+			return fmt.Errorf("missing --user")
+		}
+		return shareProfile(name, *user, *role)
+	case "transfer":
+		owner := fs.String("owner", "", "User id of the new owner of the profile")
+		name, err := parseNameArgs(fs, args)
+		if err != nil {
+			return err
+		}
+		if *owner == "" {
+			return fmt.Errorf("missing --owner")
+		}
+		return transferProfile(name, *owner)
+	case "members":
+		name, err := parseNameArgs(fs, args)
+		if err != nil {
+			return err
+		}
+		return listMembers(name)
+	case "export":
+		output := fs.String("o", "-", "File to write the bundle to, stdout by default")
+		names, err := parseNamesArgs(fs, args)
+		if err != nil {
+			return err
+		}
+		return exportProfilesTo(names, *output)
+	case "restore":
+		fromPrefix := fs.String("userid-prefix-from", "", "Prefix of the user ids of the exported cluster to replace")
+		toPrefix := fs.String("userid-prefix-to", "", "Prefix replacing --userid-prefix-from")
+		users := stringList{}
+		fs.Var(&users, "map-user", "OLD=NEW user id to replace, can be repeated")
+		timeout := fs.Duration("timeout", 2*time.Minute, "How long to wait for the profile controller to create each namespace")
+		path, err := parseNameArgs(fs, args)
+		if err != nil {
+			return err
+		}
+		mapper, err := newUserIDMapper(*fromPrefix, *toPrefix, users)
+		if err != nil {
+			return err
+		}
+		objects, err := readBundle(path)
+		if err != nil {
+			return err
+		}
+		return restoreProfiles(objects, mapper, *timeout)
+	case "migrate":
+		dryRun := fs.Bool("dry-run", false, "Only print what would be migrated")
+		fs.Parse(args)
+		if fs.NArg() != 0 {
+			return fmt.Errorf("unexpected arguments %v", fs.Args())
+		}
+		client, err := dynamicClient()
+		if err != nil {
+			return err
+		}
+		return migrateProfiles(client, *dryRun)
+	}
+	return fmt.Errorf("unknown profile command %q", command)
+}
+
+func createProfile(name, owner string) error {
+	client, err := kfam.NewProfileClient()
+	if err != nil {
+		return err
+	}
+	err = client.Create(map[string]interface{}{
+		"metadata": metav1.ObjectMeta{Name: name},
+		"spec": map[string]interface{}{
+			"owner": rbacv1.Subject{
+				Kind: rbacv1.UserKind,
+				Name: owner,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Profile %s created for %s\n", name, owner)
+	return nil
+}
+
+func transferProfile(name, owner string) error {
+	client, err := kfam.NewProfileClient()
+	if err != nil {
+		return err
+	}
+	owners, err := client.GetOwners(name)
+	if err != nil {
+		return err
+	}
+	err = client.TransferOwner(name, owners[0].Name, rbacv1.Subject{
+		APIGroup: rbacv1.GroupName,
+		Kind:     rbacv1.UserKind,
+		Name:     owner,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Profile %s transferred from %s to %s\n", name, owners[0].Name, owner)
+	return nil
+}
+
+// newBinding returns the Binding that the central dashboard sends to KFAM
+// when a contributor is added to a profile.
+func newBinding(profile, user, role string) (*kfam.Binding, error) {
+	if role != "edit" && role != "view" {
+		return nil, fmt.Errorf("role must be edit or view, got %q", role)
+	}
+	return &kfam.Binding{
+		User: &rbacv1.Subject{
+			Kind: rbacv1.UserKind,
+			Name: user,
+		},
+		ReferredNamespace: profile,
+		RoleRef: &rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     role,
+		},
+	}, nil
+}
+
+func shareProfile(profile, user, role string) error {
+	binding, err := newBinding(profile, user, role)
+	if err != nil {
+		return err
+	}
+	contributor, err := kfam.ContributorFromBinding(binding)
+	if err != nil {
+		return err
+	}
+	client, err := kfam.NewProfileClient()
+	if err != nil {
+		return err
+	}
+	if err := client.AddContributor(profile, contributor); err != nil {
+		return err
+	}
+	fmt.Printf("Profile %s shared with %s as %s\n", profile, user, role)
+	return nil
+}
+
+func listMembers(profile string) error {
+	client, err := kfam.NewBindingClient(profile)
+	if err != nil {
+		return err
+	}
+	bindings, err := client.List("", []string{profile}, "")
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tROLE")
+	for _, binding := range bindings.Bindings {
+		fmt.Fprintf(w, "%s\t%s\n", binding.User.Name, binding.RoleRef.Name)
+	}
+	return w.Flush()
+}
+
+// exportProfilesTo writes the bundle of the Profiles to the file, or to
+// stdout if it is "-".
+func exportProfilesTo(names []string, output string) error {
+	if output == "-" {
+		return exportProfiles(names, os.Stdout)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := exportProfiles(names, f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Profiles exported to %s\n", output)
+	return nil
+}
+
+// parseNamesArgs parses the flags of a subcommand that takes any number of
+// NAME arguments, which can come before or after the flags.
+func parseNamesArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	names := []string{}
+	for len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		names, args = append(names, args[0]), args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return append(names, fs.Args()...), nil
+}
+
+// stringList is a flag that can be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -16,12 +16,14 @@ import (
 	"net/url"
 	"path"
 	"strconv"
+	"strings"
 	"time"
 
-	profileRegister "github.com/kubeflow/kubeflow/components/access-management/pkg/apis/kubeflow/v1beta1"
-	profilev1beta1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1beta1"
+	profileRegister "github.com/kubeflow/kubeflow/components/access-management/pkg/apis/kubeflow/v1"
+	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 	istioRegister "istio.io/client-go/pkg/apis/security/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
@@ -40,6 +42,7 @@ type KfamV1Alpha1Interface interface {
 	DeleteProfile(w http.ResponseWriter, r *http.Request)
 	ReadBinding(w http.ResponseWriter, r *http.Request)
 	QueryClusterAdmin(w http.ResponseWriter, r *http.Request)
+	TransferProfileOwner(w http.ResponseWriter, r *http.Request)
 }
 
 type KfamV1Alpha1Client struct {
@@ -48,9 +51,10 @@ type KfamV1Alpha1Client struct {
 	clusterAdmin  []string
 	userIdHeader  string
 	userIdPrefix  string
+	groupsHeader  string
 }
 
-func NewKfamClient(userIdHeader string, userIdPrefix string, clusterAdmin string) (*KfamV1Alpha1Client, error) {
+func NewKfamClient(userIdHeader string, userIdPrefix string, groupsHeader string, clusterAdmin string) (*KfamV1Alpha1Client, error) {
 	profileRESTClient, err := getRESTClient(profileRegister.GroupName, profileRegister.GroupVersion)
 	if err != nil {
 		return nil, err
@@ -86,6 +90,7 @@ func NewKfamClient(userIdHeader string, userIdPrefix string, clusterAdmin string
 		clusterAdmin: []string{clusterAdmin},
 		userIdHeader: userIdHeader,
 		userIdPrefix: userIdPrefix,
+		groupsHeader: groupsHeader,
 	}, nil
 }
 
@@ -101,6 +106,47 @@ func getRESTClient(group string, version string) (*rest.RESTClient, error) {
 	return rest.RESTClientFor(restconfig)
 }
 
+// NewProfileClient returns a ProfileClient for the cluster of the default
+// kubeconfig.
+func NewProfileClient() (*ProfileClient, error) {
+	profileRESTClient, err := getRESTClient(profileRegister.GroupName, profileRegister.GroupVersion)
+	if err != nil {
+		return nil, err
+	}
+	return &ProfileClient{restClient: profileRESTClient}, nil
+}
+
+// NewBindingClient returns a BindingClient for the cluster of the default
+// kubeconfig. It only lists the RoleBindings of namespace, so it can be used
+// by users who are not allowed to list RoleBindings in the whole cluster.
+func NewBindingClient(namespace string) (*BindingClient, error) {
+	istioRESTClient, err := getRESTClient(istioRegister.GroupName, "v1beta1")
+	if err != nil {
+		return nil, err
+	}
+	restconfig, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	kubeClient, err := clientset.NewForConfig(restconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(kubeClient, time.Minute*60,
+		informers.WithNamespace(namespace))
+	roleBindingLister := informerFactory.Rbac().V1().RoleBindings().Lister()
+	stop := make(chan struct{})
+	informerFactory.Start(stop)
+	informerFactory.WaitForCacheSync(stop)
+
+	return &BindingClient{
+		restClient:        istioRESTClient,
+		kubeClient:        kubeClient,
+		roleBindingLister: roleBindingLister,
+	}, nil
+}
+
 func (c *KfamV1Alpha1Client) CreateBinding(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	const action = "create"
@@ -114,8 +160,13 @@ func (c *KfamV1Alpha1Client) CreateBinding(w http.ResponseWriter, r *http.Reques
 	}
 	// check permission before create binding
 	useremail := c.getUserEmail(r.Header)
-	if c.isOwnerOrAdmin(useremail, binding.ReferredNamespace) {
-		err := c.bindingClient.Create(&binding, c.userIdHeader, c.userIdPrefix)
+	if c.isOwnerOrAdmin(useremail, c.getUserGroups(r.Header), binding.ReferredNamespace) {
+		// The profile controller creates the RoleBinding and the
+		// AuthorizationPolicy of the contributor.
+		contributor, err := ContributorFromBinding(&binding)
+		if err == nil {
+			err = c.profileClient.AddContributor(binding.ReferredNamespace, contributor)
+		}
 		if err != nil {
 			IncRequestErrorCounter(err.Error(), useremail, action, r.URL.Path,
 				SEVERITY_MAJOR)
@@ -134,7 +185,7 @@ func (c *KfamV1Alpha1Client) CreateBinding(w http.ResponseWriter, r *http.Reques
 func (c *KfamV1Alpha1Client) CreateProfile(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	const action = "create"
-	var profile profilev1beta1.Profile
+	profile := map[string]interface{}{}
 	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
 		IncRequestErrorCounter("decode error", "", action, r.URL.Path,
 			SEVERITY_MAJOR)
@@ -142,8 +193,7 @@ func (c *KfamV1Alpha1Client) CreateProfile(w http.ResponseWriter, r *http.Reques
 		writeResponse(w, []byte(err.Error()))
 		return
 	}
-	_, err := c.profileClient.Create(&profile)
-	if err != nil {
+	if err := c.profileClient.Create(profile); err != nil {
 		IncRequestErrorCounter(err.Error(), "", action, r.URL.Path,
 			SEVERITY_MAJOR)
 		w.WriteHeader(http.StatusForbidden)
@@ -167,8 +217,17 @@ func (c *KfamV1Alpha1Client) DeleteBinding(w http.ResponseWriter, r *http.Reques
 	}
 	// check permission before delete
 	useremail := c.getUserEmail(r.Header)
-	if c.isOwnerOrAdmin(useremail, binding.ReferredNamespace) {
-		err := c.bindingClient.Delete(&binding)
+	if c.isOwnerOrAdmin(useremail, c.getUserGroups(r.Header), binding.ReferredNamespace) {
+		removed := false
+		contributor, err := ContributorFromBinding(&binding)
+		if err == nil {
+			removed, err = c.profileClient.RemoveContributor(binding.ReferredNamespace, contributor)
+		}
+		// Bindings created before spec.contributors existed are deleted
+		// directly.
+		if err == nil && !removed {
+			err = c.bindingClient.Delete(&binding)
+		}
 		if err != nil {
 			IncRequestErrorCounter(err.Error(), useremail, action, r.URL.Path,
 				SEVERITY_MAJOR)
@@ -190,7 +249,7 @@ func (c *KfamV1Alpha1Client) DeleteProfile(w http.ResponseWriter, r *http.Reques
 	useremail := c.getUserEmail(r.Header)
 	profileName := path.Base(r.RequestURI)
 	// check permission before delete
-	if c.isOwnerOrAdmin(useremail, profileName) {
+	if c.isOwnerOrAdmin(useremail, c.getUserGroups(r.Header), profileName) {
 		err := c.profileClient.Delete(profileName, nil)
 		if err != nil {
 			IncRequestErrorCounter(err.Error(), useremail, action, r.URL.Path,
@@ -207,6 +266,58 @@ func (c *KfamV1Alpha1Client) DeleteProfile(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+// TransferProfileOwner makes the user of the request body the owner of the
+// profile. Only the current owner and the cluster admins can transfer it.
+func (c *KfamV1Alpha1Client) TransferProfileOwner(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	const action = "update"
+	var owner rbacv1.Subject
+	if err := json.NewDecoder(r.Body).Decode(&owner); err != nil {
+		IncRequestErrorCounter("decode error", "", action, r.URL.Path,
+			SEVERITY_MAJOR)
+		w.WriteHeader(http.StatusForbidden)
+		writeResponse(w, []byte(err.Error()))
+		return
+	}
+	if owner.Kind == "" {
+		owner.Kind = rbacv1.UserKind
+	}
+	if owner.Kind != rbacv1.UserKind || owner.Name == "" {
+		IncRequestErrorCounter("invalid owner", "", action, r.URL.Path,
+			SEVERITY_MINOR)
+		w.WriteHeader(http.StatusForbidden)
+		writeResponse(w, []byte("the owner must be a user"))
+		return
+	}
+	owner.APIGroup = rbacv1.GroupName
+	useremail := c.getUserEmail(r.Header)
+	profileName := mux.Vars(r)["profile"]
+	owners, err := c.profileClient.GetOwners(profileName)
+	if err != nil {
+		IncRequestErrorCounter(err.Error(), useremail, action, r.URL.Path,
+			SEVERITY_MAJOR)
+		w.WriteHeader(http.StatusForbidden)
+		writeResponse(w, []byte(err.Error()))
+		return
+	}
+	// owners[0] is spec.owner, the co-owners can't transfer the profile.
+	currentOwner := owners[0].Name
+	if currentOwner != useremail && !c.isClusterAdmin(useremail) {
+		IncRequestCounter("forbidden", useremail, action, r.URL.Path)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if err := c.profileClient.TransferOwner(profileName, currentOwner, owner); err != nil {
+		IncRequestErrorCounter(err.Error(), useremail, action, r.URL.Path,
+			SEVERITY_MAJOR)
+		w.WriteHeader(http.StatusForbidden)
+		writeResponse(w, []byte(err.Error()))
+		return
+	}
+	IncRequestCounter("", useremail, action, r.URL.Path)
+	w.WriteHeader(http.StatusOK)
+}
+
 func (c *KfamV1Alpha1Client) ReadBinding(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	const action = "read"
@@ -221,12 +332,13 @@ func (c *KfamV1Alpha1Client) ReadBinding(w http.ResponseWriter, r *http.Request)
 	namespaces := []string{}
 	// by default scan all namespaces created by profile CR
 	if queries.Get("namespace") == "" {
-		profList, err := c.profileClient.List(metav1.ListOptions{})
+		profiles, err := c.profileClient.List(metav1.ListOptions{})
 		if err != nil {
 			w.WriteHeader(http.StatusForbidden)
 			writeResponse(w, []byte(err.Error()))
+			return
 		}
-		for _, profile := range profList.Items {
+		for _, profile := range profiles {
 			namespaces = append(namespaces, profile.Name)
 		}
 	} else {
@@ -290,6 +402,21 @@ func (c *KfamV1Alpha1Client) getUserEmail(header http.Header) string {
 	return header.Get(c.userIdHeader)[len(c.userIdPrefix):]
 }
 
+// getUserGroups returns the comma separated groups of the groups header, none
+// if it isn't configured.
+func (c *KfamV1Alpha1Client) getUserGroups(header http.Header) []string {
+	groups := []string{}
+	if c.groupsHeader == "" {
+		return groups
+	}
+	for _, group := range strings.Split(header.Get(c.groupsHeader), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
 func (c *KfamV1Alpha1Client) isClusterAdmin(queryUser string) bool {
 	for _, val := range c.clusterAdmin {
 		if val == queryUser {
@@ -299,12 +426,30 @@ func (c *KfamV1Alpha1Client) isClusterAdmin(queryUser string) bool {
 	return false
 }
 
-//isOwnerOrAdmin return true if queryUser is cluster admin or profile owner
-func (c *KfamV1Alpha1Client) isOwnerOrAdmin(queryUser string, profileName string) bool {
+//isOwnerOrAdmin return true if queryUser is cluster admin or profile owner, either
+//as a user or as a member of one of queryGroups
+func (c *KfamV1Alpha1Client) isOwnerOrAdmin(queryUser string, queryGroups []string, profileName string) bool {
 	isAdmin := c.isClusterAdmin(queryUser)
-	prof, err := c.profileClient.Get(profileName, metav1.GetOptions{})
+	owners, err := c.profileClient.GetOwners(profileName)
 	if err != nil {
 		return false
 	}
-	return isAdmin || (prof.Spec.Owner.Name == queryUser)
+	if isAdmin {
+		return true
+	}
+	for _, owner := range owners {
+		switch owner.Kind {
+		case rbacv1.UserKind:
+			if owner.Name == queryUser {
+				return true
+			}
+		case rbacv1.GroupKind:
+			for _, group := range queryGroups {
+				if owner.Name == group {
+					return true
+				}
+			}
+		}
+	}
+	return false
 }
@@ -15,18 +15,50 @@
 package kfam
 
 import (
-	"github.com/kubeflow/kubeflow/components/profile-controller/api/v1beta1"
+	"encoding/json"
+	"fmt"
+
+	profileRegister "github.com/kubeflow/kubeflow/components/access-management/pkg/apis/kubeflow/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 )
 
+// ProfileInterface reads and writes the Profiles as raw JSON, so that none of
+// the fields of v1, their storage version, are dropped.
 type ProfileInterface interface {
-	Create(profile *v1beta1.Profile) (*v1beta1.Profile, error)
+	Create(profile map[string]interface{}) error
 	Delete(name string, opts *metav1.DeleteOptions) error
-	Get(name string, opts metav1.GetOptions) (*v1beta1.Profile, error)
-	List(opts metav1.ListOptions) (*v1beta1.ProfileList, error)
-	Update(profile *v1beta1.Profile) (*v1beta1.Profile, error)
+	List(opts metav1.ListOptions) ([]metav1.ObjectMeta, error)
+	AddContributor(name string, contributor Contributor) error
+	RemoveContributor(name string, contributor Contributor) (bool, error)
+	GetOwners(name string) ([]rbacv1.Subject, error)
+	TransferOwner(name string, from string, owner rbacv1.Subject) error
+}
+
+// Contributor is an entry of spec.contributors of a Profile. The profile
+// controller reconciles a RoleBinding and an AuthorizationPolicy for every
+// contributor. It mirrors ProfileContributor.
+type Contributor struct {
+	rbacv1.Subject `json:",inline"`
+	Role           string `json:"role"`
+}
+
+// ContributorFromBinding returns the contributor equivalent to a binding.
+func ContributorFromBinding(binding *Binding) (Contributor, error) {
+	if binding.User == nil || binding.RoleRef == nil {
+		return Contributor{}, fmt.Errorf("binding must have a user and a RoleRef")
+	}
+	if _, ok := roleBindingNameMap["kubeflow-"+binding.RoleRef.Name]; !ok {
+		return Contributor{}, fmt.Errorf("role must be admin, edit or view, got %q", binding.RoleRef.Name)
+	}
+	return Contributor{Subject: *binding.User, Role: binding.RoleRef.Name}, nil
+}
+
+func (c Contributor) matches(other Contributor) bool {
+	return c.Kind == other.Kind && c.Name == other.Name && c.Namespace == other.Namespace && c.Role == other.Role
 }
 
 type ProfileClient struct {
@@ -35,16 +67,21 @@ type ProfileClient struct {
 
 const Profiles = "profiles"
 
-func (c *ProfileClient) Create(profile *v1beta1.Profile) (*v1beta1.Profile, error) {
-	result := v1beta1.Profile{}
-	err := c.restClient.
+// Create creates the Profile as v1, whatever the version it was written for,
+// since v1 is a superset of v1beta1.
+func (c *ProfileClient) Create(profile map[string]interface{}) error {
+	profile["apiVersion"] = profileRegister.SchemeGroupVersion.String()
+	profile["kind"] = "Profile"
+	body, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	return c.restClient.
 		Post().
 		Resource(Profiles).
-		Body(profile).
+		Body(body).
 		Do().
-		Into(&result)
-
-	return &result, err
+		Error()
 }
 
 func (c *ProfileClient) Delete(name string, opts *metav1.DeleteOptions) error {
@@ -57,39 +94,176 @@ func (c *ProfileClient) Delete(name string, opts *metav1.DeleteOptions) error {
 		Error()
 }
 
-func (c *ProfileClient) Get(name string, opts metav1.GetOptions) (*v1beta1.Profile, error) {
-	result := v1beta1.Profile{}
-	err := c.restClient.
+// List returns the metadata of the Profiles.
+func (c *ProfileClient) List(opts metav1.ListOptions) ([]metav1.ObjectMeta, error) {
+	raw, err := c.restClient.
 		Get().
 		Resource(Profiles).
-		Name(name).
 		VersionedParams(&opts, scheme.ParameterCodec).
 		Do().
-		Into(&result)
+		Raw()
+	if err != nil {
+		return nil, err
+	}
+	list := struct {
+		Items []struct {
+			metav1.ObjectMeta `json:"metadata"`
+		} `json:"items"`
+	}{}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+	result := []metav1.ObjectMeta{}
+	for _, item := range list.Items {
+		result = append(result, item.ObjectMeta)
+	}
+	return result, nil
+}
+
+// AddContributor adds contributor to spec.contributors of the Profile name,
+// unless it is already there.
+func (c *ProfileClient) AddContributor(name string, contributor Contributor) error {
+	_, err := c.updateContributors(name, func(contributors []Contributor) ([]Contributor, bool) {
+		for _, existing := range contributors {
+			if existing.matches(contributor) {
+				return contributors, false
+			}
+		}
+		return append(contributors, contributor), true
+	})
+	return err
+}
 
-	return &result, err
+// RemoveContributor removes contributor from spec.contributors of the
+// Profile name, and returns false if it wasn't there.
+func (c *ProfileClient) RemoveContributor(name string, contributor Contributor) (bool, error) {
+	return c.updateContributors(name, func(contributors []Contributor) ([]Contributor, bool) {
+		kept := []Contributor{}
+		for _, existing := range contributors {
+			if !existing.matches(contributor) {
+				kept = append(kept, existing)
+			}
+		}
+		return kept, len(kept) != len(contributors)
+	})
 }
 
-func (c *ProfileClient) List(opts metav1.ListOptions) (*v1beta1.ProfileList, error) {
-	result := v1beta1.ProfileList{}
-	err := c.restClient.
+// GetOwners returns spec.owner and spec.owners of the Profile name.
+func (c *ProfileClient) GetOwners(name string) ([]rbacv1.Subject, error) {
+	raw, err := c.restClient.
 		Get().
 		Resource(Profiles).
-		VersionedParams(&opts, scheme.ParameterCodec).
+		Name(name).
 		Do().
-		Into(&result)
+		Raw()
+	if err != nil {
+		return nil, err
+	}
+	profile := struct {
+		Spec struct {
+			Owner  rbacv1.Subject   `json:"owner"`
+			Owners []rbacv1.Subject `json:"owners"`
+		} `json:"spec"`
+	}{}
+	if err := json.Unmarshal(raw, &profile); err != nil {
+		return nil, err
+	}
+	return append([]rbacv1.Subject{profile.Spec.Owner}, profile.Spec.Owners...), nil
+}
 
-	return &result, err
+// TransferOwner replaces spec.owner of the Profile name with owner, if it
+// is still from. The profile controller then updates the namespace
+// annotation, the RoleBinding and the AuthorizationPolicy of the owner.
+func (c *ProfileClient) TransferOwner(name string, from string, owner rbacv1.Subject) error {
+	_, err := c.updateSpec(name, func(spec map[string]interface{}) (bool, error) {
+		current := rbacv1.Subject{}
+		if value, ok := spec["owner"]; ok {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return false, err
+			}
+			if err := json.Unmarshal(data, &current); err != nil {
+				return false, err
+			}
+		}
+		if current.Name != from {
+			return false, fmt.Errorf("profile %v is owned by %v, not %v", name, current.Name, from)
+		}
+		if current.Kind == owner.Kind && current.Name == owner.Name {
+			return false, nil
+		}
+		spec["owner"] = owner
+		return true, nil
+	})
+	return err
 }
 
-func (c *ProfileClient) Update(profile *v1beta1.Profile) (*v1beta1.Profile, error) {
-	result := v1beta1.Profile{}
-	err := c.restClient.
-		Put().
-		Resource(Profiles).
-		Body(profile).
-		Do().
-		Into(&result)
+// updateContributors applies update to spec.contributors of the Profile
+// name.
+func (c *ProfileClient) updateContributors(name string,
+	update func([]Contributor) ([]Contributor, bool)) (bool, error) {
+	return c.updateSpec(name, func(spec map[string]interface{}) (bool, error) {
+		contributors := []Contributor{}
+		if value, ok := spec["contributors"]; ok {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return false, err
+			}
+			if err := json.Unmarshal(data, &contributors); err != nil {
+				return false, err
+			}
+		}
+		contributors, changed := update(contributors)
+		if changed {
+			spec["contributors"] = contributors
+		}
+		return changed, nil
+	})
+}
+
+// updateSpec applies update to the spec of the Profile name. The update is
+// retried when the Profile changed in the meantime.
+func (c *ProfileClient) updateSpec(name string,
+	update func(map[string]interface{}) (bool, error)) (bool, error) {
+	const maxRetries = 5
+	for retry := 0; ; retry++ {
+		raw, err := c.restClient.
+			Get().
+			Resource(Profiles).
+			Name(name).
+			Do().
+			Raw()
+		if err != nil {
+			return false, err
+		}
+		profile := map[string]interface{}{}
+		if err := json.Unmarshal(raw, &profile); err != nil {
+			return false, err
+		}
+		spec, _ := profile["spec"].(map[string]interface{})
+		if spec == nil {
+			spec = map[string]interface{}{}
+		}
 
-	return &result, err
+		changed, err := update(spec)
+		if err != nil || !changed {
+			return false, err
+		}
+		profile["spec"] = spec
+		body, err := json.Marshal(profile)
+		if err != nil {
+			return false, err
+		}
+		err = c.restClient.
+			Put().
+			Resource(Profiles).
+			Name(name).
+			Body(body).
+			Do().
+			Error()
+		if apierrors.IsConflict(err) && retry < maxRetries {
+			continue
+		}
+		return err == nil, err
+	}
 }
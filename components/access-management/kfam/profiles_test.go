@@ -0,0 +1,284 @@
+package kfam
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// newTestProfileClient serves a single Profile and records the last PUT.
+func newTestProfileClient(t *testing.T, profile string, conflicts int) (*ProfileClient, *string, func()) {
+	stored := profile
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/kubeflow.org/v1/profiles/kubeflow-user" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte(stored))
+		case http.MethodPut:
+			if conflicts > 0 {
+				conflicts--
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Conflict","code":409}`))
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			stored = string(body)
+			w.Write(body)
+		}
+	}))
+
+	restClient, err := rest.RESTClientFor(&rest.Config{
+		Host:    server.URL,
+		APIPath: "/apis",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion:         &schema.GroupVersion{Group: "kubeflow.org", Version: "v1"},
+			NegotiatedSerializer: serializer.DirectCodecFactory{CodecFactory: scheme.Codecs},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unable to create the REST client: %v", err)
+	}
+	return &ProfileClient{restClient: restClient}, &stored, server.Close
+}
+
+func storedContributors(t *testing.T, stored string) []Contributor {
+	profile := struct {
+		Spec struct {
+			Owner        rbacv1.Subject `json:"owner"`
+			Contributors []Contributor  `json:"contributors"`
+			Unknown      string         `json:"unknownField"`
+		} `json:"spec"`
+	}{}
+	if err := json.Unmarshal([]byte(stored), &profile); err != nil {
+		t.Fatalf("Invalid stored profile: %v", err)
+	}
+	if profile.Spec.Owner.Name != "owner@example.com" || profile.Spec.Unknown != "kept" {
+		t.Errorf("Expected the other fields of the profile to be kept, got %s", stored)
+	}
+	return profile.Spec.Contributors
+}
+
+func TestContributorFromBinding(t *testing.T) {
+	binding := getBindingObject("user@example.com")
+	contributor, err := ContributorFromBinding(binding)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if contributor.Name != "user@example.com" || contributor.Kind != rbacv1.UserKind || contributor.Role != "edit" {
+		t.Errorf("Unexpected contributor %+v", contributor)
+	}
+
+	binding.RoleRef.Name = "owner"
+	if _, err := ContributorFromBinding(binding); err == nil {
+		t.Errorf("Expected an error for an unknown role")
+	}
+}
+
+func TestUpdateContributors(t *testing.T) {
+	client, stored, stop := newTestProfileClient(t, `{
+		"apiVersion": "kubeflow.org/v1",
+		"kind": "Profile",
+		"metadata": {"name": "kubeflow-user", "resourceVersion": "1"},
+		"spec": {"owner": {"kind": "User", "name": "owner@example.com"}, "unknownField": "kept"}
+	}`, 1)
+	defer stop()
+
+	alice := Contributor{Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice@example.com"}, Role: "edit"}
+	bob := Contributor{Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "bob@example.com"}, Role: "view"}
+
+	// The first update is retried after a conflict.
+	if err := client.AddContributor("kubeflow-user", alice); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := client.AddContributor("kubeflow-user", bob); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := client.AddContributor("kubeflow-user", alice); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if contributors := storedContributors(t, *stored); len(contributors) != 2 {
+		t.Errorf("Expected 2 contributors, got %+v", contributors)
+	}
+
+	removed, err := client.RemoveContributor("kubeflow-user", alice)
+	if err != nil || !removed {
+		t.Fatalf("Expected alice to be removed, got %v, %v", removed, err)
+	}
+	removed, err = client.RemoveContributor("kubeflow-user", alice)
+	if err != nil || removed {
+		t.Errorf("Expected alice to be gone, got %v, %v", removed, err)
+	}
+	contributors := storedContributors(t, *stored)
+	if len(contributors) != 1 || !contributors[0].matches(bob) {
+		t.Errorf("Expected only bob to be left, got %+v", contributors)
+	}
+}
+
+func TestIsOwnerOrAdmin(t *testing.T) {
+	client, _, stop := newTestProfileClient(t, `{
+		"apiVersion": "kubeflow.org/v1",
+		"kind": "Profile",
+		"metadata": {"name": "kubeflow-user"},
+		"spec": {
+			"owner": {"kind": "User", "name": "owner@example.com"},
+			"owners": [{"kind": "User", "name": "deputy@example.com"}, {"kind": "Group", "name": "team-a"}]
+		}
+	}`, 0)
+	defer stop()
+	kfam := &KfamV1Alpha1Client{
+		profileClient: client,
+		clusterAdmin:  []string{"admin@example.com"},
+		groupsHeader:  "kubeflow-groups",
+	}
+
+	header := http.Header{}
+	header.Set("kubeflow-groups", "team-b, team-a")
+	for _, test := range []struct {
+		user   string
+		groups []string
+		want   bool
+	}{
+		{"owner@example.com", nil, true},
+		{"deputy@example.com", nil, true},
+		{"admin@example.com", nil, true},
+		{"member@example.com", kfam.getUserGroups(header), true},
+		{"member@example.com", []string{"team-b"}, false},
+		{"team-a", nil, false},
+	} {
+		if got := kfam.isOwnerOrAdmin(test.user, test.groups, "kubeflow-user"); got != test.want {
+			t.Errorf("isOwnerOrAdmin(%q, %v) = %v, want %v", test.user, test.groups, got, test.want)
+		}
+	}
+}
+
+func TestTransferProfileOwner(t *testing.T) {
+	client, stored, stop := newTestProfileClient(t, `{
+		"apiVersion": "kubeflow.org/v1",
+		"kind": "Profile",
+		"metadata": {"name": "kubeflow-user", "resourceVersion": "1"},
+		"spec": {
+			"owner": {"kind": "User", "name": "owner@example.com"},
+			"owners": [{"kind": "User", "name": "deputy@example.com"}],
+			"unknownField": "kept"
+		}
+	}`, 1)
+	defer stop()
+	router := NewRouter(&KfamV1Alpha1Client{
+		profileClient: client,
+		clusterAdmin:  []string{"admin@example.com"},
+		userIdHeader:  "kubeflow-userid",
+	})
+	transfer := func(user string, body string) int {
+		request := httptest.NewRequest(http.MethodPut, "/kfam/v1/profiles/kubeflow-user/owner", strings.NewReader(body))
+		request.Header.Set("kubeflow-userid", user)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	// Co-owners can't transfer the profile.
+	if code := transfer("deputy@example.com", `{"name": "deputy@example.com"}`); code != http.StatusForbidden {
+		t.Errorf("Expected the co-owner to be forbidden, got %v", code)
+	}
+	if code := transfer("owner@example.com", `{"kind": "Group", "name": "team-a"}`); code != http.StatusForbidden {
+		t.Errorf("Expected a group owner to be rejected, got %v", code)
+	}
+	// The first update is retried after a conflict.
+	if code := transfer("owner@example.com", `{"name": "deputy@example.com"}`); code != http.StatusOK {
+		t.Fatalf("Expected the owner to transfer the profile, got %v", code)
+	}
+	profile := struct {
+		Spec struct {
+			Owner   rbacv1.Subject `json:"owner"`
+			Unknown string         `json:"unknownField"`
+		} `json:"spec"`
+	}{}
+	if err := json.Unmarshal([]byte(*stored), &profile); err != nil {
+		t.Fatal(err)
+	}
+	if profile.Spec.Owner.Name != "deputy@example.com" || profile.Spec.Owner.Kind != rbacv1.UserKind ||
+		profile.Spec.Unknown != "kept" {
+		t.Errorf("Expected the owner to be replaced, got %s", *stored)
+	}
+
+	// The previous owner lost the right to transfer it, the admins keep it.
+	if code := transfer("owner@example.com", `{"name": "owner@example.com"}`); code != http.StatusForbidden {
+		t.Errorf("Expected the previous owner to be forbidden, got %v", code)
+	}
+	if code := transfer("admin@example.com", `{"name": "owner@example.com"}`); code != http.StatusOK {
+		t.Errorf("Expected the admin to transfer the profile, got %v", code)
+	}
+}
+
+func TestCreateProfile(t *testing.T) {
+	var created []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/apis/kubeflow.org/v1/profiles" {
+			http.NotFound(w, r)
+			return
+		}
+		created, _ = ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(created)
+	}))
+	defer server.Close()
+	restClient, err := rest.RESTClientFor(&rest.Config{
+		Host:    server.URL,
+		APIPath: "/apis",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion:         &schema.GroupVersion{Group: "kubeflow.org", Version: "v1"},
+			NegotiatedSerializer: serializer.DirectCodecFactory{CodecFactory: scheme.Codecs},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unable to create the REST client: %v", err)
+	}
+	router := NewRouter(&KfamV1Alpha1Client{profileClient: &ProfileClient{restClient: restClient}})
+
+	// The fields of v1 are kept, and a v1beta1 Profile is created as v1.
+	request := httptest.NewRequest(http.MethodPost, "/kfam/v1/profiles", strings.NewReader(`{
+		"apiVersion": "kubeflow.org/v1beta1",
+		"kind": "Profile",
+		"metadata": {"name": "kubeflow-user"},
+		"spec": {
+			"owner": {"kind": "User", "name": "owner@example.com"},
+			"owners": [{"kind": "User", "name": "deputy@example.com"}],
+			"contributors": [{"kind": "User", "name": "user@example.com", "role": "edit"}],
+			"template": "standard"
+		}
+	}`))
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected the profile to be created, got %v: %s", response.Code, response.Body)
+	}
+	profile := struct {
+		APIVersion string `json:"apiVersion"`
+		Spec       struct {
+			Owners       []rbacv1.Subject `json:"owners"`
+			Contributors []Contributor    `json:"contributors"`
+			Template     string           `json:"template"`
+		} `json:"spec"`
+	}{}
+	if err := json.Unmarshal(created, &profile); err != nil {
+		t.Fatal(err)
+	}
+	if profile.APIVersion != "kubeflow.org/v1" || len(profile.Spec.Owners) != 1 ||
+		len(profile.Spec.Contributors) != 1 || profile.Spec.Template != "standard" {
+		t.Errorf("Expected the v1 fields of the profile to be kept, got %s", created)
+	}
+}
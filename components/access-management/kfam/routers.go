@@ -67,6 +67,13 @@ func NewRouter(kfamV1Alpha1 KfamV1Alpha1Interface) *mux.Router {
 			kfamV1Alpha1.DeleteProfile,
 		},
 
+		Route{
+			"TransferProfileOwner",
+			strings.ToUpper("Put"),
+			"/kfam/v1/profiles/{profile}/owner",
+			kfamV1Alpha1.TransferProfileOwner,
+		},
+
 		Route{
 			"ReadBinding",
 			strings.ToUpper("Get"),
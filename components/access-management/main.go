@@ -18,7 +18,7 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 
 	"github.com/kubeflow/kubeflow/components/access-management/kfam"
-	profile "github.com/kubeflow/kubeflow/components/access-management/pkg/apis/kubeflow/v1beta1"
+	profile "github.com/kubeflow/kubeflow/components/access-management/pkg/apis/kubeflow/v1"
 
 	istioSecurityClient "istio.io/client-go/pkg/apis/security/v1beta1"
 )
@@ -30,6 +30,10 @@ const USERIDHEADER = "userid-header"
 // set this parameter to specify header value prefix (if any) before user id.
 const USERIDPREFIX = "userid-prefix"
 
+// set this parameter to specify header key containing the groups of the user,
+// to give access to the profiles owned by them.
+const GROUPSHEADER = "groups-header"
+
 // set cluster admin user id here.
 const CLUSTERADMIN = "cluster-admin"
 
@@ -37,16 +41,18 @@ func main() {
 	log.Printf("Server started")
 	var userIdHeader string
 	var userIdPrefix string
+	var groupsHeader string
 	var clusterAdmin string
 	flag.StringVar(&userIdHeader, USERIDHEADER, "x-goog-authenticated-user-email", "Key of request header containing user id")
 	flag.StringVar(&userIdPrefix, USERIDPREFIX, "accounts.google.com:", "Request header user id common prefix")
+	flag.StringVar(&groupsHeader, GROUPSHEADER, "", "Key of request header containing the comma separated groups of the user")
 	flag.StringVar(&clusterAdmin, CLUSTERADMIN, "", "cluster admin")
 	flag.Parse()
 
 	profile.AddToScheme(scheme.Scheme)
 	istioSecurityClient.AddToScheme(scheme.Scheme)
 
-	profileClient, err := kfam.NewKfamClient(userIdHeader, userIdPrefix, clusterAdmin)
+	profileClient, err := kfam.NewKfamClient(userIdHeader, userIdPrefix, groupsHeader, clusterAdmin)
 	if err != nil {
 		log.Print(err)
 		panic(err)
@@ -14,42 +14,35 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-// NOTE: Boilerplate only.  Ignore this file.
-
-// Package v1alpha1 contains API Schema definitions for the kubeflow v1alpha1 API group
-// +k8s:openapi-gen=true
-// +k8s:deepcopy-gen=package,register
-// +k8s:conversion-gen=github.com/kubeflow/kubeflow/components/profile-controller/pkg/apis/kubeflow
-// +k8s:defaulter-gen=TypeMeta
+// Package v1 contains the API group and version of the Profiles, v1, their
+// storage version.
 // +groupName=kubeflow.org
-package v1beta1
+package v1
 
 import (
-	profilev1beta1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 const GroupName = "kubeflow.org"
-const GroupVersion = "v1beta1"
+const GroupVersion = "v1"
 
 var (
 	// SchemeGroupVersion is group version used to register these objects
 	SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: GroupVersion}
 
-	// SchemeBuilder is used to add go types to the GroupVersionKind scheme
+	// SchemeBuilder is used to register the options of the requests
 	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
 
 	// AddToScheme is required by pkg/client/...
 	AddToScheme = SchemeBuilder.AddToScheme
 )
 
+// addKnownTypes only registers the options of the requests. The Profiles are
+// read and written as raw JSON by the ProfileClient, so that none of the
+// fields of the storage version are dropped.
 func addKnownTypes(scheme *runtime.Scheme) error {
-	scheme.AddKnownTypes(SchemeGroupVersion,
-		&profilev1beta1.Profile{},
-		&profilev1beta1.ProfileList{},
-	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
 }
@@ -27,6 +27,9 @@ func (src *Notebook) ConvertTo(dstRaw conversion.Hub) error {
 	dst.Spec.Template.Spec = src.Spec.Template.Spec
 	dst.Status.ReadyReplicas = src.Status.ReadyReplicas
 	dst.Status.ContainerState = src.Status.ContainerState
+	dst.Spec.UpdatePolicy = nbv1beta1.NotebookUpdatePolicy(src.Spec.UpdatePolicy)
+	dst.Status.UpdateAvailable = src.Status.UpdateAvailable
+	dst.Status.UpdateMessage = src.Status.UpdateMessage
 	conditions := []nbv1beta1.NotebookCondition{}
 	for _, c := range src.Status.Conditions {
 		newc := nbv1beta1.NotebookCondition{
@@ -53,6 +56,9 @@ func (dst *Notebook) ConvertFrom(srcRaw conversion.Hub) error {
 	dst.Spec.Template.Spec = src.Spec.Template.Spec
 	dst.Status.ReadyReplicas = src.Status.ReadyReplicas
 	dst.Status.ContainerState = src.Status.ContainerState
+	dst.Spec.UpdatePolicy = NotebookUpdatePolicy(src.Spec.UpdatePolicy)
+	dst.Status.UpdateAvailable = src.Status.UpdateAvailable
+	dst.Status.UpdateMessage = src.Status.UpdateMessage
 	conditions := []NotebookCondition{}
 	for _, c := range src.Status.Conditions {
 		newc := NotebookCondition{
@@ -27,8 +27,28 @@ import (
 type NotebookSpec struct {
 	// Template describes the notebooks that will be created.
 	Template NotebookTemplateSpec `json:"template,omitempty"`
+	// UpdatePolicy decides when the Notebook is restarted to pick up a newer
+	// image or changed PodDefaults. Defaults to Manual.
+	// +optional
+	UpdatePolicy NotebookUpdatePolicy `json:"updatePolicy,omitempty"`
 }
 
+// NotebookUpdatePolicy describes when a Notebook picks up available updates.
+// +kubebuilder:validation:Enum=Manual;OnNextStart;Immediate
+type NotebookUpdatePolicy string
+
+const (
+	// UpdatePolicyManual only reports the updates in the status.
+	UpdatePolicyManual NotebookUpdatePolicy = "Manual"
+	// UpdatePolicyOnNextStart picks up the updates the next time the
+	// Notebook is started, e.g. after it was culled. The controller does
+	// the same as with Manual: the pod of a stopped Notebook is created
+	// again when it is started, which picks up the updates.
+	UpdatePolicyOnNextStart NotebookUpdatePolicy = "OnNextStart"
+	// UpdatePolicyImmediate restarts the Notebook once all its kernels are idle.
+	UpdatePolicyImmediate NotebookUpdatePolicy = "Immediate"
+)
+
 type NotebookTemplateSpec struct {
 	Spec corev1.PodSpec `json:"spec,omitempty"`
 }
@@ -41,6 +61,13 @@ type NotebookStatus struct {
 	ReadyReplicas int32 `json:"readyReplicas"`
 	// ContainerState is the state of underlying container.
 	ContainerState corev1.ContainerState `json:"containerState"`
+	// UpdateAvailable is true when the registry has a newer digest for the
+	// image of the Notebook, or the PodDefaults applied to it have changed.
+	// +optional
+	UpdateAvailable bool `json:"updateAvailable,omitempty"`
+	// UpdateMessage describes the available update.
+	// +optional
+	UpdateMessage string `json:"updateMessage,omitempty"`
 }
 
 type NotebookCondition struct {
@@ -5,8 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
-	"strconv"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -17,28 +15,15 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/kubeflow/kubeflow/components/notebook-controller/api/v1beta1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/config"
 	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/metrics"
 )
 
-// The constants with name 'DEFAULT_{ENV_Var}' are the default values to be
-// used, if the respective ENV vars are not present.
-// All the time numbers correspond to minutes.
-
-const DEFAULT_CULL_IDLE_TIME = "1440" // One day
-const DEFAULT_IDLENESS_CHECK_PERIOD = "1"
-const DEFAULT_ENABLE_CULLING = "false"
-const DEFAULT_CLUSTER_DOMAIN = "cluster.local"
-const DEFAULT_DEV = "false"
-
-var CULL_IDLE_TIME = 0
-var ENABLE_CULLING = false
-var IDLENESS_CHECK_PERIOD = 0
-var CLUSTER_DOMAIN = ""
-var DEV = false
-
 // When a Resource should be stopped/culled, then the controller should add this
 // annotation in the Resource's Metadata. Then, inside the reconcile loop,
 // the controller must check if this annotation is set and then apply the
@@ -73,12 +58,22 @@ type CullingReconciler struct {
 	Log     logr.Logger
 	Scheme  *runtime.Scheme
 	Metrics *metrics.Metrics
+	// Config holds the configuration of the controller. Culling can be
+	// enabled and disabled while the controller is running.
+	Config *config.Store
+	// NamespaceFilter, if set, restricts the namespaces this instance culls.
+	NamespaceFilter *NamespaceFilter
 }
 
 func (r *CullingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := r.Log.WithValues("culler", req.NamespacedName)
 	log.Info("Reconciliation loop started")
 
+	cfg := r.Config.Get()
+	if !cfg.Culling.Enabled {
+		return ctrl.Result{}, nil
+	}
+
 	instance := &v1beta1.Notebook{}
 	err := r.Get(context.TODO(), req.NamespacedName, instance)
 	if err != nil && apierrs.IsNotFound(err) {
@@ -131,13 +126,13 @@ func (r *CullingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	}
 
 	// Check if culling period has passed (IDLENESS_CHECK_PERIOD ~ default 1 min)
-	if !cullingCheckPeriodHasPassed(instance.ObjectMeta, r.Log) {
+	if !cullingCheckPeriodHasPassed(instance.ObjectMeta, cfg, r.Log) {
 		log.Info("Not enough time has passed. Won't check for culling.")
-		return ctrl.Result{RequeueAfter: getRequeueTime()}, nil
+		return ctrl.Result{RequeueAfter: getRequeueTime(cfg)}, nil
 	}
 
 	// Update the LAST_ACTIVITY_ANNOTATION and LAST_ACTIVITY_CHECK_TIMESTAMP_ANNOTATION
-	updateNotebookLastActivityAnnotation(&instance.ObjectMeta, r.Log)
+	updateNotebookLastActivityAnnotation(&instance.ObjectMeta, cfg, r.Log)
 	updateLastCullingCheckTimestampAnnotation(&instance.ObjectMeta, r.Log)
 	// Always keep track of the last time we checked for culling
 	err = r.Update(ctx, instance)
@@ -146,7 +141,7 @@ func (r *CullingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	}
 
 	// Check if the Notebook needs to be stopped
-	if notebookIsIdle(instance.ObjectMeta, r.Log) {
+	if notebookIsIdle(instance.ObjectMeta, cfg, r.Log) {
 		log.Info(fmt.Sprintf(
 			"Notebook %s/%s needs culling. Updating Notebook CR Annotations...",
 			instance.Namespace, instance.Name))
@@ -158,25 +153,25 @@ func (r *CullingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			return ctrl.Result{}, err
 		}
 	}
-	return ctrl.Result{RequeueAfter: getRequeueTime()}, nil
+	return ctrl.Result{RequeueAfter: getRequeueTime(cfg)}, nil
 }
 
 // This function ensures that we run the culling checks every CULLING_CHECK_PERIOD
 // even if in the meantime an update/create/delete event occurs for a Notebook CR.
-func cullingCheckPeriodHasPassed(meta metav1.ObjectMeta, log logr.Logger) bool {
+func cullingCheckPeriodHasPassed(meta metav1.ObjectMeta, cfg *config.Config, log logr.Logger) bool {
 	if _, ok := meta.GetAnnotations()[LAST_ACTIVITY_CHECK_TIMESTAMP_ANNOTATION]; !ok {
 		log.Info("No last-activity-check-timestamp found in the CR. Won't check for culling")
 		return false
 	}
 	storedTimestamp, _ := time.Parse(time.RFC3339, meta.Annotations[LAST_ACTIVITY_CHECK_TIMESTAMP_ANNOTATION])
-	nextCullingCheck := storedTimestamp.Add(getRequeueTime())
+	nextCullingCheck := storedTimestamp.Add(getRequeueTime(cfg))
 	currentTime := time.Now()
 
 	return nextCullingCheck.Before(currentTime)
 }
 
 // Culling Logic
-func notebookIsIdle(meta metav1.ObjectMeta, log logr.Logger) bool {
+func notebookIsIdle(meta metav1.ObjectMeta, cfg *config.Config, log logr.Logger) bool {
 	// Being idle means that the Notebook can be culled/stopped
 	if meta.GetAnnotations() != nil {
 		if StopAnnotationIsSet(meta) {
@@ -191,7 +186,7 @@ func notebookIsIdle(meta metav1.ObjectMeta, log logr.Logger) bool {
 			return false
 		}
 
-		timeCap := LastActivity.Add(time.Duration(CULL_IDLE_TIME) * time.Minute)
+		timeCap := LastActivity.Add(time.Duration(cfg.Culling.IdleTime) * time.Minute)
 		if time.Now().After(timeCap) {
 			return true
 		}
@@ -199,17 +194,16 @@ func notebookIsIdle(meta metav1.ObjectMeta, log logr.Logger) bool {
 	return false
 }
 
-func getNotebookApiKernels(nm, ns string, log logr.Logger) []KernelStatus {
+func getNotebookApiKernels(nm, ns string, cfg *config.Config, log logr.Logger) []KernelStatus {
 	// Get the Kernels' status from the Server's `/api/kernels` endpoint
 	client := &http.Client{
 		Timeout: time.Second * 10,
 	}
 
-	domain := GetEnvDefault("CLUSTER_DOMAIN", DEFAULT_CLUSTER_DOMAIN)
 	url := fmt.Sprintf(
 		"http://%s.%s.svc.%s/notebook/%s/%s/api/kernels",
-		nm, ns, domain, ns, nm)
-	if GetEnvDefault("DEV", DEFAULT_DEV) != "false" {
+		nm, ns, cfg.ClusterDomain, ns, nm)
+	if cfg.Dev {
 		url = fmt.Sprintf(
 			"http://localhost:8001/api/v1/namespaces/%s/services/%s:http-%s/proxy/notebook/%s/%s/api/kernels",
 			ns, nm, nm, ns, nm)
@@ -255,11 +249,11 @@ func allKernelsAreIdle(kernels []KernelStatus, log logr.Logger) bool {
 }
 
 // Update LAST_ACTIVITY_ANNOTATION
-func updateNotebookLastActivityAnnotation(meta *metav1.ObjectMeta, log logr.Logger) {
+func updateNotebookLastActivityAnnotation(meta *metav1.ObjectMeta, cfg *config.Config, log logr.Logger) {
 
 	log.Info("Updating the last-activity annotation. Checking /api/kernels")
 	nm, ns := meta.GetName(), meta.GetNamespace()
-	kernels := getNotebookApiKernels(nm, ns, log)
+	kernels := getNotebookApiKernels(nm, ns, cfg, log)
 	if kernels == nil {
 		log.Info("Could not GET the kernels status. Will not update last-activity.")
 		return
@@ -381,75 +375,35 @@ func StopAnnotationIsSet(meta metav1.ObjectMeta) bool {
 	return false
 }
 
-// Some Utility Functions
-func GetEnvDefault(variable string, defaultVal string) string {
-	envVar := os.Getenv(variable)
-	if len(envVar) == 0 {
-		return defaultVal
-	}
-	return envVar
-}
-
 // Time / Frequency Utility functions
 func createTimestamp() string {
 	now := time.Now()
 	return now.Format(time.RFC3339)
 }
 
-func getRequeueTime() time.Duration {
+func getRequeueTime(cfg *config.Config) time.Duration {
 	// The frequency in which we check if the Pod needs culling
-	// Uses ENV var: IDLENESS_CHECK_PERIOD
-	return time.Duration(IDLENESS_CHECK_PERIOD) * time.Minute
-}
-
-func initGlobalVars() error {
-	log := logf.Log.WithName("Culler")
-
-	devMode := GetEnvDefault("DEV", DEFAULT_DEV)
-	if devMode == "true" {
-		DEV = true
-	}
-
-	idleTime := GetEnvDefault("CULL_IDLE_TIME", DEFAULT_CULL_IDLE_TIME)
-	realIdleTime, err := strconv.Atoi(idleTime)
-	if err != nil {
-		log.Info(fmt.Sprintf(
-			"CULL_IDLE_TIME should be Int. Got %s instead. Using default value.",
-			idleTime))
-		realIdleTime, _ = strconv.Atoi(DEFAULT_CULL_IDLE_TIME)
-	}
-	CULL_IDLE_TIME = realIdleTime
-
-	enableCulling := GetEnvDefault("ENABLE_CULLING", DEFAULT_ENABLE_CULLING)
-	if enableCulling == "true" {
-		ENABLE_CULLING = true
-	}
-
-	CLUSTER_DOMAIN = GetEnvDefault("CLUSTER_DOMAIN", DEFAULT_CLUSTER_DOMAIN)
-
-	cullPeriod := GetEnvDefault("IDLENESS_CHECK_PERIOD", DEFAULT_IDLENESS_CHECK_PERIOD)
-	period, err := strconv.Atoi(cullPeriod)
-	if err != nil {
-		return err
-	}
-	IDLENESS_CHECK_PERIOD = period
-
-	return nil
+	return time.Duration(cfg.Culling.CheckPeriod) * time.Minute
 }
 
 // SetupWithManager : Add the culling controller to the manager
 func (r *CullingReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
-	log := r.Log.WithValues("Culler", "setup")
-
-	if err := initGlobalVars(); err != nil {
-		log.Error(err, "Could not initialize the global variables")
-		return err
+	// Map function to check every Notebook when the configuration changes, so
+	// that enabling culling or shortening the idle time takes effect at once
+	mapConfigToRequests := func(object client.Object) []reconcile.Request {
+		return notebookRequests(mgr.GetClient(), r.NamespaceFilter, r.Log)
 	}
 
 	controller := ctrl.NewControllerManagedBy(mgr).
 		For(&v1beta1.Notebook{}).
+		Watches(
+			&source.Channel{Source: r.Config.Subscribe()},
+			handler.EnqueueRequestsFromMapFunc(mapConfigToRequests)).
 		Named("Culler")
+	if r.NamespaceFilter != nil {
+		controller.WithEventFilter(r.NamespaceFilter.Predicate(mgr.GetClient()))
+	}
 
 	err := controller.Complete(r)
 	if err != nil {
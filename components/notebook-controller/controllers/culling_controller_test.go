@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/config"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -253,8 +254,11 @@ func TestNotebookIsIdle(t *testing.T) {
 			for envVar, val := range c.env {
 				os.Setenv(envVar, val)
 			}
-			initGlobalVars()
-			if notebookIsIdle(c.meta, TestLogger) != c.result {
+			cfg, err := config.FromEnv()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if notebookIsIdle(c.meta, cfg, TestLogger) != c.result {
 				t.Errorf("ENV VAR: %+v\n", c.env)
 				t.Errorf("Wrong result for case object: %+v\n", c.meta)
 			}
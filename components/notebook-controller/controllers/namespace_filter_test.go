@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestNamespaceFilterManages(t *testing.T) {
+	canary := &corev1.Namespace{
+		ObjectMeta: v1.ObjectMeta{
+			Name:   "team-a",
+			Labels: map[string]string{"notebooks.kubeflow.org/canary": "true"},
+		},
+	}
+	stable := &corev1.Namespace{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "team-b",
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(canary, stable).Build()
+
+	tests := []struct {
+		name       string
+		namespaces string
+		selector   string
+		namespace  string
+		expected   bool
+	}{
+		{"no filter", "", "", "team-b", true},
+		{"listed namespace", "team-a,team-c", "", "team-a", true},
+		{"unlisted namespace", "team-a,team-c", "", "team-b", false},
+		{"selector matches", "", "notebooks.kubeflow.org/canary=true", "team-a", true},
+		{"selector does not match", "", "notebooks.kubeflow.org/canary=true", "team-b", false},
+		{"negated selector", "", "notebooks.kubeflow.org/canary!=true", "team-b", true},
+		{"missing namespace", "", "notebooks.kubeflow.org/canary!=true", "team-z", false},
+	}
+	for _, test := range tests {
+		f, err := NewNamespaceFilter(test.namespaces, test.selector, 1, 0)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if got := f.Manages(context.Background(), c, test.namespace); got != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestNamespaceFilterShards(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	namespaces := []string{"team-a", "team-b", "team-c", "team-d", "team-e", "team-f"}
+
+	shards := []*NamespaceFilter{}
+	for id := 0; id < 3; id++ {
+		f, err := NewNamespaceFilter("", "", 3, id)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := fmt.Sprintf("kubeflow-notebook-controller-shard-%d", id)
+		if got := f.LeaderElectionID("kubeflow-notebook-controller"); got != expected {
+			t.Errorf("expected leader election id %s, got %s", expected, got)
+		}
+		shards = append(shards, f)
+	}
+
+	// Every namespace must be managed by exactly one shard.
+	for _, ns := range namespaces {
+		owners := 0
+		for _, f := range shards {
+			if f.Manages(context.Background(), c, ns) {
+				owners++
+			}
+		}
+		if owners != 1 {
+			t.Errorf("namespace %s is managed by %d shards", ns, owners)
+		}
+	}
+}
+
+func TestNewNamespaceFilterValidation(t *testing.T) {
+	if _, err := NewNamespaceFilter("", "", 0, 0); err == nil {
+		t.Errorf("expected an error for a shard count of 0")
+	}
+	if _, err := NewNamespaceFilter("", "", 2, 2); err == nil {
+		t.Errorf("expected an error for an out of range shard id")
+	}
+	if _, err := NewNamespaceFilter("", "a in (", 1, 0); err == nil {
+		t.Errorf("expected an error for an invalid selector")
+	}
+	f, err := NewNamespaceFilter("", "", 1, -1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.LeaderElectionID("kubeflow-notebook-controller") != "kubeflow-notebook-controller" {
+		t.Errorf("an unsharded instance should keep the default leader election id")
+	}
+}
+
+func TestNamespaceFilterPredicate(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	f, err := NewNamespaceFilter("team-a", "", 1, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p := f.Predicate(c)
+	if !p.Generic(event.GenericEvent{}) {
+		t.Errorf("expected events without an object to be let through")
+	}
+	managed := &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "a", Namespace: "team-a"}}
+	unmanaged := &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "a", Namespace: "team-b"}}
+	if !p.Create(event.CreateEvent{Object: managed}) || p.Create(event.CreateEvent{Object: unmanaged}) {
+		t.Errorf("expected only the events of team-a to be let through")
+	}
+}
@@ -19,7 +19,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"reflect"
 	"strings"
 	"time"
@@ -27,6 +26,7 @@ import (
 	"github.com/go-logr/logr"
 	reconcilehelper "github.com/kubeflow/kubeflow/components/common/reconcilehelper"
 	"github.com/kubeflow/kubeflow/components/notebook-controller/api/v1beta1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/config"
 	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/metrics"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -51,6 +51,8 @@ const DefaultContainerPort = 8888
 const DefaultServingPort = 80
 const AnnotationRewriteURI = "notebooks.kubeflow.org/http-rewrite-uri"
 const AnnotationHeadersRequestSet = "notebooks.kubeflow.org/http-headers-request-set"
+const AnnotationProbePath = "notebooks.kubeflow.org/probe-path"
+const AnnotationServerType = "notebooks.kubeflow.org/server-type"
 
 const PrefixEnvVar = "NB_PREFIX"
 
@@ -77,13 +79,23 @@ type NotebookReconciler struct {
 	Scheme        *runtime.Scheme
 	Metrics       *metrics.Metrics
 	EventRecorder record.EventRecorder
+	// Config holds the configuration of the controller, which can change
+	// while the controller is running.
+	Config *config.Store
+	// NamespaceFilter, if set, restricts the namespaces this instance manages.
+	NamespaceFilter *NamespaceFilter
+	// Resolver, if set, is asked for the digests of the Notebook images when
+	// checking for updates.
+	Resolver DigestResolver
 }
 
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;patch
 // +kubebuilder:rbac:groups=core,resources=services,verbs="*"
 // +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs="*"
 // +kubebuilder:rbac:groups=kubeflow.org,resources=notebooks;notebooks/status;notebooks/finalizers,verbs="*"
+// +kubebuilder:rbac:groups=kubeflow.org,resources=poddefaults,verbs=get;list;watch
 // +kubebuilder:rbac:groups="networking.istio.io",resources=virtualservices,verbs="*"
 
 func (r *NotebookReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -135,8 +147,12 @@ func (r *NotebookReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, nil
 	}
 
+	// Use the same configuration for the whole reconciliation, even if it
+	// is reloaded in the meantime.
+	cfg := r.Config.Get()
+
 	// Reconcile StatefulSet
-	ss := generateStatefulSet(instance)
+	ss := generateStatefulSet(instance, cfg)
 	if err := ctrl.SetControllerReference(instance, ss, r.Scheme); err != nil {
 		return ctrl.Result{}, err
 	}
@@ -200,8 +216,8 @@ func (r *NotebookReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	}
 
 	// Reconcile virtual service if we use ISTIO.
-	if os.Getenv("USE_ISTIO") == "true" {
-		err = r.reconcileVirtualService(instance)
+	if cfg.Istio.Enabled {
+		err = r.reconcileVirtualService(instance, cfg)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
@@ -215,13 +231,22 @@ func (r *NotebookReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, err
 	}
 
+	if cfg.Updates.Enabled {
+		r.checkForUpdates(ctx, instance, foundPod, cfg, log)
+	} else {
+		instance.Status.UpdateAvailable, instance.Status.UpdateMessage = false, ""
+	}
+
 	// Update Notebook CR status
 	err = updateNotebookStatus(r, instance, foundStateful, foundPod, req)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	if !cfg.Updates.Enabled {
+		return ctrl.Result{}, nil
+	}
+	return r.applyUpdate(ctx, instance, foundStateful, foundPod, cfg, log)
 }
 
 func updateNotebookStatus(r *NotebookReconciler, nb *v1beta1.Notebook,
@@ -251,6 +276,9 @@ func createNotebookStatus(r *NotebookReconciler, nb *v1beta1.Notebook,
 		Conditions:     make([]v1beta1.NotebookCondition, 0),
 		ReadyReplicas:  sts.Status.ReadyReplicas,
 		ContainerState: corev1.ContainerState{},
+		// Computed by checkForUpdates.
+		UpdateAvailable: nb.Status.UpdateAvailable,
+		UpdateMessage:   nb.Status.UpdateMessage,
 	}
 
 	// Update the status based on the Pod's status
@@ -358,7 +386,53 @@ func setPrefixEnvVar(instance *v1beta1.Notebook, container *corev1.Container) {
 	})
 }
 
-func generateStatefulSet(instance *v1beta1.Notebook) *appsv1.StatefulSet {
+// setProbes adds a readiness and a startup probe to the Notebook container,
+// so that the Notebook only becomes Ready once the server answers requests.
+// Containers that define a readiness or startup probe are left untouched. No
+// liveness probe is added, since restarting a busy server kills its kernels.
+func setProbes(instance *v1beta1.Notebook, container *corev1.Container, cfg *config.Config) {
+	if !cfg.Probes.Enabled || container.ReadinessProbe != nil || container.StartupProbe != nil {
+		return
+	}
+
+	handler := corev1.ProbeHandler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Path:   probePath(instance, cfg),
+			Port:   intstr.FromInt(int(container.Ports[0].ContainerPort)),
+			Scheme: corev1.URISchemeHTTP,
+		},
+	}
+	container.ReadinessProbe = &corev1.Probe{
+		ProbeHandler:     handler,
+		TimeoutSeconds:   5,
+		PeriodSeconds:    10,
+		SuccessThreshold: 1,
+		FailureThreshold: 3,
+	}
+	container.StartupProbe = &corev1.Probe{
+		ProbeHandler:     handler,
+		TimeoutSeconds:   5,
+		PeriodSeconds:    10,
+		SuccessThreshold: 1,
+		FailureThreshold: cfg.Probes.StartupFailureThreshold,
+	}
+}
+
+// probePath returns the path probed to know if the Notebook server is up. It
+// is taken from the AnnotationProbePath annotation, then from the server type
+// of the Notebook, and defaults to the Jupyter API under NB_PREFIX.
+func probePath(instance *v1beta1.Notebook, cfg *config.Config) string {
+	annotations := instance.ObjectMeta.Annotations
+	if path := annotations[AnnotationProbePath]; path != "" {
+		return path
+	}
+	if path, ok := cfg.Probes.ServerTypePaths[annotations[AnnotationServerType]]; ok {
+		return path
+	}
+	return "/notebook/" + instance.Namespace + "/" + instance.Name + "/api"
+}
+
+func generateStatefulSet(instance *v1beta1.Notebook, cfg *config.Config) *appsv1.StatefulSet {
 	replicas := int32(1)
 	if metav1.HasAnnotation(instance.ObjectMeta, "kubeflow-resource-stopped") {
 		replicas = 0
@@ -408,7 +482,7 @@ func generateStatefulSet(instance *v1beta1.Notebook) *appsv1.StatefulSet {
 	if container.WorkingDir == "" {
 		container.WorkingDir = "/home/jovyan"
 	}
-	if container.Ports == nil {
+	if len(container.Ports) == 0 {
 		container.Ports = []corev1.ContainerPort{
 			{
 				ContainerPort: DefaultContainerPort,
@@ -419,12 +493,13 @@ func generateStatefulSet(instance *v1beta1.Notebook) *appsv1.StatefulSet {
 	}
 
 	setPrefixEnvVar(instance, container)
+	setProbes(instance, container, cfg)
 
 	// For some platforms (like OpenShift), adding fsGroup: 100 is troublesome.
 	// This allows for those platforms to bypass the automatic addition of the fsGroup
 	// and will allow for the Pod Security Policy controller to make an appropriate choice
 	// https://github.com/kubernetes-sigs/controller-runtime/issues/4617
-	if value, exists := os.LookupEnv("ADD_FSGROUP"); !exists || value == "true" {
+	if cfg.AddFSGroup {
 		if podSpec.SecurityContext == nil {
 			fsGroup := DefaultFSGroup
 			podSpec.SecurityContext = &corev1.PodSecurityContext{
@@ -439,7 +514,7 @@ func generateService(instance *v1beta1.Notebook) *corev1.Service {
 	// Define the desired Service object
 	port := DefaultContainerPort
 	containerPorts := instance.Spec.Template.Spec.Containers[0].Ports
-	if containerPorts != nil {
+	if len(containerPorts) > 0 {
 		port = int(containerPorts[0].ContainerPort)
 	}
 	svc := &corev1.Service{
@@ -468,10 +543,9 @@ func virtualServiceName(kfName string, namespace string) string {
 	return fmt.Sprintf("notebook-%s-%s", namespace, kfName)
 }
 
-func generateVirtualService(instance *v1beta1.Notebook) (*unstructured.Unstructured, error) {
+func generateVirtualService(instance *v1beta1.Notebook, cfg *config.Config) (*unstructured.Unstructured, error) {
 	name := instance.Name
 	namespace := instance.Namespace
-	clusterDomain := "cluster.local"
 	prefix := fmt.Sprintf("/notebook/%s/%s/", namespace, name)
 
 	// unpack annotations from Notebook resource
@@ -486,10 +560,7 @@ func generateVirtualService(instance *v1beta1.Notebook) (*unstructured.Unstructu
 		rewrite = annotations[AnnotationRewriteURI]
 	}
 
-	if clusterDomainFromEnv, ok := os.LookupEnv("CLUSTER_DOMAIN"); ok {
-		clusterDomain = clusterDomainFromEnv
-	}
-	service := fmt.Sprintf("%s.%s.svc.%s", name, namespace, clusterDomain)
+	service := fmt.Sprintf("%s.%s.svc.%s", name, namespace, cfg.ClusterDomain)
 
 	vsvc := &unstructured.Unstructured{}
 	vsvc.SetAPIVersion("networking.istio.io/v1alpha3")
@@ -497,20 +568,12 @@ func generateVirtualService(instance *v1beta1.Notebook) (*unstructured.Unstructu
 	vsvc.SetName(virtualServiceName(name, namespace))
 	vsvc.SetNamespace(namespace)
 
-	istioHost := os.Getenv("ISTIO_HOST")
-	if len(istioHost) == 0 {
-		istioHost = "*"
-	}
-	if err := unstructured.SetNestedStringSlice(vsvc.Object, []string{istioHost}, "spec", "hosts"); err != nil {
+	if err := unstructured.SetNestedStringSlice(vsvc.Object, []string{cfg.Istio.Host}, "spec", "hosts"); err != nil {
 		return nil, fmt.Errorf("Set .spec.hosts error: %v", err)
 
 	}
 
-	istioGateway := os.Getenv("ISTIO_GATEWAY")
-	if len(istioGateway) == 0 {
-		istioGateway = "kubeflow/kubeflow-gateway"
-	}
-	if err := unstructured.SetNestedStringSlice(vsvc.Object, []string{istioGateway},
+	if err := unstructured.SetNestedStringSlice(vsvc.Object, []string{cfg.Istio.Gateway},
 		"spec", "gateways"); err != nil {
 		return nil, fmt.Errorf("set .spec.gateways error: %v", err)
 	}
@@ -570,9 +633,9 @@ func generateVirtualService(instance *v1beta1.Notebook) (*unstructured.Unstructu
 
 }
 
-func (r *NotebookReconciler) reconcileVirtualService(instance *v1beta1.Notebook) error {
+func (r *NotebookReconciler) reconcileVirtualService(instance *v1beta1.Notebook, cfg *config.Config) error {
 	log := r.Log.WithValues("notebook", instance.Namespace)
-	virtualService, err := generateVirtualService(instance)
+	virtualService, err := generateVirtualService(instance, cfg)
 	if err != nil {
 		log.Info("Unable to generate VirtualService...", err)
 		return err
@@ -710,6 +773,11 @@ func (r *NotebookReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		}
 	}
 
+	// Map function to reconcile every Notebook when the configuration changes
+	mapConfigToRequests := func(object client.Object) []reconcile.Request {
+		return notebookRequests(mgr.GetClient(), r.NamespaceFilter, r.Log)
+	}
+
 	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&v1beta1.Notebook{}).
 		Owns(&appsv1.StatefulSet{}).
@@ -721,14 +789,22 @@ func (r *NotebookReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Watches(
 			&source.Kind{Type: &corev1.Event{}},
 			handler.EnqueueRequestsFromMapFunc(mapEventToRequest),
-			builder.WithPredicates(predNBEvents(r)))
-	// watch Istio virtual service
-	if os.Getenv("USE_ISTIO") == "true" {
+			builder.WithPredicates(predNBEvents(r))).
+		Watches(
+			&source.Channel{Source: r.Config.Subscribe()},
+			handler.EnqueueRequestsFromMapFunc(mapConfigToRequests))
+	// watch Istio virtual service. Enabling Istio in the configuration file
+	// while the controller is running creates the VirtualServices, but they
+	// are only watched after a restart.
+	if r.Config.Get().Istio.Enabled {
 		virtualService := &unstructured.Unstructured{}
 		virtualService.SetAPIVersion("networking.istio.io/v1alpha3")
 		virtualService.SetKind("VirtualService")
 		builder.Owns(virtualService)
 	}
+	if r.NamespaceFilter != nil {
+		builder.WithEventFilter(r.NamespaceFilter.Predicate(mgr.GetClient()))
+	}
 
 	err := builder.Complete(r)
 	if err != nil {
@@ -737,3 +813,24 @@ func (r *NotebookReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
 	return nil
 }
+
+// notebookRequests returns a reconciliation request for every Notebook
+// managed by this controller instance.
+func notebookRequests(c client.Client, filter *NamespaceFilter, log logr.Logger) []reconcile.Request {
+	notebooks := &v1beta1.NotebookList{}
+	if err := c.List(context.Background(), notebooks); err != nil {
+		log.Error(err, "unable to list Notebooks")
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, nb := range notebooks.Items {
+		if filter != nil && !filter.Manages(context.Background(), c, nb.Namespace) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: nb.Name, Namespace: nb.Namespace},
+		})
+	}
+	return requests
+}
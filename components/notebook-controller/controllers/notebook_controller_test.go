@@ -15,6 +15,7 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 
 	nbv1beta1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1beta1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/config"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
@@ -289,6 +290,97 @@ func TestCreateNotebookStatus(t *testing.T) {
 
 }
 
+func TestGenerateStatefulSetProbes(t *testing.T) {
+	cfg, err := config.FromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	userProbe := &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			Exec: &corev1.ExecAction{Command: []string{"true"}},
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		annotations    map[string]string
+		readinessProbe *corev1.Probe
+		ports          []corev1.ContainerPort
+		expectedPath   string
+		expectedProbe  *corev1.Probe
+	}{
+		{
+			name:         "Jupyter API under the prefix",
+			expectedPath: "/notebook/test-namespace/test-notebook/api",
+		},
+		{
+			name:         "Path from annotation",
+			annotations:  map[string]string{AnnotationProbePath: "/healthz"},
+			expectedPath: "/healthz",
+		},
+		{
+			name:         "Path from server type",
+			annotations:  map[string]string{AnnotationServerType: "group-two"},
+			expectedPath: "/",
+		},
+		{
+			name:         "Empty list of ports",
+			ports:        []corev1.ContainerPort{},
+			expectedPath: "/notebook/test-namespace/test-notebook/api",
+		},
+		{
+			name:           "User defined probe",
+			readinessProbe: userProbe,
+			expectedProbe:  userProbe,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			nb := &nbv1beta1.Notebook{
+				ObjectMeta: v1.ObjectMeta{
+					Name:        "test-notebook",
+					Namespace:   "test-namespace",
+					Annotations: test.annotations,
+				},
+				Spec: nbv1beta1.NotebookSpec{
+					Template: nbv1beta1.NotebookTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{
+								Name:           "test-notebook",
+								ReadinessProbe: test.readinessProbe,
+								Ports:          test.ports,
+							}},
+						},
+					},
+				},
+			}
+			container := generateStatefulSet(nb, cfg).Spec.Template.Spec.Containers[0]
+
+			if test.expectedProbe != nil {
+				if !reflect.DeepEqual(container.ReadinessProbe, test.expectedProbe) || container.StartupProbe != nil {
+					t.Errorf("Expected the user defined probes to be kept, got %v and %v",
+						container.ReadinessProbe, container.StartupProbe)
+				}
+				return
+			}
+			for _, probe := range []*corev1.Probe{container.ReadinessProbe, container.StartupProbe} {
+				if probe == nil || probe.HTTPGet == nil {
+					t.Fatalf("Expected an HTTP probe, got %v", probe)
+				}
+				if probe.HTTPGet.Path != test.expectedPath || probe.HTTPGet.Port.IntValue() != DefaultContainerPort {
+					t.Errorf("Expected a probe on %s:%d, got %s:%s", test.expectedPath,
+						DefaultContainerPort, probe.HTTPGet.Path, probe.HTTPGet.Port.String())
+				}
+			}
+			if container.StartupProbe.FailureThreshold != cfg.Probes.StartupFailureThreshold {
+				t.Errorf("Expected a startup failure threshold of %d, got %d",
+					cfg.Probes.StartupFailureThreshold, container.StartupProbe.FailureThreshold)
+			}
+		})
+	}
+}
+
 func createMockReconciler() *NotebookReconciler {
 	reconciler := &NotebookReconciler{
 		Scheme: runtime.NewScheme(),
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubeflow/kubeflow/components/notebook-controller/api/v1beta1"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/config"
+)
+
+// The admission webhook records every PodDefault it applied to a pod in an
+// annotation with the resourceVersion of the PodDefault.
+const (
+	PODDEFAULT_ANNOTATION_PREFIX  = "poddefault.admission.kubeflow.org/poddefault-"
+	PODDEFAULT_EXCLUDE_ANNOTATION = "poddefault.admission.kubeflow.org/exclude"
+)
+
+// RESTARTED_AT_ANNOTATION is set on the pod template of the StatefulSet to
+// restart the Notebook, like `kubectl rollout restart` does.
+const RESTARTED_AT_ANNOTATION = "kubectl.kubernetes.io/restartedAt"
+
+// DigestResolver returns the digest an image tag points to in its registry.
+type DigestResolver interface {
+	Digest(ctx context.Context, image string, maxAge time.Duration) (string, error)
+}
+
+// checkForUpdates sets status.updateAvailable and status.updateMessage by
+// comparing the running pod of the Notebook with its registries and with
+// the PodDefaults of its namespace. The previous status is kept while the
+// Notebook has no running pod.
+func (r *NotebookReconciler) checkForUpdates(ctx context.Context, nb *v1beta1.Notebook,
+	pod *corev1.Pod, cfg *config.Config, log logr.Logger) {
+	if pod.Name == "" || pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+		return
+	}
+
+	updates := r.imageUpdates(ctx, pod, cfg, log)
+	changes, err := r.podDefaultUpdates(ctx, pod)
+	if err != nil {
+		log.Error(err, "unable to check the PodDefaults of the Notebook")
+	}
+	updates = append(updates, changes...)
+
+	nb.Status.UpdateAvailable = len(updates) > 0
+	nb.Status.UpdateMessage = strings.Join(updates, "; ")
+}
+
+// imageUpdates returns a message for every container whose image tag points
+// to a newer digest than the one running. Only the containers that always
+// pull their image are checked, since the others would keep running the
+// cached image after a restart.
+func (r *NotebookReconciler) imageUpdates(ctx context.Context, pod *corev1.Pod,
+	cfg *config.Config, log logr.Logger) []string {
+	if r.Resolver == nil {
+		return nil
+	}
+	maxAge := time.Duration(cfg.Updates.CheckPeriod) * time.Minute
+
+	updates := []string{}
+	for _, container := range pod.Spec.Containers {
+		if container.ImagePullPolicy != corev1.PullAlways {
+			continue
+		}
+		running := runningDigest(pod, container.Name)
+		if running == "" {
+			continue
+		}
+		latest, err := r.Resolver.Digest(ctx, container.Image, maxAge)
+		if err != nil {
+			log.Error(err, "unable to resolve the digest of the image", "image", container.Image)
+			continue
+		}
+		if latest != running {
+			updates = append(updates, fmt.Sprintf("image %s has a newer digest %s", container.Image, latest))
+		}
+	}
+	return updates
+}
+
+// runningDigest returns the digest of the image the container is running,
+// from an imageID like docker.io/library/python@sha256:...
+func runningDigest(pod *corev1.Pod, container string) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != container {
+			continue
+		}
+		if i := strings.LastIndex(status.ImageID, "@"); i >= 0 {
+			return status.ImageID[i+1:]
+		}
+	}
+	return ""
+}
+
+// podDefaultUpdates lists the PodDefaults of the namespace of the pod and
+// compares them with the ones the webhook applied to it.
+func (r *NotebookReconciler) podDefaultUpdates(ctx context.Context, pod *corev1.Pod) ([]string, error) {
+	if pod.Annotations[PODDEFAULT_EXCLUDE_ANNOTATION] == "true" {
+		return nil, nil
+	}
+	podDefaults := &unstructured.UnstructuredList{}
+	podDefaults.SetAPIVersion("kubeflow.org/v1alpha1")
+	podDefaults.SetKind("PodDefaultList")
+	if err := r.List(ctx, podDefaults, client.InNamespace(pod.Namespace)); err != nil {
+		// Clusters without the admission webhook have no PodDefaults.
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return podDefaultChanges(pod, podDefaults.Items), nil
+}
+
+// podDefaultChanges returns a message for every PodDefault that was changed,
+// added or deleted since the pod was created.
+func podDefaultChanges(pod *corev1.Pod, podDefaults []unstructured.Unstructured) []string {
+	applied := map[string]string{}
+	for key, version := range pod.Annotations {
+		if strings.HasPrefix(key, PODDEFAULT_ANNOTATION_PREFIX) {
+			applied[strings.TrimPrefix(key, PODDEFAULT_ANNOTATION_PREFIX)] = version
+		}
+	}
+
+	changes := []string{}
+	for i := range podDefaults {
+		pd := &podDefaults[i]
+		version, wasApplied := applied[pd.GetName()]
+		delete(applied, pd.GetName())
+		matches := podDefaultMatches(pd, pod)
+
+		switch {
+		case wasApplied && !matches:
+			changes = append(changes, fmt.Sprintf("PodDefault %s no longer selects the Notebook", pd.GetName()))
+		case wasApplied && version != pd.GetResourceVersion():
+			changes = append(changes, fmt.Sprintf("PodDefault %s changed", pd.GetName()))
+		case !wasApplied && matches:
+			changes = append(changes, fmt.Sprintf("PodDefault %s was added", pd.GetName()))
+		}
+	}
+	for name := range applied {
+		changes = append(changes, fmt.Sprintf("PodDefault %s was deleted", name))
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// podDefaultMatches returns true if the selector of the PodDefault selects
+// the pod, like the admission webhook does.
+func podDefaultMatches(pd *unstructured.Unstructured, pod *corev1.Pod) bool {
+	selector := metav1.LabelSelector{}
+	if spec, ok, _ := unstructured.NestedMap(pd.Object, "spec", "selector"); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &selector); err != nil {
+			return false
+		}
+	}
+	s, err := metav1.LabelSelectorAsSelector(&selector)
+	if err != nil {
+		return false
+	}
+	return s.Matches(labels.Set(pod.Labels))
+}
+
+// applyUpdate restarts a running Notebook with the Immediate update policy
+// once all its kernels are idle. The other policies only report the update:
+// a stopped Notebook picks it up when it is started again.
+func (r *NotebookReconciler) applyUpdate(ctx context.Context, nb *v1beta1.Notebook, sts *appsv1.StatefulSet,
+	pod *corev1.Pod, cfg *config.Config, log logr.Logger) (ctrl.Result, error) {
+	result := ctrl.Result{RequeueAfter: time.Duration(cfg.Updates.CheckPeriod) * time.Minute}
+	if !nb.Status.UpdateAvailable || nb.Spec.UpdatePolicy != v1beta1.UpdatePolicyImmediate ||
+		StopAnnotationIsSet(nb.ObjectMeta) {
+		return result, nil
+	}
+	// Wait for a previous restart to complete.
+	if pod.Name == "" || pod.DeletionTimestamp != nil || sts.Status.UpdateRevision != sts.Status.CurrentRevision {
+		return result, nil
+	}
+
+	// Check the kernels again at the pace of the culler.
+	retry := ctrl.Result{RequeueAfter: time.Duration(cfg.Culling.CheckPeriod) * time.Minute}
+	kernels := getNotebookApiKernels(nb.Name, nb.Namespace, cfg, log)
+	if kernels == nil || !allKernelsAreIdle(kernels, log) {
+		log.Info("Postponing the update of the Notebook until its kernels are idle")
+		return retry, nil
+	}
+
+	log.Info("Restarting the Notebook to apply the update", "update", nb.Status.UpdateMessage)
+	patch := client.MergeFrom(sts.DeepCopy())
+	if sts.Spec.Template.Annotations == nil {
+		sts.Spec.Template.Annotations = map[string]string{}
+	}
+	sts.Spec.Template.Annotations[RESTARTED_AT_ANNOTATION] = time.Now().Format(time.RFC3339)
+	if err := r.Patch(ctx, sts, patch); err != nil {
+		log.Error(err, "unable to restart the StatefulSet")
+		return ctrl.Result{}, err
+	}
+	r.EventRecorder.Eventf(nb, corev1.EventTypeNormal, "Updating",
+		"Restarting to apply the update: %s", nb.Status.UpdateMessage)
+	return result, nil
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/config"
+)
+
+type fakeResolver map[string]string
+
+func (f fakeResolver) Digest(ctx context.Context, image string, maxAge time.Duration) (string, error) {
+	return f[image], nil
+}
+
+func newPodDefault(name, version string, selector map[string]interface{}) unstructured.Unstructured {
+	pd := unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"selector": selector},
+	}}
+	pd.SetName(name)
+	pd.SetResourceVersion(version)
+	return pd
+}
+
+func TestPodDefaultChanges(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{
+			Labels: map[string]string{"access-ml-pipeline": "true"},
+			Annotations: map[string]string{
+				PODDEFAULT_ANNOTATION_PREFIX + "access-ml-pipeline": "10",
+				PODDEFAULT_ANNOTATION_PREFIX + "gpu":                "20",
+				PODDEFAULT_ANNOTATION_PREFIX + "deleted":            "30",
+			},
+		},
+	}
+	selectsPod := map[string]interface{}{
+		"matchLabels": map[string]interface{}{"access-ml-pipeline": "true"},
+	}
+	podDefaults := []unstructured.Unstructured{
+		newPodDefault("access-ml-pipeline", "10", selectsPod),
+		newPodDefault("gpu", "21", map[string]interface{}{
+			"matchLabels": map[string]interface{}{"gpu": "true"},
+		}),
+		newPodDefault("new", "40", selectsPod),
+	}
+
+	expected := []string{
+		"PodDefault deleted was deleted",
+		"PodDefault gpu no longer selects the Notebook",
+		"PodDefault new was added",
+	}
+	if changes := podDefaultChanges(pod, podDefaults); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %v, got %v", expected, changes)
+	}
+
+	podDefaults = podDefaults[:1]
+	delete(pod.Annotations, PODDEFAULT_ANNOTATION_PREFIX+"gpu")
+	delete(pod.Annotations, PODDEFAULT_ANNOTATION_PREFIX+"deleted")
+	if changes := podDefaultChanges(pod, podDefaults); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v", changes)
+	}
+}
+
+func TestImageUpdates(t *testing.T) {
+	cfg, err := config.FromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	r := &NotebookReconciler{
+		Log: ctrl.Log,
+		Resolver: fakeResolver{
+			"jupyter:latest": "sha256:new",
+			"sidecar:v1":     "sha256:new",
+			"pinned:v1":      "sha256:new",
+		},
+	}
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "notebook", Image: "jupyter:latest", ImagePullPolicy: corev1.PullAlways},
+				{Name: "sidecar", Image: "sidecar:v1", ImagePullPolicy: corev1.PullIfNotPresent},
+				{Name: "pinned", Image: "pinned:v1", ImagePullPolicy: corev1.PullAlways},
+			},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "notebook", ImageID: "docker.io/library/jupyter@sha256:old"},
+				{Name: "sidecar", ImageID: "docker.io/library/sidecar@sha256:old"},
+				{Name: "pinned", ImageID: "docker.io/library/pinned@sha256:new"},
+			},
+		},
+	}
+
+	expected := []string{"image jupyter:latest has a newer digest sha256:new"}
+	if updates := r.imageUpdates(context.Background(), pod, cfg, r.Log); !reflect.DeepEqual(updates, expected) {
+		t.Errorf("Expected %v, got %v", expected, updates)
+	}
+}
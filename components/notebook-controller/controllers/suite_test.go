@@ -20,6 +20,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/config"
 	controllermetrics "github.com/kubeflow/kubeflow/components/notebook-controller/pkg/metrics"
 
 	. "github.com/onsi/ginkgo"
@@ -77,12 +78,16 @@ var _ = BeforeSuite(func() {
 	})
 	Expect(err).NotTo(HaveOccurred())
 
+	configStore, err := config.NewStore("", ctrl.Log.WithName("config"))
+	Expect(err).NotTo(HaveOccurred())
+
 	err = (&NotebookReconciler{
 		Client:        k8sManager.GetClient(),
 		Log:           ctrl.Log.WithName("controllers").WithName("notebook-controller"),
 		Scheme:        k8sManager.GetScheme(),
 		Metrics:       controllermetrics.NewMetrics(k8sManager.GetClient()),
 		EventRecorder: k8sManager.GetEventRecorderFor("notebook-controller"),
+		Config:        configStore,
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
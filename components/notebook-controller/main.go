@@ -35,7 +35,9 @@ import (
 	nbv1alpha1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1alpha1"
 	nbv1beta1 "github.com/kubeflow/kubeflow/components/notebook-controller/api/v1beta1"
 	"github.com/kubeflow/kubeflow/components/notebook-controller/controllers"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/config"
 	controller_metrics "github.com/kubeflow/kubeflow/components/notebook-controller/pkg/metrics"
+	"github.com/kubeflow/kubeflow/components/notebook-controller/pkg/registry"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -60,6 +62,9 @@ func main() {
 	var probeAddr string
 	var Burst int
 	var QPS int
+	var namespaces, namespaceSelector string
+	var shardCount, shardID int
+	var configFile string
 	var log = logf.Log.WithName("main")
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "probe-addr", ":8081", "The address the health endpoint binds to.")
@@ -69,6 +74,16 @@ func main() {
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
 	flag.IntVar(&Burst, "burst", 0, "If it's zero, the created RESTClient will use DefaultBurst")
 	flag.IntVar(&QPS, "qps", 0, "If it's zero, the created RESTClient will use DefaultQPS")
+	flag.StringVar(&namespaces, "namespaces", "",
+		"Comma separated list of namespaces to manage. If empty, all namespaces are managed.")
+	flag.StringVar(&namespaceSelector, "namespace-selector", "",
+		"Label selector for the namespaces to manage. If empty, all namespaces are managed.")
+	flag.IntVar(&shardCount, "shard-count", 1,
+		"Number of shards the managed namespaces are split into. Each shard runs its own leader election.")
+	flag.IntVar(&shardID, "shard-id", -1,
+		"Shard managed by this instance. If negative, it is taken from the ordinal of the pod hostname.")
+	flag.StringVar(&configFile, "config", "",
+		"Path of the configuration file, reloaded when it changes. If empty, the configuration is read from the environment.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -83,42 +98,72 @@ func main() {
 		cfg.QPS = float32(QPS)
 	}
 
+	configStore, err := config.NewStore(configFile, ctrl.Log.WithName("config"))
+	if err != nil {
+		setupLog.Error(err, "invalid configuration")
+		os.Exit(1)
+	}
+
+	namespaceFilter, err := controllers.NewNamespaceFilter(namespaces, namespaceSelector, shardCount, shardID)
+	if err != nil {
+		setupLog.Error(err, "invalid namespace filter")
+		os.Exit(1)
+	}
+	if namespaceFilter.Sharded() {
+		setupLog.Info("Running as a shard", "shard", namespaceFilter.ShardID, "shards", namespaceFilter.ShardCount)
+	}
+
 	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
 		Scheme:                  scheme,
 		MetricsBindAddress:      metricsAddr,
 		HealthProbeBindAddress:  probeAddr,
 		LeaderElection:          enableLeaderElection,
 		LeaderElectionNamespace: leaderElectionNamespace,
-		LeaderElectionID:        "kubeflow-notebook-controller",
+		LeaderElectionID:        namespaceFilter.LeaderElectionID("kubeflow-notebook-controller"),
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	if err := mgr.Add(configStore); err != nil {
+		setupLog.Error(err, "unable to watch the configuration file")
+		os.Exit(1)
+	}
+	if err := mgr.AddMetricsExtraHandler("/debug/config", configStore); err != nil {
+		setupLog.Error(err, "unable to serve the configuration")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.NotebookReconciler{
-		Client:        mgr.GetClient(),
-		Log:           ctrl.Log.WithName("controllers").WithName("Notebook"),
-		Scheme:        mgr.GetScheme(),
-		Metrics:       controller_metrics.NewMetrics(mgr.GetClient()),
-		EventRecorder: mgr.GetEventRecorderFor("notebook-controller"),
+		Client:          mgr.GetClient(),
+		Log:             ctrl.Log.WithName("controllers").WithName("Notebook"),
+		Scheme:          mgr.GetScheme(),
+		Metrics:         controller_metrics.NewMetrics(mgr.GetClient()),
+		EventRecorder:   mgr.GetEventRecorderFor("notebook-controller"),
+		Config:          configStore,
+		NamespaceFilter: namespaceFilter,
+		Resolver:        registry.NewResolver(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Notebook")
 		os.Exit(1)
 	} //+kubebuilder:scaffold:builder
 
-	if controllers.GetEnvDefault("ENABLE_CULLING", controllers.DEFAULT_ENABLE_CULLING) == "true" {
-		if err = (&controllers.CullingReconciler{
-			Client: mgr.GetClient(),
-			Log:    ctrl.Log.WithName("controllers").WithName("Culler"),
-			Scheme: mgr.GetScheme(),
-		}).SetupWithManager(mgr); err != nil {
-			setupLog.Error(err, "unable to create controller", "controller", "Culler")
-			os.Exit(1)
-		} //+kubebuilder:scaffold:builder
-	} else {
-		log.Info("Culling of idle Pods is Disabled. To enable it set the " +
-			"ENV Var 'ENABLE_CULLING=true'")
+	// The culler is always running, since culling can be enabled in the
+	// configuration file without restarting the controller.
+	if err = (&controllers.CullingReconciler{
+		Client:          mgr.GetClient(),
+		Log:             ctrl.Log.WithName("controllers").WithName("Culler"),
+		Scheme:          mgr.GetScheme(),
+		Config:          configStore,
+		NamespaceFilter: namespaceFilter,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Culler")
+		os.Exit(1)
+	} //+kubebuilder:scaffold:builder
+	if !configStore.Get().Culling.Enabled {
+		log.Info("Culling of idle Pods is Disabled. To enable it set " +
+			"'culling.enabled: true' in the configuration file or the ENV Var 'ENABLE_CULLING=true'")
 	}
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// APIVersion is the version of the configuration file format.
+	APIVersion = "notebooks.kubeflow.org/v1alpha1"
+	// Kind is the kind of the configuration file.
+	Kind = "NotebookControllerConfig"
+)
+
+// Default values, used when neither the configuration file nor the legacy
+// environment variables set a field. All times are in minutes.
+const (
+	DefaultIstioGateway        = "kubeflow/kubeflow-gateway"
+	DefaultIstioHost           = "*"
+	DefaultClusterDomain       = "cluster.local"
+	DefaultCullIdleTime        = 1440 // One day
+	DefaultIdlenessCheckPeriod = 1
+	// DefaultStartupFailureThreshold gives large images ten minutes to start.
+	DefaultStartupFailureThreshold = 60
+	DefaultUpdateCheckPeriod       = 60 // One hour
+)
+
+// Config is the configuration of the notebook controller.
+type Config struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	// Istio configures the VirtualService created for every Notebook.
+	Istio IstioConfig `json:"istio"`
+	// ClusterDomain is the DNS domain of the cluster.
+	ClusterDomain string `json:"clusterDomain"`
+	// AddFSGroup adds fsGroup: 100 to the security context of Notebook pods
+	// that don't define one. Platforms like OpenShift should disable it.
+	AddFSGroup bool `json:"addFSGroup"`
+	// Culling configures the stopping of idle Notebooks.
+	Culling CullingConfig `json:"culling"`
+	// Probes configures the probes added to the Notebook containers.
+	Probes ProbesConfig `json:"probes"`
+	// Updates configures the detection of newer images and changed
+	// PodDefaults for running Notebooks.
+	Updates UpdatesConfig `json:"updates"`
+	// Dev makes the culler reach the Notebooks through `kubectl proxy`.
+	Dev bool `json:"dev"`
+}
+
+// IstioConfig configures the Istio integration.
+type IstioConfig struct {
+	Enabled bool   `json:"enabled"`
+	Gateway string `json:"gateway"`
+	Host    string `json:"host"`
+}
+
+// CullingConfig configures the culling of idle Notebooks.
+type CullingConfig struct {
+	Enabled bool `json:"enabled"`
+	// IdleTime is the time in minutes after which an idle Notebook is stopped.
+	IdleTime int `json:"idleTime"`
+	// CheckPeriod is the time in minutes between two idleness checks.
+	CheckPeriod int `json:"checkPeriod"`
+}
+
+// ProbesConfig configures the readiness and startup probes added to the
+// Notebook containers that don't define their own.
+type ProbesConfig struct {
+	Enabled bool `json:"enabled"`
+	// ServerTypePaths maps the notebooks.kubeflow.org/server-type annotation
+	// to the path to probe. Other servers are probed at ${NB_PREFIX}/api.
+	ServerTypePaths map[string]string `json:"serverTypePaths"`
+	// StartupFailureThreshold is the number of failed startup probes, ten
+	// seconds apart, after which the container is restarted.
+	StartupFailureThreshold int32 `json:"startupFailureThreshold"`
+}
+
+// UpdatesConfig configures the detection of Notebook updates.
+type UpdatesConfig struct {
+	Enabled bool `json:"enabled"`
+	// CheckPeriod is the time in minutes between two checks of a Notebook.
+	// The digests returned by the registries are cached for as long.
+	CheckPeriod int `json:"checkPeriod"`
+}
+
+// FromEnv returns the configuration defined by the legacy environment
+// variables, falling back to the defaults for the unset ones.
+func FromEnv() (*Config, error) {
+	fsGroup, fsGroupSet := os.LookupEnv("ADD_FSGROUP")
+	probes, probesSet := os.LookupEnv("INJECT_PROBES")
+	c := &Config{
+		APIVersion: APIVersion,
+		Kind:       Kind,
+		Istio: IstioConfig{
+			Enabled: os.Getenv("USE_ISTIO") == "true",
+			Gateway: getEnvDefault("ISTIO_GATEWAY", DefaultIstioGateway),
+			Host:    getEnvDefault("ISTIO_HOST", DefaultIstioHost),
+		},
+		ClusterDomain: getEnvDefault("CLUSTER_DOMAIN", DefaultClusterDomain),
+		AddFSGroup:    !fsGroupSet || fsGroup == "true",
+		Culling: CullingConfig{
+			Enabled:     os.Getenv("ENABLE_CULLING") == "true",
+			IdleTime:    DefaultCullIdleTime,
+			CheckPeriod: DefaultIdlenessCheckPeriod,
+		},
+		Probes: ProbesConfig{
+			Enabled: !probesSet || probes == "true",
+			// RStudio and VS Code are served at the root of the rewritten URI.
+			ServerTypePaths: map[string]string{
+				"group-one": "/",
+				"group-two": "/",
+			},
+			StartupFailureThreshold: DefaultStartupFailureThreshold,
+		},
+		Updates: UpdatesConfig{
+			Enabled:     os.Getenv("CHECK_UPDATES") == "true",
+			CheckPeriod: DefaultUpdateCheckPeriod,
+		},
+		Dev: getEnvDefault("DEV", "false") != "false",
+	}
+
+	// An invalid idle time has always fallen back to the default value.
+	if idleTime, err := strconv.Atoi(getEnvDefault("CULL_IDLE_TIME", "")); err == nil {
+		c.Culling.IdleTime = idleTime
+	}
+	if period, ok := os.LookupEnv("IDLENESS_CHECK_PERIOD"); ok && period != "" {
+		p, err := strconv.Atoi(period)
+		if err != nil {
+			return nil, fmt.Errorf("IDLENESS_CHECK_PERIOD should be an integer, got %q", period)
+		}
+		c.Culling.CheckPeriod = p
+	}
+	return c, nil
+}
+
+// Load reads the configuration file at path on top of the configuration
+// defined by the environment, and validates the result. If path is empty,
+// only the environment is used.
+func Load(path string) (*Config, error) {
+	c, err := FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		if err := c.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readFile overrides the fields of c that are set in the file at path.
+func (c *Config) readFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	c.APIVersion, c.Kind = "", ""
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return fmt.Errorf("unable to parse %s: %v", path, err)
+	}
+	if c.APIVersion != APIVersion || c.Kind != Kind {
+		return fmt.Errorf("%s: expected apiVersion %s and kind %s, got %q and %q",
+			path, APIVersion, Kind, c.APIVersion, c.Kind)
+	}
+	return nil
+}
+
+// Validate returns an error if the configuration can't be used.
+func (c *Config) Validate() error {
+	if c.ClusterDomain == "" {
+		return fmt.Errorf("clusterDomain must not be empty")
+	}
+	if c.Istio.Enabled && (c.Istio.Gateway == "" || c.Istio.Host == "") {
+		return fmt.Errorf("istio.gateway and istio.host must be set when istio is enabled")
+	}
+	if c.Culling.IdleTime <= 0 {
+		return fmt.Errorf("culling.idleTime must be positive, got %d", c.Culling.IdleTime)
+	}
+	if c.Culling.CheckPeriod <= 0 {
+		return fmt.Errorf("culling.checkPeriod must be positive, got %d", c.Culling.CheckPeriod)
+	}
+	if c.Probes.StartupFailureThreshold <= 0 {
+		return fmt.Errorf("probes.startupFailureThreshold must be positive, got %d",
+			c.Probes.StartupFailureThreshold)
+	}
+	if c.Updates.CheckPeriod <= 0 {
+		return fmt.Errorf("updates.checkPeriod must be positive, got %d", c.Updates.CheckPeriod)
+	}
+	return nil
+}
+
+func getEnvDefault(variable string, defaultVal string) string {
+	envVar := os.Getenv(variable)
+	if len(envVar) == 0 {
+		return defaultVal
+	}
+	return envVar
+}
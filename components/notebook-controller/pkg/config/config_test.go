@@ -0,0 +1,169 @@
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Unable to write %s: %v", path, err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("ISTIO_HOST", "env.example.com")
+	defer os.Unsetenv("ISTIO_HOST")
+
+	testCases := []struct {
+		testName string
+		content  string
+		valid    bool
+		check    func(c *Config) bool
+	}{
+		{
+			testName: "File overrides the environment",
+			content: `apiVersion: notebooks.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+istio:
+  enabled: true
+  host: file.example.com
+culling:
+  enabled: true
+  idleTime: 60
+`,
+			valid: true,
+			check: func(c *Config) bool {
+				return c.Istio.Enabled && c.Istio.Host == "file.example.com" &&
+					c.Istio.Gateway == DefaultIstioGateway &&
+					c.Culling.Enabled && c.Culling.IdleTime == 60 &&
+					c.Culling.CheckPeriod == DefaultIdlenessCheckPeriod
+			},
+		},
+		{
+			testName: "Environment is used for unset fields",
+			content: `apiVersion: notebooks.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+clusterDomain: example.local
+`,
+			valid: true,
+			check: func(c *Config) bool {
+				return c.Istio.Host == "env.example.com" && c.ClusterDomain == "example.local" && c.AddFSGroup
+			},
+		},
+		{
+			testName: "Unknown fields are rejected",
+			content: `apiVersion: notebooks.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+cullingEnabled: true
+`,
+		},
+		{
+			testName: "Wrong kind is rejected",
+			content: `apiVersion: notebooks.kubeflow.org/v1alpha1
+kind: TensorboardControllerConfig
+`,
+		},
+		{
+			testName: "Invalid values are rejected",
+			content: `apiVersion: notebooks.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+culling:
+  checkPeriod: 0
+`,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			path := filepath.Join(dir, "config.yaml")
+			writeConfig(t, path, c.content)
+			cfg, err := Load(path)
+			if !c.valid {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", cfg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !c.check(cfg) {
+				t.Errorf("Unexpected configuration: %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	os.Setenv("ADD_FSGROUP", "false")
+	os.Setenv("CULL_IDLE_TIME", "not-a-number")
+	defer os.Unsetenv("ADD_FSGROUP")
+	defer os.Unsetenv("CULL_IDLE_TIME")
+
+	cfg, err := FromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.AddFSGroup {
+		t.Errorf("Expected ADD_FSGROUP=false to disable the fsGroup")
+	}
+	if cfg.Culling.IdleTime != DefaultCullIdleTime {
+		t.Errorf("Expected an invalid CULL_IDLE_TIME to fall back to %d, got %d",
+			DefaultCullIdleTime, cfg.Culling.IdleTime)
+	}
+
+	os.Setenv("IDLENESS_CHECK_PERIOD", "never")
+	defer os.Unsetenv("IDLENESS_CHECK_PERIOD")
+	if _, err := FromEnv(); err == nil {
+		t.Errorf("Expected an error for an invalid IDLENESS_CHECK_PERIOD")
+	}
+}
+
+func TestStoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, `apiVersion: notebooks.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+culling:
+  idleTime: 10
+`)
+
+	store, err := NewStore(path, logf.Log.WithName("test"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	changes := store.Subscribe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go store.Start(ctx)
+	// Give the watcher some time to start.
+	time.Sleep(100 * time.Millisecond)
+
+	// An invalid file keeps the previous configuration.
+	writeConfig(t, path, "kind: [")
+	time.Sleep(100 * time.Millisecond)
+	if got := store.Get().Culling.IdleTime; got != 10 {
+		t.Errorf("Expected the previous idle time to be kept, got %d", got)
+	}
+
+	writeConfig(t, path, `apiVersion: notebooks.kubeflow.org/v1alpha1
+kind: NotebookControllerConfig
+culling:
+  idleTime: 20
+`)
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the configuration to be reloaded")
+	}
+	if got := store.Get().Culling.IdleTime; got != 20 {
+		t.Errorf("Expected the idle time to be reloaded, got %d", got)
+	}
+}
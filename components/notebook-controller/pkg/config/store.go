@@ -0,0 +1,130 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// Store holds the effective configuration of the controller and reloads it
+// when the configuration file changes. An invalid file is logged and ignored,
+// so the controller keeps running with the last valid configuration.
+type Store struct {
+	path    string
+	log     logr.Logger
+	current atomic.Value
+
+	mu          sync.Mutex
+	subscribers []chan event.GenericEvent
+}
+
+// NewStore loads and validates the configuration file at path. If path is
+// empty, the configuration comes from the environment and never changes.
+func NewStore(path string, log logr.Logger) (*Store, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{path: path, log: log}
+	s.current.Store(c)
+	return s, nil
+}
+
+// Get returns the current configuration. The result must not be modified.
+func (s *Store) Get() *Config {
+	return s.current.Load().(*Config)
+}
+
+// Subscribe returns a channel that receives an event every time the
+// configuration changes. It is meant to be used with a source.Channel.
+func (s *Store) Subscribe() <-chan event.GenericEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := make(chan event.GenericEvent, 1)
+	s.subscribers = append(s.subscribers, ch)
+	return ch
+}
+
+// Start watches the configuration file until ctx is done.
+func (s *Store) Start(ctx context.Context) error {
+	if s.path == "" {
+		<-ctx.Done()
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(s.path); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case fsEvent := <-watcher.Events:
+			if fsEvent.Op&(fsnotify.Write|fsnotify.Remove) == 0 {
+				continue
+			}
+			// ConfigMaps work with symlinks. See:
+			// https://martensson.io/go-fsnotify-and-kubernetes-configmaps/
+			if fsEvent.Op&fsnotify.Remove != 0 {
+				watcher.Remove(fsEvent.Name)
+				if err := watcher.Add(s.path); err != nil {
+					s.log.Error(err, "Unable to watch config file", "path", s.path)
+				}
+			}
+			s.reload()
+		case err := <-watcher.Errors:
+			s.log.Error(err, "Error while watching config file", "path", s.path)
+		}
+	}
+}
+
+// NeedLeaderElection returns false, so that every replica follows the
+// configuration file and not only the leader.
+func (s *Store) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *Store) reload() {
+	c, err := Load(s.path)
+	if err != nil {
+		s.log.Error(err, "Ignoring invalid config file", "path", s.path)
+		return
+	}
+	if reflect.DeepEqual(c, s.Get()) {
+		return
+	}
+	s.current.Store(c)
+	s.log.Info("Reloaded config file", "path", s.path, "config", c)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.subscribers {
+		// A pending event already triggers a reconciliation with the latest
+		// configuration, so there is no need to queue another one.
+		select {
+		case ch <- event.GenericEvent{}:
+		default:
+		}
+	}
+}
+
+// ServeHTTP writes the effective configuration as JSON, for debugging.
+func (s *Store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Get()); err != nil {
+		s.log.Error(err, "Unable to write the configuration")
+	}
+}
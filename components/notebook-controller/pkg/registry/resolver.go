@@ -0,0 +1,242 @@
+// Package registry resolves image tags to the digests they currently point
+// to, using the Docker Registry HTTP API V2.
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDomain = "docker.io"
+	// Docker Hub serves the registry API under a different host.
+	dockerHubHost = "registry-1.docker.io"
+	defaultTag    = "latest"
+)
+
+// The manifest types accepted when resolving a tag. Multi-arch images are
+// resolved to the digest of their index, which is also what the kubelet
+// reports in the imageID of the containers.
+var manifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Resolver returns the digests of image tags. The digests are cached, so
+// that the Notebooks using the same image only cost one request.
+type Resolver struct {
+	Client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedDigest
+}
+
+type cachedDigest struct {
+	digest  string
+	fetched time.Time
+}
+
+// NewResolver returns a Resolver with an empty cache.
+func NewResolver() *Resolver {
+	return &Resolver{
+		Client: &http.Client{Timeout: 30 * time.Second},
+		cache:  map[string]cachedDigest{},
+	}
+}
+
+// Reference is a parsed image reference.
+type Reference struct {
+	// Host of the registry API.
+	Host       string
+	Repository string
+	// Tag or digest of the image.
+	Reference string
+}
+
+// IsDigest returns true if the reference pins the image to a digest.
+func (r Reference) IsDigest() bool {
+	return strings.Contains(r.Reference, ":")
+}
+
+// ParseReference parses an image reference like the container runtimes do:
+// images without a registry are pulled from Docker Hub, and images without
+// a tag or digest use the latest tag.
+func ParseReference(image string) (Reference, error) {
+	if image == "" {
+		return Reference{}, fmt.Errorf("empty image reference")
+	}
+	ref := Reference{Host: defaultDomain}
+	name := image
+
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Reference = name[:i], name[i+1:]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Reference = name[:i], name[i+1:]
+	} else {
+		ref.Reference = defaultTag
+	}
+
+	if i := strings.Index(name, "/"); i >= 0 {
+		domain := name[:i]
+		if strings.ContainsAny(domain, ".:") || domain == "localhost" {
+			ref.Host, name = domain, name[i+1:]
+		}
+	}
+	if ref.Host == defaultDomain {
+		ref.Host = dockerHubHost
+		if !strings.Contains(name, "/") {
+			name = "library/" + name
+		}
+	}
+	if name == "" || ref.Reference == "" {
+		return Reference{}, fmt.Errorf("invalid image reference %q", image)
+	}
+	ref.Repository = name
+	return ref, nil
+}
+
+// Digest returns the digest the tag of image points to. The registry is only
+// asked when the cached digest is older than maxAge.
+func (r *Resolver) Digest(ctx context.Context, image string, maxAge time.Duration) (string, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	if ref.IsDigest() {
+		return ref.Reference, nil
+	}
+
+	key := ref.Host + "/" + ref.Repository + ":" + ref.Reference
+	r.mu.Lock()
+	cached, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && time.Since(cached.fetched) < maxAge {
+		return cached.digest, nil
+	}
+
+	digest, err := r.fetchDigest(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	r.cache[key] = cachedDigest{digest: digest, fetched: time.Now()}
+	r.mu.Unlock()
+	return digest, nil
+}
+
+func (r *Resolver) fetchDigest(ctx context.Context, ref Reference) (string, error) {
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", ref.Host, ref.Repository, ref.Reference)
+
+	resp, err := r.headManifest(ctx, manifestURL, "")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		// Public images on most registries only need an anonymous token.
+		token, err := r.token(ctx, resp.Header.Get("WWW-Authenticate"), ref)
+		if err != nil {
+			return "", err
+		}
+		if resp, err = r.headManifest(ctx, manifestURL, token); err != nil {
+			return "", err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to get the manifest of %s/%s:%s: %s",
+			ref.Host, ref.Repository, ref.Reference, resp.Status)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("registry %s didn't return the digest of %s:%s",
+			ref.Host, ref.Repository, ref.Reference)
+	}
+	return digest, nil
+}
+
+func (r *Resolver) headManifest(ctx context.Context, manifestURL, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// token requests a pull token from the authorization server named in the
+// WWW-Authenticate challenge of the registry.
+func (r *Resolver) token(ctx context.Context, challenge string, ref Reference) (string, error) {
+	params := parseChallenge(challenge)
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("registry %s requires unsupported authentication %q", ref.Host, challenge)
+	}
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid token realm %q: %v", realm, err)
+	}
+	query := tokenURL.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", fmt.Sprintf("repository:%s:pull", ref.Repository))
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to get a token for %s/%s: %s", ref.Host, ref.Repository, resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid token response from %s: %v", realm, err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// parseChallenge returns the parameters of a Bearer challenge like
+// `Bearer realm="https://auth.docker.io/token",service="registry.docker.io"`.
+func parseChallenge(challenge string) map[string]string {
+	params := map[string]string{}
+	parts := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return params
+	}
+	for _, param := range strings.Split(parts[1], ",") {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		params[strings.ToLower(kv[0])] = strings.Trim(kv[1], `"`)
+	}
+	return params
+}
@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReference(t *testing.T) {
+	testCases := []struct {
+		image    string
+		expected Reference
+	}{
+		{"jupyter", Reference{dockerHubHost, "library/jupyter", "latest"}},
+		{"kubeflownotebookswg/jupyter:v1.6.0", Reference{dockerHubHost, "kubeflownotebookswg/jupyter", "v1.6.0"}},
+		{"docker.io/kubeflownotebookswg/jupyter", Reference{dockerHubHost, "kubeflownotebookswg/jupyter", "latest"}},
+		{"gcr.io/project/team/image:tag", Reference{"gcr.io", "project/team/image", "tag"}},
+		{"localhost:5000/image", Reference{"localhost:5000", "image", "latest"}},
+		{"quay.io/org/image@sha256:abcd", Reference{"quay.io", "org/image", "sha256:abcd"}},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.image, func(t *testing.T) {
+			ref, err := ParseReference(c.image)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if ref != c.expected {
+				t.Errorf("Expected %+v, got %+v", c.expected, ref)
+			}
+		})
+	}
+}
+
+func TestDigest(t *testing.T) {
+	const token = "pull-token"
+	digest := "sha256:1111"
+	manifestRequests := 0
+
+	mux := http.NewServeMux()
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("scope") != "repository:team/image:pull" {
+			http.Error(w, "wrong scope", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"token": %q}`, token)
+	})
+	mux.HandleFunc("/v2/team/image/manifests/v1", func(w http.ResponseWriter, r *http.Request) {
+		manifestRequests++
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.Header().Set("WWW-Authenticate",
+				fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+	})
+
+	resolver := NewResolver()
+	resolver.Client = server.Client()
+	image := strings.TrimPrefix(server.URL, "https://") + "/team/image:v1"
+
+	got, err := resolver.Digest(context.Background(), image, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != digest {
+		t.Errorf("Expected digest %s, got %s", digest, got)
+	}
+
+	// The cached digest is returned until it is older than maxAge.
+	digest = "sha256:2222"
+	if got, _ := resolver.Digest(context.Background(), image, time.Hour); got != "sha256:1111" {
+		t.Errorf("Expected the cached digest, got %s", got)
+	}
+	if got, _ := resolver.Digest(context.Background(), image, 0); got != digest {
+		t.Errorf("Expected the new digest %s, got %s", digest, got)
+	}
+	if manifestRequests != 4 {
+		t.Errorf("Expected 4 manifest requests, got %d", manifestRequests)
+	}
+
+	if _, err := resolver.Digest(context.Background(),
+		strings.TrimPrefix(server.URL, "https://")+"/team/missing:v1", time.Hour); err == nil {
+		t.Errorf("Expected an error for a missing image")
+	}
+}
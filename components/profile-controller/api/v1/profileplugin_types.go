@@ -0,0 +1,117 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ProfilePluginSpec defines the desired state of ProfilePlugin
+type ProfilePluginSpec struct {
+	// Kind of the plugins in spec.plugins of the Profiles handled by this webhook
+	// +kubebuilder:validation:MinLength=1
+	PluginKind string `json:"pluginKind"`
+
+	// How to reach the webhook. Only https is supported.
+	ClientConfig admissionregistrationv1.WebhookClientConfig `json:"clientConfig"`
+
+	// Timeout of a call to the webhook, 10 seconds by default
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=30
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=profileplugins,scope=Cluster
+// +kubebuilder:printcolumn:name="Plugin Kind",type=string,JSONPath=`.spec.pluginKind`
+
+// ProfilePlugin registers a webhook that applies and revokes the plugins of
+// a kind for the Profiles.
+type ProfilePlugin struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ProfilePluginSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ProfilePluginList contains a list of ProfilePlugin
+type ProfilePluginList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProfilePlugin `json:"items"`
+}
+
+// ProfilePluginPhase tells the webhook whether the plugin is applied or
+// revoked.
+type ProfilePluginPhase string
+
+const (
+	// ProfilePluginApply is sent when the Profile is created or updated.
+	ProfilePluginApply ProfilePluginPhase = "apply"
+	// ProfilePluginRevoke is sent when the Profile is deleted. The webhook
+	// must handle it idempotently.
+	ProfilePluginRevoke ProfilePluginPhase = "revoke"
+)
+
+const (
+	ProfilePluginSuccess = "Success"
+	ProfilePluginFailure = "Failure"
+)
+
+// ProfilePluginRequest is the body POSTed to the webhook of a ProfilePlugin.
+// +kubebuilder:object:generate=false
+type ProfilePluginRequest struct {
+	Phase   ProfilePluginPhase `json:"phase"`
+	Profile *Profile           `json:"profile"`
+	// Spec of the plugin in spec.plugins of the Profile
+	Spec *runtime.RawExtension `json:"spec,omitempty"`
+}
+
+// ProfilePluginResponse is the body returned by the webhook of a
+// ProfilePlugin.
+// +kubebuilder:object:generate=false
+type ProfilePluginResponse struct {
+	// Success or Failure
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	// Patches applied by the profile controller to objects in the namespace
+	// of the Profile
+	Patches []ProfilePluginPatch `json:"patches,omitempty"`
+}
+
+// ProfilePluginPatch is a patch of an object in the namespace of the Profile.
+// +kubebuilder:object:generate=false
+type ProfilePluginPatch struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	// application/merge-patch+json by default, or application/json-patch+json
+	Type  types.PatchType `json:"type,omitempty"`
+	Patch json.RawMessage `json:"patch"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ProfilePlugin{}, &ProfilePluginList{})
+}
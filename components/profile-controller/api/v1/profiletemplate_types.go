@@ -0,0 +1,102 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ProfileTemplateSpec defines the defaults of the Profiles referencing the
+// template. The fields set in a Profile override them.
+type ProfileTemplateSpec struct {
+	// Resourcequota applied to the namespaces of the Profiles without one
+	ResourceQuotaSpec v1.ResourceQuotaSpec `json:"resourceQuotaSpec,omitempty"`
+
+	// LimitRange applied to the namespaces of the Profiles without one,
+	// instead of the cluster default
+	LimitRange *v1.LimitRangeSpec `json:"limitRange,omitempty"`
+
+	// Labels set on the namespaces, over the cluster default labels. An
+	// empty value removes the label.
+	NamespaceLabels map[string]string `json:"namespaceLabels,omitempty"`
+
+	// Plugins applied to the Profiles without a plugin of the same kind
+	Plugins []Plugin `json:"plugins,omitempty"`
+
+	// ServiceAccounts provisioned in the namespaces, over the ones of the
+	// cluster default. The ones of the Profiles with the same name override
+	// them.
+	ServiceAccounts []ProfileServiceAccount `json:"serviceAccounts,omitempty"`
+
+	// PodDefaults created in the namespaces
+	PodDefaults []ProfileTemplatePodDefault `json:"podDefaults,omitempty"`
+
+	// ConfigMaps copied into the namespaces
+	ConfigMaps []ProfileTemplateSource `json:"configMaps,omitempty"`
+
+	// Secrets copied into the namespaces
+	Secrets []ProfileTemplateSource `json:"secrets,omitempty"`
+}
+
+// ProfileTemplatePodDefault is a PodDefault created in the namespace of
+// the Profiles.
+type ProfileTemplatePodDefault struct {
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Spec of the kubeflow.org/v1alpha1 PodDefault
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Spec runtime.RawExtension `json:"spec"`
+}
+
+// ProfileTemplateSource is an object copied with the same name into the
+// namespace of the Profiles.
+type ProfileTemplateSource struct {
+	// Namespace of the object to copy
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=profiletemplates,scope=Cluster
+
+// ProfileTemplate bundles the resources of the namespaces of the Profiles
+// that reference it with spec.template.
+type ProfileTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ProfileTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ProfileTemplateList contains a list of ProfileTemplate
+type ProfileTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProfileTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ProfileTemplate{}, &ProfileTemplateList{})
+}
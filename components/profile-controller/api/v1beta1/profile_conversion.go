@@ -0,0 +1,113 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// The versions have the same fields. The fields of the nested types are
+// converted as a whole, so that a field added to only one of the versions
+// breaks the build until it is converted here.
+
+// ConvertTo converts the Profile to the v1 hub.
+func (src *Profile) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*profilev1.Profile)
+	in := src.DeepCopy()
+	dst.ObjectMeta = in.ObjectMeta
+
+	dst.Spec = profilev1.ProfileSpec{
+		Owner:             in.Spec.Owner,
+		Owners:            in.Spec.Owners,
+		ResourceQuotaSpec: in.Spec.ResourceQuotaSpec,
+		LimitRange:        in.Spec.LimitRange,
+		Template:          in.Spec.Template,
+		AdoptNamespace:    in.Spec.AdoptNamespace,
+		ExpiresAt:         in.Spec.ExpiresAt,
+		TTL:               in.Spec.TTL,
+		PodSecurity:       (*profilev1.ProfilePodSecurity)(in.Spec.PodSecurity),
+		NamespaceMetadata: (*profilev1.ProfileNamespaceMetadata)(in.Spec.NamespaceMetadata),
+	}
+	for _, p := range in.Spec.Plugins {
+		dst.Spec.Plugins = append(dst.Spec.Plugins, profilev1.Plugin(p))
+	}
+	for _, c := range in.Spec.Contributors {
+		dst.Spec.Contributors = append(dst.Spec.Contributors, profilev1.ProfileContributor(c))
+	}
+	for _, sa := range in.Spec.ServiceAccounts {
+		dst.Spec.ServiceAccounts = append(dst.Spec.ServiceAccounts, profilev1.ProfileServiceAccount(sa))
+	}
+
+	dst.Status = profilev1.ProfileStatus{
+		ObservedTemplateGeneration: in.Status.ObservedTemplateGeneration,
+		Quota:                      (*profilev1.ProfileQuotaStatus)(in.Status.Quota),
+	}
+	for _, c := range in.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, profilev1.ProfileCondition(c))
+	}
+	for _, c := range in.Status.AdoptionConflicts {
+		dst.Status.AdoptionConflicts = append(dst.Status.AdoptionConflicts, profilev1.ProfileResourceConflict(c))
+	}
+	for _, p := range in.Status.Plugins {
+		dst.Status.Plugins = append(dst.Status.Plugins, profilev1.ProfilePluginStatus(p))
+	}
+	return nil
+}
+
+// ConvertFrom converts the v1 hub to the Profile.
+func (dst *Profile) ConvertFrom(srcRaw conversion.Hub) error {
+	in := srcRaw.(*profilev1.Profile).DeepCopy()
+	dst.ObjectMeta = in.ObjectMeta
+
+	dst.Spec = ProfileSpec{
+		Owner:             in.Spec.Owner,
+		Owners:            in.Spec.Owners,
+		ResourceQuotaSpec: in.Spec.ResourceQuotaSpec,
+		LimitRange:        in.Spec.LimitRange,
+		Template:          in.Spec.Template,
+		AdoptNamespace:    in.Spec.AdoptNamespace,
+		ExpiresAt:         in.Spec.ExpiresAt,
+		TTL:               in.Spec.TTL,
+		PodSecurity:       (*ProfilePodSecurity)(in.Spec.PodSecurity),
+		NamespaceMetadata: (*ProfileNamespaceMetadata)(in.Spec.NamespaceMetadata),
+	}
+	for _, p := range in.Spec.Plugins {
+		dst.Spec.Plugins = append(dst.Spec.Plugins, Plugin(p))
+	}
+	for _, c := range in.Spec.Contributors {
+		dst.Spec.Contributors = append(dst.Spec.Contributors, ProfileContributor(c))
+	}
+	for _, sa := range in.Spec.ServiceAccounts {
+		dst.Spec.ServiceAccounts = append(dst.Spec.ServiceAccounts, ProfileServiceAccount(sa))
+	}
+
+	dst.Status = ProfileStatus{
+		ObservedTemplateGeneration: in.Status.ObservedTemplateGeneration,
+		Quota:                      (*ProfileQuotaStatus)(in.Status.Quota),
+	}
+	for _, c := range in.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, ProfileCondition(c))
+	}
+	for _, c := range in.Status.AdoptionConflicts {
+		dst.Status.AdoptionConflicts = append(dst.Status.AdoptionConflicts, ProfileResourceConflict(c))
+	}
+	for _, p := range in.Status.Plugins {
+		dst.Status.Plugins = append(dst.Status.Plugins, ProfilePluginStatus(p))
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package v1beta1
+
+import (
+	"fmt"
+	"testing"
+
+	fuzz "github.com/google/gofuzz"
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+)
+
+// newProfileFuzzer returns a fuzzer setting every field, so that a field
+// missed by the conversion fails the round trips.
+func newProfileFuzzer() *fuzz.Fuzzer {
+	return fuzz.New().NilChance(0).NumElements(1, 3).Funcs(
+		func(q *resource.Quantity, c fuzz.Continue) {
+			*q = *resource.NewQuantity(c.Int63n(1000), resource.DecimalSI)
+		},
+		func(e *runtime.RawExtension, c fuzz.Continue) {
+			e.Raw = []byte(fmt.Sprintf(`{"value":%d}`, c.Int63()))
+		},
+		func(m *metav1.TypeMeta, c fuzz.Continue) {
+			// The webhook sets the apiVersion and kind.
+		},
+	)
+}
+
+func TestProfileConversionRoundTrip(t *testing.T) {
+	f := newProfileFuzzer()
+	for i := 0; i < 100; i++ {
+		original := &Profile{}
+		f.Fuzz(original)
+		hub := &profilev1.Profile{}
+		if err := original.ConvertTo(hub); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		restored := &Profile{}
+		if err := restored.ConvertFrom(hub); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !apiequality.Semantic.DeepEqual(original, restored) {
+			t.Fatalf("v1beta1 changed through v1:\n%+v\n%+v", original, restored)
+		}
+	}
+}
+
+func TestProfileHubRoundTrip(t *testing.T) {
+	f := newProfileFuzzer()
+	for i := 0; i < 100; i++ {
+		original := &profilev1.Profile{}
+		f.Fuzz(original)
+		spoke := &Profile{}
+		if err := spoke.ConvertFrom(original); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		restored := &profilev1.Profile{}
+		if err := spoke.ConvertTo(restored); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !apiequality.Semantic.DeepEqual(original, restored) {
+			t.Fatalf("v1 changed through v1beta1:\n%+v\n%+v", original, restored)
+		}
+	}
+}
+
+func TestProfileConversionIsolated(t *testing.T) {
+	original := &Profile{Spec: ProfileSpec{
+		Plugins:      []Plugin{{Spec: &runtime.RawExtension{Raw: []byte(`{}`)}}},
+		Contributors: []ProfileContributor{{Role: "edit"}},
+	}}
+	hub := &profilev1.Profile{}
+	if err := original.ConvertTo(hub); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	hub.Spec.Plugins[0].Spec.Raw[0] = '['
+	hub.Spec.Contributors[0].Role = "view"
+	if string(original.Spec.Plugins[0].Spec.Raw) != "{}" || original.Spec.Contributors[0].Role != "edit" {
+		t.Errorf("Expected the converted Profile not to share memory with its source")
+	}
+}
+
+func TestProfileIsConvertible(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := profilev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := conversion.IsConvertible(scheme, &Profile{})
+	if err != nil || !ok {
+		t.Errorf("Expected Profile to be convertible, got %v, %v", ok, err)
+	}
+}
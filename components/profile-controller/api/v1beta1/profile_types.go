@@ -27,9 +27,15 @@ type Plugin struct {
 }
 
 type ProfileCondition struct {
-	Type    string `json:"type,omitempty"`
-	Status  string `json:"status,omitempty" description:"status of the condition, one of True, False, Unknown"`
+	Type   string `json:"type,omitempty"`
+	Status string `json:"status,omitempty" description:"status of the condition, one of True, False, Unknown"`
+	// CamelCase reason of the last status of the condition
+	Reason  string `json:"reason,omitempty"`
 	Message string `json:"message,omitempty"`
+	// Last time the status of the condition changed
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Generation of the Profile the condition was set for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
 
 // ProfileSpec defines the desired state of Profile
@@ -37,10 +43,104 @@ type ProfileSpec struct {
 	// The profile owner
 	Owner rbacv1.Subject `json:"owner,omitempty"`
 
+	// Owners share the ownership of the profile with the owner. They can be
+	// users or groups.
+	Owners []rbacv1.Subject `json:"owners,omitempty"`
+
 	Plugins []Plugin `json:"plugins,omitempty"`
 
 	// Resourcequota that will be applied to target namespace
 	ResourceQuotaSpec v1.ResourceQuotaSpec `json:"resourceQuotaSpec,omitempty"`
+
+	// LimitRange applied to the namespace, instead of the cluster default.
+	// An empty list of limits removes the cluster default.
+	LimitRange *v1.LimitRangeSpec `json:"limitRange,omitempty"`
+
+	// Contributors are given access to the namespace besides the owner
+	Contributors []ProfileContributor `json:"contributors,omitempty"`
+
+	// ServiceAccounts provisioned in the namespace, besides the ones of the
+	// cluster default. An entry with the name of a default one overrides it.
+	ServiceAccounts []ProfileServiceAccount `json:"serviceAccounts,omitempty"`
+
+	// Name of the ProfileTemplate the fields not set here default to
+	Template string `json:"template,omitempty"`
+
+	// AdoptNamespace allows the Profile to take over an existing namespace
+	// that isn't owned by its owner, if the namespace is annotated with
+	// profiles.kubeflow.org/adopt: <owner name>
+	AdoptNamespace bool `json:"adoptNamespace,omitempty"`
+
+	// ExpiresAt is the time the Profile expires at. It is deleted with its
+	// namespace once the expiration grace period of the controller is over.
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// TTL is the lifetime of the Profile from its creation, when ExpiresAt
+	// isn't set
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// PodSecurity sets the Pod Security Admission levels of the namespace,
+	// instead of the cluster default
+	PodSecurity *ProfilePodSecurity `json:"podSecurity,omitempty"`
+
+	// NamespaceMetadata sets labels and annotations on the namespace
+	NamespaceMetadata *ProfileNamespaceMetadata `json:"namespaceMetadata,omitempty"`
+}
+
+// ProfileNamespaceMetadata are labels and annotations of the namespace of
+// the Profile. They don't override the system keys (with a kubernetes.io,
+// k8s.io or kubeflow.org domain), the cluster default labels nor the owner
+// annotation.
+type ProfileNamespaceMetadata struct {
+	Labels map[string]string `json:"labels,omitempty"`
+
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ProfilePodSecurity is the Pod Security Admission configuration of the
+// namespace of the Profile. The levels that aren't set keep the ones of the
+// cluster default.
+type ProfilePodSecurity struct {
+	// Level enforced on the pods of the namespace
+	// +kubebuilder:validation:Enum=privileged;baseline;restricted
+	Enforce string `json:"enforce,omitempty"`
+
+	// Level whose violations are recorded in the audit log
+	// +kubebuilder:validation:Enum=privileged;baseline;restricted
+	Audit string `json:"audit,omitempty"`
+
+	// Level whose violations are returned as warnings to the users
+	// +kubebuilder:validation:Enum=privileged;baseline;restricted
+	Warn string `json:"warn,omitempty"`
+
+	// Version of the Pod Security Standards of the levels, latest if empty
+	Version string `json:"version,omitempty"`
+}
+
+// ProfileContributor is a user, group or service account with a role in
+// the namespace of the Profile.
+type ProfileContributor struct {
+	rbacv1.Subject `json:",inline"`
+
+	// Role of the contributor, bound to the kubeflow-<role> ClusterRole
+	// +kubebuilder:validation:Enum=admin;edit;view
+	Role string `json:"role"`
+}
+
+// ProfileServiceAccount is a service account of the namespace of the
+// Profile.
+type ProfileServiceAccount struct {
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// ClusterRole bound to the service account in the namespace, none if empty
+	ClusterRole string `json:"clusterRole,omitempty"`
+
+	// Annotations set on the service account
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// ImagePullSecrets added to the service account
+	ImagePullSecrets []v1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
 }
 
 const (
@@ -52,6 +152,62 @@ const (
 // ProfileStatus defines the observed state of Profile
 type ProfileStatus struct {
 	Conditions []ProfileCondition `json:"conditions,omitempty"`
+
+	// Generation of the ProfileTemplate the Profile was last reconciled with
+	ObservedTemplateGeneration int64 `json:"observedTemplateGeneration,omitempty"`
+
+	// Pre-existing resources of the namespace that block its adoption
+	AdoptionConflicts []ProfileResourceConflict `json:"adoptionConflicts,omitempty"`
+
+	// Usage of the ResourceQuota of the namespace
+	Quota *ProfileQuotaStatus `json:"quota,omitempty"`
+
+	// Plugins applied to the Profile
+	Plugins []ProfilePluginStatus `json:"plugins,omitempty"`
+}
+
+// ProfilePluginStatus records when a plugin was applied, and with which
+// spec, so that it is only applied again when its spec changes or when it is
+// verified.
+type ProfilePluginStatus struct {
+	Kind string `json:"kind"`
+
+	// Hash of the spec the plugin was last applied with
+	SpecHash string `json:"specHash"`
+
+	// Spec the plugin was last applied with, to revoke what a new spec drops
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Spec *runtime.RawExtension `json:"spec,omitempty"`
+
+	// Last time the plugin was applied
+	LastAppliedTime metav1.Time `json:"lastAppliedTime,omitempty"`
+
+	// Last time the plugin was applied or checked for drift
+	LastVerifiedTime metav1.Time `json:"lastVerifiedTime,omitempty"`
+
+	// What had drifted from the spec when the plugin was last verified
+	Drift string `json:"drift,omitempty"`
+}
+
+// ProfileQuotaStatus mirrors the status of the kf-resource-quota
+// ResourceQuota of the namespace.
+type ProfileQuotaStatus struct {
+	// Hard limits of the quota
+	Hard v1.ResourceList `json:"hard,omitempty"`
+
+	// Resources used in the namespace
+	Used v1.ResourceList `json:"used,omitempty"`
+
+	// Resources left before the hard limits are reached
+	Remaining v1.ResourceList `json:"remaining,omitempty"`
+}
+
+// ProfileResourceConflict is a resource of the namespace with the name of
+// one that the Profile manages, but that the Profile doesn't own.
+type ProfileResourceConflict struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Message string `json:"message,omitempty"`
 }
 
 // +kubebuilder:object:root=true
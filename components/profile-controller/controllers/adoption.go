@@ -0,0 +1,152 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	istioSecurityClient "istio.io/client-go/pkg/apis/security/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// NAMESPACE_ADOPT_ANNOTATION lets a Profile with spec.adoptNamespace take
+// over an existing namespace when it is set on the namespace to the primary
+// owner of the Profile, so that the namespace approves a given owner rather
+// than any Profile of its name.
+const NAMESPACE_ADOPT_ANNOTATION = "profiles.kubeflow.org/adopt"
+
+// canAdoptNamespace tells whether both the Profile and the namespace opted
+// in to the adoption, the namespace for the owner of the Profile.
+func canAdoptNamespace(profileIns *profilev1.Profile, ns *corev1.Namespace) bool {
+	owner := getPrimaryOwner(profileIns)
+	return profileIns.Spec.AdoptNamespace && owner != "" && ns.Annotations[NAMESPACE_ADOPT_ANNOTATION] == owner
+}
+
+// managedObject is a resource the Profile creates in its namespace.
+type managedObject struct {
+	kind string
+	obj  client.Object
+}
+
+// getManagedObjects returns the resources with fixed names that the Profile
+// creates in its namespace, and would take over.
+func (r *ProfileReconciler) getManagedObjects(profileIns *profilev1.Profile) ([]managedObject, error) {
+	objects := []managedObject{
+		{"RoleBinding", &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "namespaceAdmin"}}},
+		{"AuthorizationPolicy", &istioSecurityClient.AuthorizationPolicy{ObjectMeta: metav1.ObjectMeta{Name: AUTHZPOLICYISTIO}}},
+		{"ResourceQuota", &corev1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Name: KFQUOTA}}},
+		{"LimitRange", &corev1.LimitRange{ObjectMeta: metav1.ObjectMeta{Name: KFLIMITRANGE}}},
+	}
+	defaults, err := readDefaultServiceAccountsFromFile(r.DefaultServiceAccountsPath)
+	if err != nil {
+		return nil, err
+	}
+	// Existing service accounts are merged, only their RoleBindings are replaced.
+	for _, sa := range getProfileServiceAccounts(profileIns, defaults) {
+		if sa.ClusterRole != "" {
+			objects = append(objects, managedObject{"RoleBinding",
+				&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: sa.Name}}})
+		}
+	}
+	networkPolicies, err := readNetworkPoliciesFromFile(r.NetworkPoliciesPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, networkPolicy := range networkPolicies {
+		objects = append(objects, managedObject{"NetworkPolicy",
+			&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: networkPolicy.Name}}})
+	}
+	return objects, nil
+}
+
+// getAdoptionConflicts returns the namespace, if another object controls it,
+// and the resources of the namespace that the Profile would take over
+// although it doesn't own them.
+func (r *ProfileReconciler) getAdoptionConflicts(ctx context.Context, profileIns *profilev1.Profile,
+	ns *corev1.Namespace) ([]profilev1.ProfileResourceConflict, error) {
+	conflicts := []profilev1.ProfileResourceConflict{}
+	if ref := metav1.GetControllerOf(ns); ref != nil && ref.UID != profileIns.UID {
+		conflicts = append(conflicts, profilev1.ProfileResourceConflict{
+			Kind:    "Namespace",
+			Name:    ns.Name,
+			Message: fmt.Sprintf("controlled by %v %v", ref.Kind, ref.Name),
+		})
+	}
+
+	objects, err := r.getManagedObjects(profileIns)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range objects {
+		err := r.Get(ctx, types.NamespacedName{Name: o.obj.GetName(), Namespace: ns.Name}, o.obj)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if metav1.IsControlledBy(o.obj, profileIns) {
+			continue
+		}
+		message := "not owned by the Profile"
+		if ref := metav1.GetControllerOf(o.obj); ref != nil {
+			message = fmt.Sprintf("controlled by %v %v", ref.Kind, ref.Name)
+		}
+		conflicts = append(conflicts, profilev1.ProfileResourceConflict{
+			Kind:    o.kind,
+			Name:    o.obj.GetName(),
+			Message: message,
+		})
+	}
+	return conflicts, nil
+}
+
+// adoptNamespace makes the Profile own the existing namespace, and adds the
+// labels without replacing the values of existing ones.
+func (r *ProfileReconciler) adoptNamespace(ctx context.Context, profileIns *profilev1.Profile,
+	ns *corev1.Namespace, labels map[string]string) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations["owner"] = getPrimaryOwner(profileIns)
+	if err := controllerutil.SetControllerReference(profileIns, ns, r.Scheme); err != nil {
+		return err
+	}
+	setNamespaceLabels(ns, labels)
+	logger.Info("Adopting existing namespace", "namespace", ns.Name, "owner", getPrimaryOwner(profileIns))
+	return r.Update(ctx, ns)
+}
+
+// formatAdoptionConflicts describes the conflicts in a condition message.
+func formatAdoptionConflicts(conflicts []profilev1.ProfileResourceConflict) string {
+	resources := []string{}
+	for _, c := range conflicts {
+		resources = append(resources, fmt.Sprintf("%v %v (%v)", c.Kind, c.Name, c.Message))
+	}
+	return "namespace can't be adopted, delete the conflicting resources: " + strings.Join(resources, ", ")
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestAdoptNamespace(t *testing.T) {
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a"},
+		Spec: profilev1.ProfileSpec{
+			Owner:          rbacv1.Subject{Kind: rbacv1.UserKind, Name: "lead@example.com"},
+			AdoptNamespace: true,
+		},
+	}
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "team-a",
+			Annotations: map[string]string{NAMESPACE_ADOPT_ANNOTATION: "lead@example.com"},
+			Labels:      map[string]string{"app.kubernetes.io/part-of": "legacy"},
+		},
+	}
+	legacyBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "namespaceAdmin", Namespace: "team-a"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "admin"},
+	}
+	r := newTestReconciler(profile, ns, legacyBinding)
+	r.DefaultNamespaceLabelsPath = newTestLabelsFile(t)
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: profile.Name}}
+
+	// The RoleBinding the Profile would replace blocks the adoption.
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	found := &profilev1.Profile{}
+	if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Status.AdoptionConflicts) != 1 || found.Status.AdoptionConflicts[0].Kind != "RoleBinding" ||
+		found.Status.AdoptionConflicts[0].Name != "namespaceAdmin" {
+		t.Errorf("Expected the RoleBinding to conflict, got %+v", found.Status.AdoptionConflicts)
+	}
+	if c := findCondition(found.Status.Conditions, profilev1.ProfileNamespaceReady); c.Reason !=
+		reasonNamespaceAdoptionConflict || !strings.Contains(c.Message, "namespaceAdmin") {
+		t.Errorf("Expected the conflict to be reported, got %+v", c)
+	}
+	foundNs := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ns.Name}, foundNs); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := foundNs.Annotations["owner"]; ok || len(foundNs.OwnerReferences) != 0 {
+		t.Errorf("Expected the namespace to be left unchanged, got %+v", foundNs.ObjectMeta)
+	}
+
+	// Once the conflict is resolved, the namespace is adopted.
+	if err := r.Delete(ctx, legacyBinding); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: ns.Name}, foundNs); err != nil {
+		t.Fatal(err)
+	}
+	if foundNs.Annotations["owner"] != "lead@example.com" || len(foundNs.OwnerReferences) != 1 {
+		t.Errorf("Expected the namespace to be owned by the Profile, got %+v", foundNs.ObjectMeta)
+	}
+	if foundNs.Labels["app.kubernetes.io/part-of"] != "legacy" {
+		t.Errorf("Expected the existing labels to be kept, got %v", foundNs.Labels)
+	}
+	if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Status.AdoptionConflicts) != 0 {
+		t.Errorf("Expected no conflicts, got %+v", found.Status.AdoptionConflicts)
+	}
+	if ready := findCondition(found.Status.Conditions, profilev1.ProfileReady); ready.Status != "True" {
+		t.Errorf("Expected the Profile to be Ready, got %+v", found.Status.Conditions)
+	}
+}
+
+func TestAdoptNamespaceWithoutApproval(t *testing.T) {
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a"},
+		Spec: profilev1.ProfileSpec{
+			Owner:          rbacv1.Subject{Kind: rbacv1.UserKind, Name: "lead@example.com"},
+			AdoptNamespace: true,
+		},
+	}
+	// The annotation must name the owner of the Profile.
+	for _, annotations := range []map[string]string{
+		nil,
+		{NAMESPACE_ADOPT_ANNOTATION: "true"},
+		{NAMESPACE_ADOPT_ANNOTATION: "other@example.com"},
+	} {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Annotations: annotations}}
+		r := newTestReconciler(profile, ns)
+		r.DefaultNamespaceLabelsPath = newTestLabelsFile(t)
+		ctx := context.Background()
+		request := ctrl.Request{NamespacedName: types.NamespacedName{Name: profile.Name}}
+		if _, err := r.Reconcile(ctx, request); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		found := &profilev1.Profile{}
+		if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+			t.Fatal(err)
+		}
+		if c := findCondition(found.Status.Conditions, profilev1.ProfileNamespaceReady); c.Reason !=
+			reasonNamespaceNotOwned || !strings.Contains(c.Message, `: "lead@example.com"`) {
+			t.Errorf("%v: Expected the namespace not to be adopted, got %+v", annotations, c)
+		}
+	}
+}
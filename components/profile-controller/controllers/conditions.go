@@ -0,0 +1,274 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reasons of the conditions of a Profile
+const (
+	reasonReconciled                = "Reconciled"
+	reasonPending                   = "Pending"
+	reasonTemplateNotFound          = "TemplateNotFound"
+	reasonTemplateFailed            = "TemplateFailed"
+	reasonTemplateResourcesFailed   = "TemplateResourcesFailed"
+	reasonNamespaceFailed           = "NamespaceFailed"
+	reasonNamespaceTimeout          = "NamespaceCreationTimeout"
+	reasonNamespaceNotOwned         = "NamespaceNotOwned"
+	reasonNamespaceAdoptionConflict = "NamespaceAdoptionConflict"
+	reasonServiceAccountFailed      = "ServiceAccountFailed"
+	reasonRoleBindingFailed         = "RoleBindingFailed"
+	reasonContributorsFailed        = "ContributorsFailed"
+	reasonAuthorizationPolicyFailed = "AuthorizationPolicyFailed"
+	reasonNetworkPolicyFailed       = "NetworkPolicyFailed"
+	reasonResourceQuotaFailed       = "ResourceQuotaFailed"
+	reasonLimitRangeFailed          = "LimitRangeFailed"
+	reasonPluginsInvalid            = "PluginsInvalid"
+	reasonPluginApplyFailed         = "PluginApplyFailed"
+	reasonPluginRevokeFailed        = "PluginRevokeFailed"
+	reasonExpirationScheduled       = "ExpirationScheduled"
+	reasonExpiringSoon              = "ExpiringSoon"
+	reasonExpired                   = "Expired"
+	reasonPodSecurityViolations     = "PodSecurityViolations"
+	reasonPluginDriftDetected       = "PluginDriftDetected"
+)
+
+// profileConditionTypes are the conditions of the steps of a reconciliation,
+// in the order they are reconciled. Ready sums them up.
+var profileConditionTypes = []string{
+	profilev1.ProfileTemplateReady,
+	profilev1.ProfileNamespaceReady,
+	profilev1.ProfileAuthorizationPolicyReady,
+	profilev1.ProfileNetworkPolicyReady,
+	profilev1.ProfileRBACReady,
+	profilev1.ProfileQuotaReady,
+	profilev1.ProfilePluginsReady,
+}
+
+// profileConditions collects the conditions set while reconciling a Profile,
+// the generation of its template, the conflicts of its adoption, the usage of
+// its quota and the status of its plugins.
+type profileConditions struct {
+	generation         int64
+	templateGeneration int64
+	adoptionConflicts  []profilev1.ProfileResourceConflict
+	quota              *profilev1.ProfileQuotaStatus
+	expiring           *profilev1.ProfileCondition
+	podSecurity        *profilev1.ProfileCondition
+	plugins            []profilev1.ProfilePluginStatus
+	pluginsInSync      *profilev1.ProfileCondition
+	current            map[string]profilev1.ProfileCondition
+}
+
+func newProfileConditions(instance *profilev1.Profile) *profileConditions {
+	c := &profileConditions{
+		generation:         instance.Generation,
+		templateGeneration: instance.Status.ObservedTemplateGeneration,
+		quota:              instance.Status.Quota,
+		current:            map[string]profilev1.ProfileCondition{},
+	}
+	c.setPlugins(instance.Status.Plugins)
+	return c
+}
+
+// setQuota records the usage of the ResourceQuota of the namespace.
+func (c *profileConditions) setQuota(quota *profilev1.ProfileQuotaStatus) {
+	c.quota = quota
+}
+
+// setAdoptionConflicts records the resources that block the adoption of the
+// namespace.
+func (c *profileConditions) setAdoptionConflicts(conflicts []profilev1.ProfileResourceConflict) {
+	c.adoptionConflicts = conflicts
+	if len(conflicts) == 0 {
+		c.adoptionConflicts = nil
+	}
+}
+
+// setTemplate records that the Profile was reconciled with the template.
+func (c *profileConditions) setTemplate(template *profilev1.ProfileTemplate) {
+	c.templateGeneration = 0
+	if template != nil {
+		c.templateGeneration = template.Generation
+	}
+}
+
+// setExpiring records the Expiring condition of a Profile with an
+// expiration.
+func (c *profileConditions) setExpiring(status corev1.ConditionStatus, reason string, message string) {
+	c.expiring = &profilev1.ProfileCondition{
+		Type:    profilev1.ProfileExpiring,
+		Status:  string(status),
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+// setPodSecurity records the PodSecurityEnforced condition of a Profile with
+// Pod Security Admission levels.
+func (c *profileConditions) setPodSecurity(status corev1.ConditionStatus, reason string, message string) {
+	c.podSecurity = &profilev1.ProfileCondition{
+		Type:    profilev1.ProfilePodSecurityEnforced,
+		Status:  string(status),
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+// podSecurityHeldBack returns whether pods hold back the enforced level.
+func (c *profileConditions) podSecurityHeldBack() bool {
+	return c.podSecurity != nil && c.podSecurity.Status == string(corev1.ConditionFalse)
+}
+
+// setPlugins records the status of the plugins, and the PluginsInSync
+// condition from the drift they had when they were last verified.
+func (c *profileConditions) setPlugins(statuses []profilev1.ProfilePluginStatus) {
+	c.plugins = statuses
+	if len(statuses) == 0 {
+		c.plugins = nil
+		c.pluginsInSync = nil
+		return
+	}
+	drifts := []string{}
+	for _, status := range statuses {
+		if status.Drift != "" {
+			drifts = append(drifts, fmt.Sprintf("%v (%v)", status.Kind, status.Drift))
+		}
+	}
+	c.pluginsInSync = &profilev1.ProfileCondition{
+		Type:   profilev1.ProfilePluginsInSync,
+		Status: string(corev1.ConditionTrue),
+		Reason: reasonReconciled,
+	}
+	if len(drifts) > 0 {
+		c.pluginsInSync.Status = string(corev1.ConditionFalse)
+		c.pluginsInSync.Reason = reasonPluginDriftDetected
+		c.pluginsInSync.Message = "drifted and applied again: " + strings.Join(drifts, "; ")
+	}
+}
+
+// setTrue records that the step of the condition succeeded.
+func (c *profileConditions) setTrue(conditionType string) {
+	c.current[conditionType] = profilev1.ProfileCondition{
+		Type:   conditionType,
+		Status: string(corev1.ConditionTrue),
+		Reason: reasonReconciled,
+	}
+}
+
+// setFalse records that the step of the condition failed.
+func (c *profileConditions) setFalse(conditionType string, reason string, message string) {
+	c.current[conditionType] = profilev1.ProfileCondition{
+		Type:    conditionType,
+		Status:  string(corev1.ConditionFalse),
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+// build returns the conditions of the Profile. The steps that weren't
+// reached are Unknown, and the transition times of the conditions whose
+// status didn't change are kept from previous.
+func (c *profileConditions) build(previous []profilev1.ProfileCondition, now metav1.Time) []profilev1.ProfileCondition {
+	conditions := []profilev1.ProfileCondition{}
+	ready := profilev1.ProfileCondition{
+		Type:   profilev1.ProfileReady,
+		Status: string(corev1.ConditionTrue),
+		Reason: reasonReconciled,
+	}
+	for _, conditionType := range profileConditionTypes {
+		condition, ok := c.current[conditionType]
+		if !ok {
+			condition = profilev1.ProfileCondition{
+				Type:    conditionType,
+				Status:  string(corev1.ConditionUnknown),
+				Reason:  reasonPending,
+				Message: "Waiting for the previous steps to succeed",
+			}
+		}
+		if condition.Status != string(corev1.ConditionTrue) && ready.Status == string(corev1.ConditionTrue) {
+			ready.Status = string(corev1.ConditionFalse)
+			ready.Reason = condition.Reason
+			ready.Message = fmt.Sprintf("%v is %v", conditionType, condition.Status)
+			if condition.Message != "" {
+				ready.Message += ": " + condition.Message
+			}
+		}
+		conditions = append(conditions, condition)
+	}
+	conditions = append(conditions, ready)
+	if c.expiring != nil {
+		conditions = append(conditions, *c.expiring)
+	}
+	if c.podSecurity != nil {
+		conditions = append(conditions, *c.podSecurity)
+	}
+	if c.pluginsInSync != nil {
+		conditions = append(conditions, *c.pluginsInSync)
+	}
+
+	for i := range conditions {
+		conditions[i].ObservedGeneration = c.generation
+		conditions[i].LastTransitionTime = now
+		for _, p := range previous {
+			if p.Type == conditions[i].Type && p.Status == conditions[i].Status {
+				conditions[i].LastTransitionTime = p.LastTransitionTime
+			}
+		}
+	}
+	return conditions
+}
+
+// updateProfileConditions writes the conditions of the reconciliation, the
+// generation of the template, the adoption conflicts, the quota usage and the
+// status of the plugins, with the status subresource if they changed. The
+// quota gauges are updated too.
+func (r *ProfileReconciler) updateProfileConditions(ctx context.Context, instance *profilev1.Profile,
+	conditions *profileConditions) error {
+	if !instance.DeletionTimestamp.IsZero() && len(instance.Finalizers) == 0 {
+		// The Profile is gone.
+		SetQuotaGauges(instance.Name, instance.Status.Quota, nil)
+		return nil
+	}
+	SetQuotaGauges(instance.Name, instance.Status.Quota, conditions.quota)
+	updated := conditions.build(instance.Status.Conditions, metav1.Now())
+	if equality.Semantic.DeepEqual(updated, instance.Status.Conditions) &&
+		conditions.templateGeneration == instance.Status.ObservedTemplateGeneration &&
+		equality.Semantic.DeepEqual(conditions.adoptionConflicts, instance.Status.AdoptionConflicts) &&
+		equality.Semantic.DeepEqual(conditions.quota, instance.Status.Quota) &&
+		equality.Semantic.DeepEqual(conditions.plugins, instance.Status.Plugins) {
+		return nil
+	}
+	instance.Status.Conditions = updated
+	instance.Status.ObservedTemplateGeneration = conditions.templateGeneration
+	instance.Status.AdoptionConflicts = conditions.adoptionConflicts
+	instance.Status.Quota = conditions.quota
+	instance.Status.Plugins = conditions.plugins
+	if err := r.Status().Update(ctx, instance); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
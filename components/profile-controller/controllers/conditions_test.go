@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func findCondition(conditions []profilev1.ProfileCondition, conditionType string) profilev1.ProfileCondition {
+	for _, c := range conditions {
+		if c.Type == conditionType {
+			return c
+		}
+	}
+	return profilev1.ProfileCondition{}
+}
+
+func TestBuildProfileConditions(t *testing.T) {
+	before := metav1.NewTime(time.Now().Add(-time.Hour))
+	now := metav1.Now()
+	previous := []profilev1.ProfileCondition{
+		{Type: profilev1.ProfileFailed, Message: "legacy"},
+		{Type: profilev1.ProfileNamespaceReady, Status: "True", LastTransitionTime: before},
+		{Type: profilev1.ProfileRBACReady, Status: "True", LastTransitionTime: before},
+	}
+	c := newProfileConditions(&profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Generation: 4}})
+	c.setTrue(profilev1.ProfileTemplateReady)
+	c.setTrue(profilev1.ProfileNamespaceReady)
+	c.setTrue(profilev1.ProfileAuthorizationPolicyReady)
+	c.setTrue(profilev1.ProfileNetworkPolicyReady)
+	c.setFalse(profilev1.ProfileRBACReady, reasonRoleBindingFailed, "forbidden")
+
+	conditions := c.build(previous, now)
+	if len(conditions) != len(profileConditionTypes)+1 {
+		t.Fatalf("Expected one condition per step and Ready, got %+v", conditions)
+	}
+	for _, condition := range conditions {
+		if condition.ObservedGeneration != 4 {
+			t.Errorf("Expected observedGeneration 4, got %+v", condition)
+		}
+	}
+	if ns := findCondition(conditions, profilev1.ProfileNamespaceReady); !ns.LastTransitionTime.Equal(&before) {
+		t.Errorf("Expected the transition time to be kept, got %+v", ns)
+	}
+	if rbac := findCondition(conditions, profilev1.ProfileRBACReady); !rbac.LastTransitionTime.Equal(&now) ||
+		rbac.Reason != reasonRoleBindingFailed {
+		t.Errorf("Expected RBACReady to transition, got %+v", rbac)
+	}
+	if quota := findCondition(conditions, profilev1.ProfileQuotaReady); quota.Status != "Unknown" {
+		t.Errorf("Expected QuotaReady to be Unknown, got %+v", quota)
+	}
+	ready := findCondition(conditions, profilev1.ProfileReady)
+	if ready.Status != "False" || ready.Reason != reasonRoleBindingFailed ||
+		ready.Message != "RBACReady is False: forbidden" {
+		t.Errorf("Unexpected Ready condition %+v", ready)
+	}
+}
+
+func TestReconcileConditions(t *testing.T) {
+	labelsPath := filepath.Join(t.TempDir(), "namespace-labels.yaml")
+	if err := ioutil.WriteFile(labelsPath, []byte("katib.kubeflow.org/metrics-collector-injection: enabled\n"),
+		0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	owner := profilev1.ProfileSpec{Owner: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "user@example.com"}}
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user", Generation: 2},
+		Spec:       owner,
+	}
+	taken := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "taken", Generation: 1},
+		Spec:       owner,
+	}
+	takenNs := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "taken", Annotations: map[string]string{"owner": "other@example.com"}},
+	}
+	r := newTestReconciler(profile, taken, takenNs)
+	r.DefaultNamespaceLabelsPath = labelsPath
+	ctx := context.Background()
+
+	for _, name := range []string{profile.Name, taken.Name} {
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: name}}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	found := &profilev1.Profile{}
+	if err := r.Get(ctx, types.NamespacedName{Name: profile.Name}, found); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, condition := range found.Status.Conditions {
+		if condition.Status != "True" || condition.ObservedGeneration != 2 {
+			t.Errorf("Expected all conditions to be True, got %+v", condition)
+		}
+	}
+	if ready := findCondition(found.Status.Conditions, profilev1.ProfileReady); ready.Status != "True" {
+		t.Errorf("Expected the Profile to be Ready, got %+v", found.Status.Conditions)
+	}
+
+	if err := r.Get(ctx, types.NamespacedName{Name: taken.Name}, found); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ns := findCondition(found.Status.Conditions, profilev1.ProfileNamespaceReady)
+	if ns.Status != "False" || ns.Reason != reasonNamespaceNotOwned {
+		t.Errorf("Expected NamespaceReady to fail, got %+v", ns)
+	}
+	if rbac := findCondition(found.Status.Conditions, profilev1.ProfileRBACReady); rbac.Status != "Unknown" {
+		t.Errorf("Expected RBACReady to be Unknown, got %+v", rbac)
+	}
+
+	// A reconciliation without changes doesn't update the status.
+	version := found.ResourceVersion
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: taken.Name}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: taken.Name}, found); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if found.ResourceVersion != version {
+		t.Errorf("Expected the status not to be updated again")
+	}
+}
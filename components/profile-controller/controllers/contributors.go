@@ -0,0 +1,225 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	istioSecurity "istio.io/api/security/v1beta1"
+	istioSecurityClient "istio.io/client-go/pkg/apis/security/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CONTRIBUTOR_LABEL marks the RoleBindings and AuthorizationPolicies created
+// for spec.contributors, so that the ones of removed contributors are pruned.
+const CONTRIBUTOR_LABEL = "profiles.kubeflow.org/contributor"
+
+var nonAlphanumeric = regexp.MustCompile("[^a-zA-Z0-9]+")
+
+// contributorBindingName returns the name KFAM has always given to the
+// RoleBinding and AuthorizationPolicy of a contributor, so that the ones
+// created through KFAM before spec.contributors existed are adopted.
+func contributorBindingName(c profilev1.ProfileContributor) string {
+	name := strings.ToLower(strings.Join([]string{
+		c.Kind,
+		nonAlphanumeric.ReplaceAllString(c.Name, "-"),
+		"ClusterRole",
+		c.Role,
+	}, "-"))
+	return nonAlphanumeric.ReplaceAllString(name, "-")
+}
+
+// contributorSubject returns the RBAC subject of a contributor, with the
+// defaults of the API group and of the namespace of service accounts.
+func contributorSubject(profileIns *profilev1.Profile, c profilev1.ProfileContributor) rbacv1.Subject {
+	subject := c.Subject
+	if subject.Kind == rbacv1.ServiceAccountKind {
+		if subject.Namespace == "" {
+			subject.Namespace = profileIns.Name
+		}
+	} else if subject.APIGroup == "" {
+		subject.APIGroup = rbacv1.GroupName
+	}
+	return subject
+}
+
+// hasAuthorizationPolicy returns false for groups when no groups header is
+// configured, since the requests only carry the user id.
+func (r *ProfileReconciler) hasAuthorizationPolicy(c profilev1.ProfileContributor) bool {
+	if c.Kind == rbacv1.GroupKind {
+		return r.GroupsHeader != ""
+	}
+	return c.Kind == rbacv1.UserKind || c.Kind == rbacv1.ServiceAccountKind
+}
+
+// getContributorAuthorizationPolicy returns the AuthorizationPolicy that lets
+// the contributor reach the services of the namespace.
+func (r *ProfileReconciler) getContributorAuthorizationPolicy(profileIns *profilev1.Profile,
+	c profilev1.ProfileContributor) istioSecurity.AuthorizationPolicy {
+	if c.Kind == rbacv1.ServiceAccountKind {
+		subject := contributorSubject(profileIns, c)
+		return istioSecurity.AuthorizationPolicy{
+			Rules: []*istioSecurity.Rule{
+				{
+					From: []*istioSecurity.Rule_From{{
+						Source: &istioSecurity.Source{
+							Principals: []string{
+								fmt.Sprintf("cluster.local/ns/%s/sa/%s", subject.Namespace, subject.Name),
+							},
+						},
+					}},
+				},
+			},
+		}
+	}
+
+	istioIGWPrincipal := GetEnvDefault(
+		"ISTIO_INGRESS_GATEWAY_PRINCIPAL",
+		"cluster.local/ns/istio-system/sa/istio-ingressgateway-service-account")
+
+	kfpUIPrincipal := GetEnvDefault(
+		"KFP_UI_PRINCIPAL",
+		"cluster.local/ns/kubeflow/sa/ml-pipeline-ui")
+
+	condition := &istioSecurity.Condition{
+		Key:    fmt.Sprintf("request.headers[%v]", r.UserIdHeader),
+		Values: []string{r.UserIdPrefix + c.Name},
+	}
+	if c.Kind == rbacv1.GroupKind {
+		condition = r.getGroupsCondition([]string{c.Name})
+	}
+
+	return istioSecurity.AuthorizationPolicy{
+		Rules: []*istioSecurity.Rule{
+			{
+				When: []*istioSecurity.Condition{condition},
+				From: []*istioSecurity.Rule_From{{
+					Source: &istioSecurity.Source{
+						Principals: []string{
+							istioIGWPrincipal,
+							kfpUIPrincipal,
+						},
+					},
+				}},
+			},
+		},
+	}
+}
+
+// reconcileContributors creates a RoleBinding and an AuthorizationPolicy for
+// every contributor of the Profile, and deletes the ones of the contributors
+// that were removed. Bindings created through KFAM without the label are
+// left alone.
+func (r *ProfileReconciler) reconcileContributors(ctx context.Context, profileIns *profilev1.Profile) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+
+	roleBindingNames := map[string]bool{}
+	policyNames := map[string]bool{}
+	for _, c := range profileIns.Spec.Contributors {
+		name := contributorBindingName(c)
+		objectMeta := metav1.ObjectMeta{
+			Annotations: map[string]string{USER: c.Name, ROLE: c.Role},
+			Labels:      map[string]string{CONTRIBUTOR_LABEL: "true"},
+			Name:        name,
+			Namespace:   profileIns.Name,
+		}
+
+		roleBinding := &rbacv1.RoleBinding{
+			ObjectMeta: objectMeta,
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "ClusterRole",
+				Name:     "kubeflow-" + c.Role,
+			},
+			Subjects: []rbacv1.Subject{contributorSubject(profileIns, c)},
+		}
+		if err := r.updateRoleBinding(profileIns, roleBinding); err != nil {
+			return err
+		}
+		roleBindingNames[name] = true
+
+		if r.hasAuthorizationPolicy(c) {
+			istioAuth := &istioSecurityClient.AuthorizationPolicy{
+				ObjectMeta: *objectMeta.DeepCopy(),
+				Spec:       r.getContributorAuthorizationPolicy(profileIns, c),
+			}
+			if err := r.updateAuthorizationPolicy(profileIns, istioAuth); err != nil {
+				return err
+			}
+			policyNames[name] = true
+		}
+	}
+
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := r.List(ctx, roleBindings, client.InNamespace(profileIns.Name),
+		client.MatchingLabels{CONTRIBUTOR_LABEL: "true"}); err != nil {
+		return err
+	}
+	for i := range roleBindings.Items {
+		roleBinding := &roleBindings.Items[i]
+		if roleBindingNames[roleBinding.Name] {
+			continue
+		}
+		logger.Info("Deleting RoleBinding of removed contributor", "namespace", roleBinding.Namespace,
+			"name", roleBinding.Name)
+		if err := r.Delete(ctx, roleBinding); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	policies := &istioSecurityClient.AuthorizationPolicyList{}
+	if err := r.List(ctx, policies, client.InNamespace(profileIns.Name),
+		client.MatchingLabels{CONTRIBUTOR_LABEL: "true"}); err != nil {
+		return err
+	}
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+		if policyNames[policy.Name] {
+			continue
+		}
+		logger.Info("Deleting AuthorizationPolicy of removed contributor", "namespace", policy.Namespace,
+			"name", policy.Name)
+		if err := r.Delete(ctx, policy); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeStringMap sets the entries of src in dst and returns true if dst
+// changed.
+func mergeStringMap(dst *map[string]string, src map[string]string) bool {
+	changed := false
+	for k, v := range src {
+		if current, ok := (*dst)[k]; ok && current == v {
+			continue
+		}
+		if *dst == nil {
+			*dst = map[string]string{}
+		}
+		(*dst)[k] = v
+		changed = true
+	}
+	return changed
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	istioSecurityClient "istio.io/client-go/pkg/apis/security/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestReconciler(objects ...client.Object) *ProfileReconciler {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = profilev1.AddToScheme(scheme)
+	_ = istioSecurityClient.AddToScheme(scheme)
+	// The kinds are namespaced, but for the cluster scoped ones the tests use.
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for gvk := range scheme.AllKnownTypes() {
+		scope := meta.RESTScopeNamespace
+		switch gvk.Kind {
+		case "Namespace", "ClusterRole", "ClusterRoleBinding", "Profile", "ProfilePlugin", "ProfileTemplate":
+			scope = meta.RESTScopeRoot
+		}
+		mapper.Add(gvk, scope)
+	}
+	return &ProfileReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).WithObjects(objects...).
+			Build(),
+		Scheme:       scheme,
+		Log:          ctrl.Log,
+		UserIdHeader: "kubeflow-userid",
+		Recorder:     record.NewFakeRecorder(100),
+	}
+}
+
+func TestContributorBindingName(t *testing.T) {
+	c := profilev1.ProfileContributor{
+		Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "lalith.vaka@zq.msds.kp.org"},
+		Role:    "edit",
+	}
+	// Same name as the bindings created through KFAM.
+	expected := "user-lalith-vaka-zq-msds-kp-org-clusterrole-edit"
+	if name := contributorBindingName(c); name != expected {
+		t.Errorf("Expected %s, got %s", expected, name)
+	}
+}
+
+func TestReconcileContributors(t *testing.T) {
+	namespace := "kubeflow-user"
+	removed := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "user-removed-example-com-clusterrole-view",
+			Namespace: namespace,
+			Labels:    map[string]string{CONTRIBUTOR_LABEL: "true"},
+		},
+		RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: kubeflowView},
+	}
+	legacy := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "user-legacy-example-com-clusterrole-view",
+			Namespace:   namespace,
+			Annotations: map[string]string{USER: "legacy@example.com", ROLE: "view"},
+		},
+		RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: kubeflowView},
+	}
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: namespace},
+		Spec: profilev1.ProfileSpec{
+			Contributors: []profilev1.ProfileContributor{
+				{Subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice@example.com"}, Role: "edit"},
+				{Subject: rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "ml-team"}, Role: "view"},
+				{Subject: rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "pipeline-runner"}, Role: "admin"},
+			},
+		},
+	}
+	r := newTestReconciler(profile, removed, legacy)
+	ctx := context.Background()
+
+	if err := r.reconcileContributors(ctx, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, name := range []string{
+		"user-alice-example-com-clusterrole-edit",
+		"group-ml-team-clusterrole-view",
+		"serviceaccount-pipeline-runner-clusterrole-admin",
+		legacy.Name,
+	} {
+		if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &rbacv1.RoleBinding{}); err != nil {
+			t.Errorf("Expected RoleBinding %s: %v", name, err)
+		}
+	}
+	err := r.Get(ctx, types.NamespacedName{Name: removed.Name, Namespace: namespace}, &rbacv1.RoleBinding{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("Expected RoleBinding %s to be pruned, got %v", removed.Name, err)
+	}
+
+	sa := &rbacv1.RoleBinding{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name: "serviceaccount-pipeline-runner-clusterrole-admin", Namespace: namespace}, sa); err == nil {
+		if sa.RoleRef.Name != kubeflowAdmin || sa.Subjects[0].Namespace != namespace {
+			t.Errorf("Unexpected RoleBinding for service account: %+v", sa)
+		}
+	}
+
+	policies := &istioSecurityClient.AuthorizationPolicyList{}
+	if err := r.List(ctx, policies, client.InNamespace(namespace)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Groups don't get an AuthorizationPolicy.
+	if len(policies.Items) != 2 {
+		t.Errorf("Expected 2 AuthorizationPolicies, got %d", len(policies.Items))
+	}
+
+	// Removing every contributor prunes all their objects.
+	profile.Spec.Contributors = nil
+	if err := r.reconcileContributors(ctx, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := r.List(ctx, roleBindings, client.InNamespace(namespace)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(roleBindings.Items) != 1 || roleBindings.Items[0].Name != legacy.Name {
+		t.Errorf("Expected only the legacy RoleBinding to be left, got %d", len(roleBindings.Items))
+	}
+	if err := r.List(ctx, policies, client.InNamespace(namespace)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(policies.Items) != 0 {
+		t.Errorf("Expected the AuthorizationPolicies to be pruned, got %d", len(policies.Items))
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NOTEBOOK_STOP_ANNOTATION of the notebook controller, which scales the
+// Notebooks that have it to zero.
+const NOTEBOOK_STOP_ANNOTATION = "kubeflow-resource-stopped"
+
+// The Notebooks are handled as unstructured objects, since the notebook
+// controller may not be installed.
+var notebookListGVK = schema.GroupVersionKind{
+	Group:   "kubeflow.org",
+	Version: "v1beta1",
+	Kind:    "NotebookList",
+}
+
+// getExpirationTime returns the time the Profile expires at, nil if it
+// doesn't expire.
+func getExpirationTime(profileIns *profilev1.Profile) *time.Time {
+	if profileIns.Spec.ExpiresAt != nil {
+		expiresAt := profileIns.Spec.ExpiresAt.Time
+		return &expiresAt
+	}
+	if profileIns.Spec.TTL != nil {
+		expiresAt := profileIns.CreationTimestamp.Add(profileIns.Spec.TTL.Duration)
+		return &expiresAt
+	}
+	return nil
+}
+
+// getPreviousExpiringReason returns the reason of the Expiring condition the
+// Profile had, so that its events are only emitted once.
+func getPreviousExpiringReason(profileIns *profilev1.Profile) string {
+	for _, c := range profileIns.Status.Conditions {
+		if c.Type == profilev1.ProfileExpiring {
+			return c.Reason
+		}
+	}
+	return ""
+}
+
+// reconcileExpiration sets the Expiring condition of the Profile, warns its
+// users with an event once the expiration is near, stops its Notebooks once
+// it expired and deletes it after the grace period. The deletion runs the
+// finalizer, which revokes the plugins. It returns when the Profile must be
+// reconciled again, and whether it was deleted.
+func (r *ProfileReconciler) reconcileExpiration(ctx context.Context, profileIns *profilev1.Profile,
+	conditions *profileConditions, now time.Time) (time.Duration, bool, error) {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	expiresAt := getExpirationTime(profileIns)
+	if expiresAt == nil || !profileIns.DeletionTimestamp.IsZero() {
+		return 0, false, nil
+	}
+	deleteAt := expiresAt.Add(r.ExpirationGracePeriod)
+	previousReason := getPreviousExpiringReason(profileIns)
+
+	switch {
+	case now.Before(expiresAt.Add(-r.ExpirationWarning)):
+		conditions.setExpiring(corev1.ConditionFalse, reasonExpirationScheduled,
+			fmt.Sprintf("The profile expires at %v", expiresAt.Format(time.RFC3339)))
+		return expiresAt.Add(-r.ExpirationWarning).Sub(now), false, nil
+	case now.Before(*expiresAt):
+		message := fmt.Sprintf("The profile expires at %v and is deleted at %v", expiresAt.Format(time.RFC3339),
+			deleteAt.Format(time.RFC3339))
+		conditions.setExpiring(corev1.ConditionTrue, reasonExpiringSoon, message)
+		if previousReason != reasonExpiringSoon {
+			r.Recorder.Event(profileIns, corev1.EventTypeWarning, reasonExpiringSoon, message)
+		}
+		return expiresAt.Sub(now), false, nil
+	case now.Before(deleteAt):
+		message := fmt.Sprintf("The profile expired at %v and is deleted at %v", expiresAt.Format(time.RFC3339),
+			deleteAt.Format(time.RFC3339))
+		conditions.setExpiring(corev1.ConditionTrue, reasonExpired, message)
+		if previousReason != reasonExpired {
+			r.Recorder.Event(profileIns, corev1.EventTypeWarning, reasonExpired, message)
+		}
+		if r.StopNotebooksOnExpiration {
+			if err := r.stopNotebooks(ctx, profileIns, now); err != nil {
+				return 0, false, err
+			}
+		}
+		return deleteAt.Sub(now), false, nil
+	}
+
+	logger.Info("Deleting expired profile", "expiresAt", expiresAt.Format(time.RFC3339))
+	r.Recorder.Event(profileIns, corev1.EventTypeNormal, "Deleting",
+		fmt.Sprintf("The profile expired at %v", expiresAt.Format(time.RFC3339)))
+	if err := r.Delete(ctx, profileIns); err != nil {
+		return 0, false, client.IgnoreNotFound(err)
+	}
+	IncRequestCounter("delete expired profile")
+	return 0, true, nil
+}
+
+// stopNotebooks sets the stop annotation on the Notebooks of the namespace
+// of the Profile that don't have it yet.
+func (r *ProfileReconciler) stopNotebooks(ctx context.Context, profileIns *profilev1.Profile, now time.Time) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	notebooks := &unstructured.UnstructuredList{}
+	notebooks.SetGroupVersionKind(notebookListGVK)
+	if err := r.List(ctx, notebooks, client.InNamespace(profileIns.Name)); err != nil {
+		if meta.IsNoMatchError(err) {
+			// The notebook controller isn't installed.
+			return nil
+		}
+		return err
+	}
+	for i := range notebooks.Items {
+		notebook := &notebooks.Items[i]
+		if _, ok := notebook.GetAnnotations()[NOTEBOOK_STOP_ANNOTATION]; ok {
+			continue
+		}
+		patch := client.MergeFrom(notebook.DeepCopy())
+		annotations := notebook.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[NOTEBOOK_STOP_ANNOTATION] = now.UTC().Format(time.RFC3339)
+		notebook.SetAnnotations(annotations)
+		logger.Info("Stopping Notebook of expired profile", "namespace", notebook.GetNamespace(),
+			"name", notebook.GetName())
+		if err := r.Patch(ctx, notebook, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
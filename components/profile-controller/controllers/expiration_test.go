@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestReconcileExpiration(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "workshop-1", CreationTimestamp: metav1.NewTime(now.Add(-24 * time.Hour))},
+		Spec:       profilev1.ProfileSpec{TTL: &metav1.Duration{Duration: 14 * 24 * time.Hour}},
+	}
+	notebook := &unstructured.Unstructured{}
+	notebook.SetGroupVersionKind(notebookListGVK.GroupVersion().WithKind("Notebook"))
+	notebook.SetName("notebook")
+	notebook.SetNamespace(profile.Name)
+	r := newTestReconciler(profile, notebook)
+	r.Scheme.AddKnownTypeWithName(notebook.GroupVersionKind(), &unstructured.Unstructured{})
+	r.Scheme.AddKnownTypeWithName(notebookListGVK, &unstructured.UnstructuredList{})
+	r.ExpirationWarning = 72 * time.Hour
+	r.ExpirationGracePeriod = 24 * time.Hour
+	r.StopNotebooksOnExpiration = true
+	recorder := r.Recorder.(*record.FakeRecorder)
+	ctx := context.Background()
+	expiresAt := now.Add(13 * 24 * time.Hour)
+
+	for _, test := range []struct {
+		now          time.Time
+		status       string
+		reason       string
+		requeueAfter time.Duration
+		event        string
+	}{
+		{now, "False", reasonExpirationScheduled, 10 * 24 * time.Hour, ""},
+		{expiresAt.Add(-time.Hour), "True", reasonExpiringSoon, time.Hour, reasonExpiringSoon},
+		{expiresAt.Add(-time.Minute), "True", reasonExpiringSoon, time.Minute, ""},
+		{expiresAt.Add(time.Hour), "True", reasonExpired, 23 * time.Hour, reasonExpired},
+	} {
+		conditions := newProfileConditions(profile)
+		requeueAfter, deleted, err := r.reconcileExpiration(ctx, profile, conditions, test.now)
+		if err != nil || deleted {
+			t.Fatalf("Unexpected result at %v: %v, %v", test.now, deleted, err)
+		}
+		if requeueAfter != test.requeueAfter {
+			t.Errorf("Expected a requeue after %v at %v, got %v", test.requeueAfter, test.now, requeueAfter)
+		}
+		if c := conditions.expiring; c.Status != test.status || c.Reason != test.reason {
+			t.Errorf("Unexpected condition at %v: %+v", test.now, c)
+		}
+		select {
+		case event := <-recorder.Events:
+			if test.event == "" || !strings.Contains(event, test.event) {
+				t.Errorf("Unexpected event at %v: %v", test.now, event)
+			}
+		default:
+			if test.event != "" {
+				t.Errorf("Expected a %v event at %v", test.event, test.now)
+			}
+		}
+		profile.Status.Conditions = conditions.build(nil, metav1.NewTime(test.now))
+	}
+
+	if err := r.Get(ctx, types.NamespacedName{Name: notebook.GetName(), Namespace: profile.Name}, notebook); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := notebook.GetAnnotations()[NOTEBOOK_STOP_ANNOTATION]; !ok {
+		t.Errorf("Expected the Notebook to be stopped, got %v", notebook.GetAnnotations())
+	}
+}
+
+func TestReconcileExpiredProfile(t *testing.T) {
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "workshop-1"},
+		Spec: profilev1.ProfileSpec{
+			Owner:     rbacv1.Subject{Kind: rbacv1.UserKind, Name: "user@example.com"},
+			ExpiresAt: &metav1.Time{Time: time.Now().Add(-48 * time.Hour)},
+		},
+	}
+	r := newTestReconciler(profile)
+	r.DefaultNamespaceLabelsPath = newTestLabelsFile(t)
+	r.ExpirationGracePeriod = 24 * time.Hour
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: profile.Name}}
+	found := &profilev1.Profile{}
+
+	// The Profile is deleted once the grace period is over.
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Get(ctx, request.NamespacedName, found); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the expired Profile to be deleted, got %v", err)
+	}
+
+	// A Profile with the finalizer is deleted once the finalizer ran.
+	profile = &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "workshop-2", Finalizers: []string{PROFILEFINALIZER}},
+		Spec:       profile.Spec,
+	}
+	if err := r.Create(ctx, profile); err != nil {
+		t.Fatal(err)
+	}
+	request.Name = profile.Name
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Get(ctx, request.NamespacedName, found); err != nil || found.DeletionTimestamp.IsZero() {
+		t.Fatalf("Expected the Profile to be deleting, got %v", err)
+	}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Get(ctx, request.NamespacedName, found); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the finalizer to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/yaml"
+)
+
+const KFLIMITRANGE = "kf-limit-range"
+
+// readDefaultLimitRangeFromFile reads the LimitRange of the Profiles without
+// spec.limitRange. There is none if the file doesn't exist or is empty.
+func readDefaultLimitRangeFromFile(path string) (*corev1.LimitRangeSpec, error) {
+	if path == "" {
+		return nil, nil
+	}
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	limitRange := &corev1.LimitRangeSpec{}
+	if err := yaml.UnmarshalStrict(dat, limitRange); err != nil {
+		return nil, errors.Wrapf(err, "Unable to parse default limit range %s", path)
+	}
+	if len(limitRange.Limits) == 0 {
+		return nil, nil
+	}
+	return limitRange, nil
+}
+
+// getProfileLimitRange returns the LimitRange of the Profile, the cluster
+// default if it has none, or nil if the namespace shouldn't have any.
+func getProfileLimitRange(profileIns *profilev1.Profile, defaultLimitRange *corev1.LimitRangeSpec) *corev1.LimitRangeSpec {
+	limitRange := profileIns.Spec.LimitRange
+	if limitRange == nil {
+		limitRange = defaultLimitRange
+	}
+	if limitRange == nil || len(limitRange.Limits) == 0 {
+		return nil
+	}
+	return limitRange
+}
+
+// setLimitRangeDefaults sets the defaults the API server sets on the limits of
+// the containers, so that the LimitRange read back compares equal: the default
+// limits to the max, and the default requests to the default limits, or else to
+// the min.
+func setLimitRangeDefaults(spec *corev1.LimitRangeSpec) {
+	for i := range spec.Limits {
+		item := &spec.Limits[i]
+		if item.Type != corev1.LimitTypeContainer {
+			continue
+		}
+		if item.Default == nil {
+			item.Default = corev1.ResourceList{}
+		}
+		if item.DefaultRequest == nil {
+			item.DefaultRequest = corev1.ResourceList{}
+		}
+		for key, value := range item.Max {
+			if _, ok := item.Default[key]; !ok {
+				item.Default[key] = value.DeepCopy()
+			}
+		}
+		for key, value := range item.Default {
+			if _, ok := item.DefaultRequest[key]; !ok {
+				item.DefaultRequest[key] = value.DeepCopy()
+			}
+		}
+		for key, value := range item.Min {
+			if _, ok := item.DefaultRequest[key]; !ok {
+				item.DefaultRequest[key] = value.DeepCopy()
+			}
+		}
+	}
+}
+
+// reconcileLimitRange creates or updates the LimitRange KFLIMITRANGE of the
+// Profile namespace, and deletes it when the Profile has none.
+func (r *ProfileReconciler) reconcileLimitRange(ctx context.Context, profileIns *profilev1.Profile) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	defaultLimitRange, err := readDefaultLimitRangeFromFile(r.DefaultLimitRangePath)
+	if err != nil {
+		return err
+	}
+	spec := getProfileLimitRange(profileIns, defaultLimitRange)
+
+	found := &corev1.LimitRange{}
+	err = r.Get(ctx, types.NamespacedName{Name: KFLIMITRANGE, Namespace: profileIns.Name}, found)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	if spec == nil {
+		if exists {
+			logger.Info("Deleting LimitRange", "namespace", found.Namespace, "name", found.Name)
+			if err := r.Delete(ctx, found); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	limitRange := &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      KFLIMITRANGE,
+			Namespace: profileIns.Name,
+		},
+		Spec: *spec.DeepCopy(),
+	}
+	setLimitRangeDefaults(&limitRange.Spec)
+	if err := controllerutil.SetControllerReference(profileIns, limitRange, r.Scheme); err != nil {
+		return err
+	}
+	if !exists {
+		logger.Info("Creating LimitRange", "namespace", limitRange.Namespace, "name", limitRange.Name)
+		return r.Create(ctx, limitRange)
+	}
+	if !equality.Semantic.DeepEqual(limitRange.Spec, found.Spec) {
+		found.Spec = limitRange.Spec
+		logger.Info("Updating LimitRange", "namespace", limitRange.Namespace, "name", limitRange.Name)
+		return r.Update(ctx, found)
+	}
+	return nil
+}
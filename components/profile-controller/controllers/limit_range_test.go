@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReconcileLimitRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limit-range.yaml")
+	if err := ioutil.WriteFile(path, []byte(`
+limits:
+- type: Container
+  defaultRequest:
+    cpu: 100m
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	namespace := "kubeflow-user"
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	r := newTestReconciler(profile)
+	r.DefaultLimitRangePath = path
+	ctx := context.Background()
+	key := types.NamespacedName{Name: KFLIMITRANGE, Namespace: namespace}
+
+	// The cluster default applies to Profiles without limitRange.
+	if err := r.reconcileLimitRange(ctx, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	found := &corev1.LimitRange{}
+	if err := r.Get(ctx, key, found); err != nil {
+		t.Fatalf("Expected the default LimitRange: %v", err)
+	}
+	if cpu := found.Spec.Limits[0].DefaultRequest[corev1.ResourceCPU]; cpu.String() != "100m" {
+		t.Errorf("Unexpected LimitRange %+v", found.Spec)
+	}
+
+	// The one of the Profile overrides it.
+	profile.Spec.LimitRange = &corev1.LimitRangeSpec{
+		Limits: []corev1.LimitRangeItem{{
+			Type:    corev1.LimitTypeContainer,
+			Default: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+		}},
+	}
+	if err := r.reconcileLimitRange(ctx, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Get(ctx, key, found); err != nil {
+		t.Fatal(err)
+	}
+	// The default request is defaulted to the default limit, like the API server does.
+	if memory := found.Spec.Limits[0].DefaultRequest[corev1.ResourceMemory]; memory.String() != "1Gi" {
+		t.Errorf("Expected the LimitRange of the Profile, got %+v", found.Spec)
+	}
+	// The LimitRange read back with the defaults of the API server isn't updated again.
+	resourceVersion := found.ResourceVersion
+	if err := r.reconcileLimitRange(ctx, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Get(ctx, key, found); err != nil {
+		t.Fatal(err)
+	}
+	if found.ResourceVersion != resourceVersion {
+		t.Errorf("Expected the LimitRange not to be updated, got resource version %v, was %v",
+			found.ResourceVersion, resourceVersion)
+	}
+
+	// An empty list of limits opts out of the cluster default.
+	profile.Spec.LimitRange = &corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{}}
+	if err := r.reconcileLimitRange(ctx, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Get(ctx, key, found); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the LimitRange to be deleted, got %v", err)
+	}
+}
+
+func TestReadDefaultLimitRangeFromFile(t *testing.T) {
+	dir := t.TempDir()
+	if limitRange, err := readDefaultLimitRangeFromFile(filepath.Join(dir, "missing.yaml")); limitRange != nil || err != nil {
+		t.Errorf("Expected no default LimitRange, got %v, %v", limitRange, err)
+	}
+	path := filepath.Join(dir, "limit-range.yaml")
+	if err := ioutil.WriteFile(path, []byte(`limit: []`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readDefaultLimitRangeFromFile(path); err == nil {
+		t.Errorf("Expected an error for an unknown field")
+	}
+}
+
+func TestSetLimitRangeDefaults(t *testing.T) {
+	spec := &corev1.LimitRangeSpec{
+		Limits: []corev1.LimitRangeItem{{
+			Type: corev1.LimitTypeContainer,
+			Max:  corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			Min:  corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+		}, {
+			Type: corev1.LimitTypePod,
+			Max:  corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+		}},
+	}
+	setLimitRangeDefaults(spec)
+	container := spec.Limits[0]
+	if cpu := container.Default[corev1.ResourceCPU]; cpu.String() != "2" {
+		t.Errorf("Expected the default limit to be the max, got %v", container.Default)
+	}
+	if cpu := container.DefaultRequest[corev1.ResourceCPU]; cpu.String() != "2" {
+		t.Errorf("Expected the default request to be the default limit, got %v", container.DefaultRequest)
+	}
+	if memory := container.DefaultRequest[corev1.ResourceMemory]; memory.String() != "64Mi" {
+		t.Errorf("Expected the default request to be the min, got %v", container.DefaultRequest)
+	}
+	if spec.Limits[1].Default != nil {
+		t.Errorf("Expected the limits of the pods to be left as is, got %+v", spec.Limits[1])
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"time"
 
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 )
 
 const PROFILE = "profile_controller"
@@ -22,6 +24,10 @@ const SEVERITY_MAJOR = "major"
 const SEVERITY_CRITICAL = "critical"
 const MAX_TAG_LEN = 30
 
+// Profile and resource of the quota gauges
+const PROFILE_NAME = "profile"
+const RESOURCE = "resource"
+
 var (
 	// Counter metrics
 	// num of requests counter vec
@@ -42,6 +48,16 @@ var (
 		Name: "service_heartbeat",
 		Help: "Heartbeat signal every 10 seconds indicating pods are alive.",
 	}, []string{COMPONENT, SEVERITY})
+
+	// Gauge metrics of the ResourceQuota of every profile
+	quotaHardGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "profile_quota_hard",
+		Help: "Hard limit of the ResourceQuota of the profile namespace",
+	}, []string{PROFILE_NAME, RESOURCE})
+	quotaUsedGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "profile_quota_used",
+		Help: "Usage of the ResourceQuota of the profile namespace",
+	}, []string{PROFILE_NAME, RESOURCE})
 )
 
 func init() {
@@ -49,6 +65,8 @@ func init() {
 	metrics.Registry.MustRegister(requestCounter)
 	metrics.Registry.MustRegister(requestErrorCounter)
 	metrics.Registry.MustRegister(serviceHeartbeat)
+	metrics.Registry.MustRegister(quotaHardGauge)
+	metrics.Registry.MustRegister(quotaUsedGauge)
 	// Count heartbeat
 	go func() {
 		labels := prometheus.Labels{COMPONENT: PROFILE, SEVERITY: SEVERITY_CRITICAL}
@@ -75,3 +93,34 @@ func IncRequestErrorCounter(kind string, severity string) {
 	log.Errorf("Failed request with kind: %v", kind)
 	requestErrorCounter.With(labels).Inc()
 }
+
+// SetQuotaGauges exports the quota of the profile, and removes the resources
+// of the previous quota that it no longer limits.
+func SetQuotaGauges(profile string, previous *profilev1.ProfileQuotaStatus, current *profilev1.ProfileQuotaStatus) {
+	hard := corev1.ResourceList{}
+	used := corev1.ResourceList{}
+	if current != nil {
+		hard = current.Hard
+		used = current.Used
+	}
+	if previous != nil {
+		for name := range previous.Hard {
+			if _, ok := hard[name]; !ok {
+				quotaHardGauge.Delete(prometheus.Labels{PROFILE_NAME: profile, RESOURCE: string(name)})
+			}
+		}
+		for name := range previous.Used {
+			if _, ok := used[name]; !ok {
+				quotaUsedGauge.Delete(prometheus.Labels{PROFILE_NAME: profile, RESOURCE: string(name)})
+			}
+		}
+	}
+	for name, quantity := range hard {
+		quotaHardGauge.With(prometheus.Labels{PROFILE_NAME: profile, RESOURCE: string(name)}).
+			Set(quantity.AsApproximateFloat64())
+	}
+	for name, quantity := range used {
+		quotaUsedGauge.With(prometheus.Labels{PROFILE_NAME: profile, RESOURCE: string(name)}).
+			Set(quantity.AsApproximateFloat64())
+	}
+}
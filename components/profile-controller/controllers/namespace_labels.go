@@ -0,0 +1,233 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// allProfilesKey is queued when the labels file changed, its worker queues
+// every Profile. The Profiles are cluster scoped, so no Profile has this key.
+const allProfilesKey = "/"
+
+// diffNamespaceLabels returns the keys whose value differs between the label
+// sets, including the added and the removed ones.
+func diffNamespaceLabels(previous map[string]string, current map[string]string) []string {
+	keys := []string{}
+	for k, v := range current {
+		if old, ok := previous[k]; !ok || old != v {
+			keys = append(keys, k)
+		}
+	}
+	for k := range previous {
+		if _, ok := current[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// namespaceLabelsSyncer applies the changes of the namespace labels file to
+// the namespaces of the Profiles. It only patches their labels, instead of
+// reconciling the Profiles, so that a change of the file doesn't run every
+// step of every Profile, like the plugins and their cloud calls. The
+// namespaces are patched from a rate limited queue.
+type namespaceLabelsSyncer struct {
+	reconciler *ProfileReconciler
+	queue      workqueue.RateLimitingInterface
+
+	mu     sync.Mutex
+	labels map[string]string
+	// changed are the keys the file added, changed or removed since the
+	// syncer started. A namespace queued before a later change has to catch
+	// up with all of them.
+	changed map[string]bool
+}
+
+// newNamespaceLabelsSyncer returns a syncer patching at most qps namespaces
+// per second, with bursts of burst namespaces.
+func newNamespaceLabelsSyncer(r *ProfileReconciler, qps float64, burst int) *namespaceLabelsSyncer {
+	rateLimiter := workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(5*time.Millisecond, 1000*time.Second),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(qps), burst)},
+	)
+	return &namespaceLabelsSyncer{
+		reconciler: r,
+		queue:      workqueue.NewNamedRateLimitingQueue(rateLimiter, "namespace-labels"),
+		labels:     r.readDefaultLabelsFromFile(r.DefaultNamespaceLabelsPath),
+		changed:    map[string]bool{},
+	}
+}
+
+// fileChanged reads the labels file again, and queues the namespaces if the
+// labels changed.
+func (s *namespaceLabelsSyncer) fileChanged() {
+	labels := s.reconciler.readDefaultLabelsFromFile(s.reconciler.DefaultNamespaceLabelsPath)
+	s.mu.Lock()
+	changed := diffNamespaceLabels(s.labels, labels)
+	s.labels = labels
+	for _, k := range changed {
+		s.changed[k] = true
+	}
+	s.mu.Unlock()
+	if len(changed) == 0 {
+		return
+	}
+	s.reconciler.Log.Info("Namespace labels changed, updating the namespaces", "labels", changed)
+	s.queue.Add(allProfilesKey)
+}
+
+// Start processes the queue until the context is done.
+func (s *namespaceLabelsSyncer) Start(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		s.queue.ShutDown()
+	}()
+	for s.processNextItem(ctx) {
+	}
+	return nil
+}
+
+func (s *namespaceLabelsSyncer) processNextItem(ctx context.Context) bool {
+	item, shutdown := s.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer s.queue.Done(item)
+	name := item.(string)
+	s.mu.Lock()
+	labels := s.labels
+	changed := []string{}
+	for k := range s.changed {
+		changed = append(changed, k)
+	}
+	s.mu.Unlock()
+
+	var err error
+	if name == allProfilesKey {
+		err = s.queueProfiles(ctx)
+	} else {
+		err = s.reconciler.syncNamespaceLabels(ctx, name, labels, changed)
+	}
+	if err != nil {
+		s.reconciler.Log.Error(err, "Failed to update namespace labels", "profile", name)
+		IncRequestErrorCounter("error syncing namespace labels", SEVERITY_MINOR)
+		s.queue.AddRateLimited(item)
+		return true
+	}
+	s.queue.Forget(item)
+	return true
+}
+
+// queueProfiles queues every Profile, through the rate limiter.
+func (s *namespaceLabelsSyncer) queueProfiles(ctx context.Context) error {
+	profileList := &profilev1.ProfileList{}
+	if err := s.reconciler.List(ctx, profileList); err != nil {
+		return err
+	}
+	for _, p := range profileList.Items {
+		s.queue.AddRateLimited(p.Name)
+	}
+	return nil
+}
+
+// syncNamespaceLabels patches the labels of the namespace of the Profile with
+// the default labels, like a reconciliation of the Profile does, but the keys
+// the file changed are overwritten with their new value, and the ones it
+// removed are deleted. The labels of spec.namespaceMetadata and the Pod
+// Security labels keep their precedence. Nothing is done if the Profile
+// doesn't own its namespace.
+func (r *ProfileReconciler) syncNamespaceLabels(ctx context.Context, name string,
+	defaultLabels map[string]string, changed []string) error {
+	logger := r.Log.WithValues("profile", name)
+	profileIns := &profilev1.Profile{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name}, profileIns); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name}, ns); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	owner, ok := ns.Annotations["owner"]
+	if !metav1.IsControlledBy(ns, profileIns) && !(ok && isProfileOwner(profileIns, owner)) {
+		return nil
+	}
+	template, err := r.getProfileTemplate(ctx, profileIns)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// The Profile is reconciled once the template is created.
+			return nil
+		}
+		return err
+	}
+	labels := getTemplateNamespaceLabels(defaultLabels, template)
+
+	updated := ns.DeepCopy()
+	setNamespaceLabels(updated, labels)
+	for _, k := range changed {
+		if v := labels[k]; v != "" {
+			updated.Labels[k] = v
+		} else {
+			delete(updated.Labels, k)
+		}
+	}
+	// The labels of the Profile are set again where the file doesn't set them anymore.
+	applyNamespaceMetadata(profileIns, updated, labels)
+	defaultPodSecurity, err := readDefaultPodSecurityFromFile(r.DefaultPodSecurityPath)
+	if err != nil {
+		return err
+	}
+	if getProfilePodSecurity(profileIns, defaultPodSecurity) != nil {
+		// The Pod Security labels of the Profile override the file.
+		keys := changed
+		for k := range labels {
+			keys = append(keys, k)
+		}
+		for _, k := range keys {
+			if strings.HasPrefix(k, POD_SECURITY_LABEL_PREFIX) {
+				if v, ok := ns.Labels[k]; ok {
+					updated.Labels[k] = v
+				} else {
+					delete(updated.Labels, k)
+				}
+			}
+		}
+	}
+	if equality.Semantic.DeepEqual(ns.Labels, updated.Labels) &&
+		equality.Semantic.DeepEqual(ns.Annotations, updated.Annotations) {
+		return nil
+	}
+	logger.Info("Updating namespace labels", "labels", diffNamespaceLabels(ns.Labels, updated.Labels))
+	if err := r.Patch(ctx, updated, client.MergeFrom(ns)); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	IncRequestCounter("sync namespace labels")
+	return nil
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestDiffNamespaceLabels(t *testing.T) {
+	changed := diffNamespaceLabels(
+		map[string]string{"kept": "true", "changed": "old", "removed": "true"},
+		map[string]string{"kept": "true", "changed": "new", "added": "true"},
+	)
+	sort.Strings(changed)
+	if expected := []string{"added", "changed", "removed"}; !reflect.DeepEqual(changed, expected) {
+		t.Errorf("Expected %v to change, got %v", expected, changed)
+	}
+}
+
+func TestNamespaceLabelsSyncer(t *testing.T) {
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"},
+		Spec: profilev1.ProfileSpec{
+			Owner: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "user@example.com"},
+			NamespaceMetadata: &profilev1.ProfileNamespaceMetadata{
+				Labels: map[string]string{"cost-center": "42"},
+			},
+		},
+	}
+	other := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "other",
+		Annotations: map[string]string{"owner": "other@example.com"},
+	}}
+	otherProfile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "other"},
+		Spec:       profilev1.ProfileSpec{Owner: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "user@example.com"}},
+	}
+	r := newTestReconciler(profile, otherProfile, other)
+	r.DefaultNamespaceLabelsPath = newTestLabelsFile(t)
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: profile.Name}}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	found := &profilev1.Profile{}
+	if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+		t.Fatal(err)
+	}
+	syncer := newNamespaceLabelsSyncer(r, 100, 10)
+
+	// An unchanged file queues nothing.
+	syncer.fileChanged()
+	if syncer.queue.Len() != 0 {
+		t.Errorf("Expected nothing to be queued, got %v items", syncer.queue.Len())
+	}
+
+	labels := "app.kubernetes.io/part-of: ''\nexample.com/new: enabled\ncost-center: '0'\n"
+	if err := ioutil.WriteFile(r.DefaultNamespaceLabelsPath, []byte(labels), 0644); err != nil {
+		t.Fatal(err)
+	}
+	syncer.fileChanged()
+	// The file change queues the Profiles, which are processed next.
+	for i := 0; i < 3; i++ {
+		if !syncer.processNextItem(ctx) {
+			t.Fatalf("Unexpected shutdown of the queue")
+		}
+	}
+
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: profile.Name}, ns); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{istioInjectionLabel: "enabled", "example.com/new": "enabled", "cost-center": "0"}
+	if !reflect.DeepEqual(ns.Labels, expected) {
+		t.Errorf("Expected labels %v, got %v", expected, ns.Labels)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: other.Name}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if len(ns.Labels) != 0 {
+		t.Errorf("Expected the namespace the Profile doesn't own to be left as is, got %v", ns.Labels)
+	}
+
+	// Only the namespace was updated, the Profile wasn't reconciled.
+	updated := &profilev1.Profile{}
+	if err := r.Get(ctx, request.NamespacedName, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.ResourceVersion != found.ResourceVersion {
+		t.Errorf("Expected the Profile not to be reconciled")
+	}
+	syncer.queue.ShutDown()
+}
+
+func TestSyncNamespaceLabelsChangedAndRemoved(t *testing.T) {
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"},
+		Spec: profilev1.ProfileSpec{
+			Owner: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "user@example.com"},
+			NamespaceMetadata: &profilev1.ProfileNamespaceMetadata{
+				Labels: map[string]string{"example.com/team": "ml"},
+			},
+		},
+	}
+	r := newTestReconciler(profile)
+	r.DefaultNamespaceLabelsPath = newTestLabelsFile(t)
+	labels := "example.com/x: old\nexample.com/gone: 'yes'\nexample.com/team: platform\n"
+	if err := ioutil.WriteFile(r.DefaultNamespaceLabelsPath, []byte(labels), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: profile.Name}}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	syncer := newNamespaceLabelsSyncer(r, 100, 10)
+
+	// The value of x changes, and gone and team are removed from the file.
+	if err := ioutil.WriteFile(r.DefaultNamespaceLabelsPath, []byte("example.com/x: new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	syncer.fileChanged()
+	for i := 0; i < 2; i++ {
+		if !syncer.processNextItem(ctx) {
+			t.Fatalf("Unexpected shutdown of the queue")
+		}
+	}
+
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: profile.Name}, ns); err != nil {
+		t.Fatal(err)
+	}
+	// The label of the Profile is set once the file doesn't set it anymore.
+	expected := map[string]string{istioInjectionLabel: "enabled", "example.com/x": "new", "example.com/team": "ml"}
+	if !reflect.DeepEqual(ns.Labels, expected) {
+		t.Errorf("Expected labels %v, got %v", expected, ns.Labels)
+	}
+	syncer.queue.ShutDown()
+}
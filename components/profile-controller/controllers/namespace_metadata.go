@@ -0,0 +1,179 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Annotations of the namespace listing the keys of the labels and the
+// annotations that spec.namespaceMetadata set, to remove them once they are
+// removed from the Profile.
+const (
+	NAMESPACE_LABELS_ANNOTATION      = "profiles.kubeflow.org/namespace-labels"
+	NAMESPACE_ANNOTATIONS_ANNOTATION = "profiles.kubeflow.org/namespace-annotations"
+)
+
+// Domains of the system keys, which Profiles can't set. Their subdomains are
+// protected too.
+var protectedNamespaceMetadataDomains = []string{"kubernetes.io", "k8s.io", "kubeflow.org"}
+
+// isProtectedNamespaceMetadataKey returns whether the key of a label or an
+// annotation belongs to the system.
+func isProtectedNamespaceMetadataKey(key string) bool {
+	i := strings.Index(key, "/")
+	if i < 0 {
+		return false
+	}
+	domain := key[:i]
+	for _, protected := range protectedNamespaceMetadataDomains {
+		if domain == protected || strings.HasSuffix(domain, "."+protected) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyNamespaceMetadata sets the labels and annotations of
+// spec.namespaceMetadata on the namespace, and removes the ones it set before
+// that the Profile doesn't have anymore. The Profile overrides the labels set
+// when the namespace was created, like istio-injection, but not the system
+// keys, the keys of defaultLabels (the labels file and the template) nor the
+// owner annotation. A label of the Profile whose key is in defaultLabels gets
+// the default value, and stays recorded if the Profile set it before, so that
+// it is removed with the Profile label once the default is gone. It returns
+// the keys it ignored.
+func applyNamespaceMetadata(profileIns *profilev1.Profile, ns *corev1.Namespace,
+	defaultLabels map[string]string) []string {
+	labels := map[string]string{}
+	annotations := map[string]string{}
+	if metadata := profileIns.Spec.NamespaceMetadata; metadata != nil {
+		labels = metadata.Labels
+		annotations = metadata.Annotations
+	}
+	ignored := []string{}
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+
+	previousLabels := map[string]bool{}
+	for _, k := range getAppliedNamespaceMetadataKeys(ns, NAMESPACE_LABELS_ANNOTATION) {
+		previousLabels[k] = true
+	}
+	appliedLabels := []string{}
+	for k, v := range labels {
+		if isProtectedNamespaceMetadataKey(k) {
+			ignored = append(ignored, "label "+k)
+			continue
+		}
+		if defaultValue, ok := defaultLabels[k]; ok {
+			ignored = append(ignored, "label "+k)
+			if defaultValue == "" {
+				delete(ns.Labels, k)
+			} else {
+				ns.Labels[k] = defaultValue
+			}
+			if previousLabels[k] {
+				appliedLabels = append(appliedLabels, k)
+			}
+			continue
+		}
+		ns.Labels[k] = v
+		appliedLabels = append(appliedLabels, k)
+	}
+	for _, k := range getAppliedNamespaceMetadataKeys(ns, NAMESPACE_LABELS_ANNOTATION) {
+		if _, ok := labels[k]; ok || isProtectedNamespaceMetadataKey(k) {
+			continue
+		}
+		if _, ok := defaultLabels[k]; ok {
+			continue
+		}
+		if k == istioInjectionLabel {
+			// Restore the default of the namespace.
+			ns.Labels[k] = "enabled"
+			continue
+		}
+		delete(ns.Labels, k)
+	}
+
+	appliedAnnotations := []string{}
+	for k, v := range annotations {
+		if k == "owner" || isProtectedNamespaceMetadataKey(k) {
+			ignored = append(ignored, "annotation "+k)
+			continue
+		}
+		ns.Annotations[k] = v
+		appliedAnnotations = append(appliedAnnotations, k)
+	}
+	for _, k := range getAppliedNamespaceMetadataKeys(ns, NAMESPACE_ANNOTATIONS_ANNOTATION) {
+		if _, ok := annotations[k]; ok || k == "owner" || isProtectedNamespaceMetadataKey(k) {
+			continue
+		}
+		delete(ns.Annotations, k)
+	}
+
+	setAppliedNamespaceMetadataKeys(ns, NAMESPACE_LABELS_ANNOTATION, appliedLabels)
+	setAppliedNamespaceMetadataKeys(ns, NAMESPACE_ANNOTATIONS_ANNOTATION, appliedAnnotations)
+	sort.Strings(ignored)
+	return ignored
+}
+
+// getAppliedNamespaceMetadataKeys returns the keys listed in the annotation.
+func getAppliedNamespaceMetadataKeys(ns *corev1.Namespace, annotation string) []string {
+	if ns.Annotations[annotation] == "" {
+		return nil
+	}
+	return strings.Split(ns.Annotations[annotation], ",")
+}
+
+// setAppliedNamespaceMetadataKeys lists the keys in the annotation, which is
+// removed when there are none.
+func setAppliedNamespaceMetadataKeys(ns *corev1.Namespace, annotation string, keys []string) {
+	if len(keys) == 0 {
+		delete(ns.Annotations, annotation)
+		return
+	}
+	sort.Strings(keys)
+	ns.Annotations[annotation] = strings.Join(keys, ",")
+}
+
+// reconcileNamespaceMetadata applies spec.namespaceMetadata to the namespace,
+// which is updated by the caller, and warns with an event about the keys it
+// ignored once per generation of the Profile.
+func (r *ProfileReconciler) reconcileNamespaceMetadata(profileIns *profilev1.Profile, ns *corev1.Namespace,
+	defaultLabels map[string]string) {
+	ignored := applyNamespaceMetadata(profileIns, ns, defaultLabels)
+	if len(ignored) == 0 {
+		return
+	}
+	for _, c := range profileIns.Status.Conditions {
+		if c.Type == profilev1.ProfileNamespaceReady && c.ObservedGeneration == profileIns.Generation {
+			return
+		}
+	}
+	r.Log.Info("Ignoring protected namespace metadata", "profile", profileIns.Name, "keys", ignored)
+	r.Recorder.Event(profileIns, corev1.EventTypeWarning, "NamespaceMetadataIgnored",
+		fmt.Sprintf("The namespace keeps the system and cluster default values of %v", strings.Join(ignored, ", ")))
+}
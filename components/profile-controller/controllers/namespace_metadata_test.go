@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestIsProtectedNamespaceMetadataKey(t *testing.T) {
+	for key, expected := range map[string]bool{
+		"cost-center":                        false,
+		"example.com/data-classification":    false,
+		"istio-injection":                    false,
+		"kubernetes.io/metadata.name":        true,
+		"pod-security.kubernetes.io/enforce": true,
+		"app.kubernetes.io/part-of":          true,
+		"profiles.kubeflow.org/adopt":        true,
+		"notkubeflow.org/key":                false,
+	} {
+		if protected := isProtectedNamespaceMetadataKey(key); protected != expected {
+			t.Errorf("Expected %v to be protected: %v, got %v", key, expected, protected)
+		}
+	}
+}
+
+func TestApplyNamespaceMetadata(t *testing.T) {
+	defaultLabels := map[string]string{"pipelines.kubeflow.org/enabled": "true", "team": "default"}
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "kubeflow-user",
+			Labels:      map[string]string{istioInjectionLabel: "enabled", "team": "default"},
+			Annotations: map[string]string{"owner": "user@example.com"},
+		},
+	}
+	profile := &profilev1.Profile{Spec: profilev1.ProfileSpec{
+		NamespaceMetadata: &profilev1.ProfileNamespaceMetadata{
+			Labels: map[string]string{
+				"cost-center":                 "42",
+				istioInjectionLabel:           "disabled",
+				"team":                        "ml",
+				"kubernetes.io/metadata.name": "other",
+			},
+			Annotations: map[string]string{"owner": "other@example.com", "example.com/contact": "ml@example.com"},
+		},
+	}}
+
+	ignored := applyNamespaceMetadata(profile, ns, defaultLabels)
+	expectedIgnored := []string{"annotation owner", "label kubernetes.io/metadata.name", "label team"}
+	if !reflect.DeepEqual(ignored, expectedIgnored) {
+		t.Errorf("Expected %v to be ignored, got %v", expectedIgnored, ignored)
+	}
+	expectedLabels := map[string]string{istioInjectionLabel: "disabled", "team": "default", "cost-center": "42"}
+	if !reflect.DeepEqual(ns.Labels, expectedLabels) {
+		t.Errorf("Expected labels %v, got %v", expectedLabels, ns.Labels)
+	}
+	if ns.Annotations["owner"] != "user@example.com" || ns.Annotations["example.com/contact"] != "ml@example.com" {
+		t.Errorf("Unexpected annotations %v", ns.Annotations)
+	}
+	if keys := ns.Annotations[NAMESPACE_LABELS_ANNOTATION]; keys != "cost-center,istio-injection" {
+		t.Errorf("Expected the applied labels to be recorded, got %v", keys)
+	}
+
+	// The keys removed from the Profile are removed, and the defaults restored.
+	profile.Spec.NamespaceMetadata = nil
+	applyNamespaceMetadata(profile, ns, defaultLabels)
+	expectedLabels = map[string]string{istioInjectionLabel: "enabled", "team": "default"}
+	if !reflect.DeepEqual(ns.Labels, expectedLabels) {
+		t.Errorf("Expected labels %v, got %v", expectedLabels, ns.Labels)
+	}
+	expectedAnnotations := map[string]string{"owner": "user@example.com"}
+	if !reflect.DeepEqual(ns.Annotations, expectedAnnotations) {
+		t.Errorf("Expected annotations %v, got %v", expectedAnnotations, ns.Annotations)
+	}
+}
+
+func TestApplyNamespaceMetadataDefaultPrecedence(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"}}
+	profile := &profilev1.Profile{Spec: profilev1.ProfileSpec{
+		NamespaceMetadata: &profilev1.ProfileNamespaceMetadata{
+			Labels: map[string]string{"cost-center": "42"},
+		},
+	}}
+	applyNamespaceMetadata(profile, ns, map[string]string{})
+	if ns.Labels["cost-center"] != "42" {
+		t.Fatalf("Expected the label of the Profile, got %v", ns.Labels)
+	}
+
+	// The default takes precedence, and the label stays recorded.
+	applyNamespaceMetadata(profile, ns, map[string]string{"cost-center": "0"})
+	if ns.Labels["cost-center"] != "0" || ns.Annotations[NAMESPACE_LABELS_ANNOTATION] != "cost-center" {
+		t.Errorf("Expected the default value to be recorded, got %v and %v", ns.Labels, ns.Annotations)
+	}
+
+	// Once both the default and the Profile label are gone, it is removed.
+	profile.Spec.NamespaceMetadata = nil
+	applyNamespaceMetadata(profile, ns, map[string]string{})
+	if _, ok := ns.Labels["cost-center"]; ok {
+		t.Errorf("Expected the label to be removed, got %v", ns.Labels)
+	}
+	if _, ok := ns.Annotations[NAMESPACE_LABELS_ANNOTATION]; ok {
+		t.Errorf("Expected no recorded label, got %v", ns.Annotations)
+	}
+}
+
+func TestReconcileNamespaceMetadata(t *testing.T) {
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"},
+		Spec: profilev1.ProfileSpec{
+			Owner: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "user@example.com"},
+			NamespaceMetadata: &profilev1.ProfileNamespaceMetadata{
+				Labels: map[string]string{"cost-center": "42", "app.kubernetes.io/part-of": "team"},
+			},
+		},
+	}
+	r := newTestReconciler(profile)
+	r.DefaultNamespaceLabelsPath = newTestLabelsFile(t)
+	recorder := r.Recorder.(*record.FakeRecorder)
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: profile.Name}}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: profile.Name}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if ns.Labels["cost-center"] != "42" || ns.Labels["app.kubernetes.io/part-of"] == "team" {
+		t.Errorf("Unexpected labels %v", ns.Labels)
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "app.kubernetes.io/part-of") {
+			t.Errorf("Unexpected event %v", event)
+		}
+	default:
+		t.Errorf("Expected the ignored keys to be recorded")
+	}
+
+	// The ignored keys are only recorded once per generation.
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	select {
+	case event := <-recorder.Events:
+		t.Errorf("Unexpected event %v", event)
+	default:
+	}
+
+	found := &profilev1.Profile{}
+	if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+		t.Fatal(err)
+	}
+	found.Spec.NamespaceMetadata.Labels = map[string]string{"cost-center": "43"}
+	if err := r.Update(ctx, found); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: profile.Name}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if ns.Labels["cost-center"] != "43" {
+		t.Errorf("Expected the label to be updated, got %v", ns.Labels)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/yaml"
+)
+
+// NETWORK_POLICY_LABEL marks the NetworkPolicies created from the template,
+// so that the ones removed from it are pruned.
+const NETWORK_POLICY_LABEL = "profiles.kubeflow.org/network-policy"
+
+// namespaceNameLabel is set by Kubernetes on every namespace.
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// getDefaultNetworkPolicies returns the NetworkPolicies of the profile
+// namespaces when there is no template. Together they deny ingress from
+// other namespaces than the kubeflow system ones and the ingress gateway.
+func getDefaultNetworkPolicies() []networkingv1.NetworkPolicy {
+	systemNamespaces := strings.Split(GetEnvDefault("KUBEFLOW_SYSTEM_NAMESPACES",
+		"kubeflow,knative-serving"), ",")
+	ingressGatewayNamespace := GetEnvDefault("ISTIO_INGRESS_GATEWAY_NAMESPACE", "istio-system")
+	ingressGatewayLabels := map[string]string{"istio": "ingressgateway"}
+
+	return []networkingv1.NetworkPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "kf-default-deny-ingress"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "kf-allow-same-namespace"},
+			Spec: networkingv1.NetworkPolicySpec{
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{
+					From: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}},
+				}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "kf-allow-kubeflow-system"},
+			Spec: networkingv1.NetworkPolicySpec{
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{
+					From: []networkingv1.NetworkPolicyPeer{{
+						NamespaceSelector: &metav1.LabelSelector{
+							MatchExpressions: []metav1.LabelSelectorRequirement{{
+								Key:      namespaceNameLabel,
+								Operator: metav1.LabelSelectorOpIn,
+								Values:   systemNamespaces,
+							}},
+						},
+					}},
+				}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "kf-allow-ingress-gateway"},
+			Spec: networkingv1.NetworkPolicySpec{
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{
+					From: []networkingv1.NetworkPolicyPeer{{
+						NamespaceSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{namespaceNameLabel: ingressGatewayNamespace},
+						},
+						PodSelector: &metav1.LabelSelector{MatchLabels: ingressGatewayLabels},
+					}},
+				}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			},
+		},
+	}
+}
+
+// readNetworkPoliciesFromFile reads the template of the NetworkPolicies of
+// every profile namespace, a list of NetworkPolicies. The built-in ones are
+// used if the file doesn't exist, and an empty list disables them.
+func readNetworkPoliciesFromFile(path string) ([]networkingv1.NetworkPolicy, error) {
+	if path == "" {
+		return getDefaultNetworkPolicies(), nil
+	}
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return getDefaultNetworkPolicies(), nil
+		}
+		return nil, err
+	}
+	networkPolicies := []networkingv1.NetworkPolicy{}
+	if err := yaml.UnmarshalStrict(dat, &networkPolicies); err != nil {
+		return nil, errors.Wrapf(err, "Unable to parse network policies %s", path)
+	}
+	for i := range networkPolicies {
+		if networkPolicies[i].Name == "" {
+			return nil, errors.Errorf("NetworkPolicy without name in %s", path)
+		}
+		setNetworkPolicyDefaults(&networkPolicies[i].Spec)
+	}
+	return networkPolicies, nil
+}
+
+// setNetworkPolicyDefaults sets the defaults the API server sets, so that the
+// NetworkPolicies read back compare equal: the protocol of the ports is TCP,
+// and the policy types are Ingress, and Egress if there are egress rules.
+func setNetworkPolicyDefaults(spec *networkingv1.NetworkPolicySpec) {
+	protocol := corev1.ProtocolTCP
+	for i := range spec.Ingress {
+		for j := range spec.Ingress[i].Ports {
+			if spec.Ingress[i].Ports[j].Protocol == nil {
+				spec.Ingress[i].Ports[j].Protocol = &protocol
+			}
+		}
+	}
+	for i := range spec.Egress {
+		for j := range spec.Egress[i].Ports {
+			if spec.Egress[i].Ports[j].Protocol == nil {
+				spec.Egress[i].Ports[j].Protocol = &protocol
+			}
+		}
+	}
+	if len(spec.PolicyTypes) == 0 {
+		spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+		if len(spec.Egress) != 0 {
+			spec.PolicyTypes = append(spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+		}
+	}
+}
+
+// reconcileNetworkPolicies creates or updates the NetworkPolicies of the
+// template in the profile namespace, and deletes the ones that were removed
+// from it.
+func (r *ProfileReconciler) reconcileNetworkPolicies(ctx context.Context, profileIns *profilev1.Profile) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	networkPolicies, err := readNetworkPoliciesFromFile(r.NetworkPoliciesPath)
+	if err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+	for _, template := range networkPolicies {
+		networkPolicy := &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: template.Annotations,
+				Labels:      map[string]string{NETWORK_POLICY_LABEL: "true"},
+				Name:        template.Name,
+				Namespace:   profileIns.Name,
+			},
+			Spec: template.Spec,
+		}
+		for k, v := range template.Labels {
+			networkPolicy.Labels[k] = v
+		}
+		if err := r.updateNetworkPolicy(ctx, profileIns, networkPolicy); err != nil {
+			return errors.Wrapf(err, "NetworkPolicy %s", template.Name)
+		}
+		names[template.Name] = true
+	}
+
+	found := &networkingv1.NetworkPolicyList{}
+	if err := r.List(ctx, found, client.InNamespace(profileIns.Name),
+		client.MatchingLabels{NETWORK_POLICY_LABEL: "true"}); err != nil {
+		return err
+	}
+	for i := range found.Items {
+		networkPolicy := &found.Items[i]
+		if names[networkPolicy.Name] {
+			continue
+		}
+		logger.Info("Deleting NetworkPolicy removed from template", "namespace", networkPolicy.Namespace,
+			"name", networkPolicy.Name)
+		if err := r.Delete(ctx, networkPolicy); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateNetworkPolicy create or update NetworkPolicy "networkPolicy" in target namespace owned by "profileIns"
+func (r *ProfileReconciler) updateNetworkPolicy(ctx context.Context, profileIns *profilev1.Profile,
+	networkPolicy *networkingv1.NetworkPolicy) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	if err := controllerutil.SetControllerReference(profileIns, networkPolicy, r.Scheme); err != nil {
+		return err
+	}
+	found := &networkingv1.NetworkPolicy{}
+	err := r.Get(ctx, types.NamespacedName{Name: networkPolicy.Name, Namespace: networkPolicy.Namespace}, found)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("Creating NetworkPolicy", "namespace", networkPolicy.Namespace, "name", networkPolicy.Name)
+			return r.Create(ctx, networkPolicy)
+		}
+		return err
+	}
+	labelsChanged := mergeStringMap(&found.Labels, networkPolicy.Labels)
+	annotationsChanged := mergeStringMap(&found.Annotations, networkPolicy.Annotations)
+	if labelsChanged || annotationsChanged || !equality.Semantic.DeepEqual(networkPolicy.Spec, found.Spec) {
+		found.Spec = networkPolicy.Spec
+		logger.Info("Updating NetworkPolicy", "namespace", networkPolicy.Namespace, "name", networkPolicy.Name)
+		return r.Update(ctx, found)
+	}
+	return nil
+}
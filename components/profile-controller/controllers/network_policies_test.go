@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestReconcileNetworkPolicies(t *testing.T) {
+	namespace := "kubeflow-user"
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	r := newTestReconciler(profile)
+	r.NetworkPoliciesPath = filepath.Join(t.TempDir(), "network-policies.yaml")
+	ctx := context.Background()
+
+	// The built-in set is used without template.
+	if err := r.reconcileNetworkPolicies(ctx, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	found := &networkingv1.NetworkPolicyList{}
+	if err := r.List(ctx, found, client.InNamespace(namespace)); err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Items) != len(getDefaultNetworkPolicies()) {
+		t.Fatalf("Expected the default NetworkPolicies, got %+v", found.Items)
+	}
+	for _, networkPolicy := range found.Items {
+		if networkPolicy.Labels[NETWORK_POLICY_LABEL] != "true" || len(networkPolicy.OwnerReferences) != 1 {
+			t.Errorf("Expected NetworkPolicy %s to be labeled and owned by the Profile", networkPolicy.Name)
+		}
+	}
+
+	// The template replaces the built-in set.
+	if err := ioutil.WriteFile(r.NetworkPoliciesPath, []byte(`
+- metadata:
+    name: kf-default-deny-ingress
+  spec:
+    podSelector: {}
+    policyTypes: [Ingress]
+    ingress:
+    - from:
+      - podSelector: {}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileNetworkPolicies(ctx, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.List(ctx, found, client.InNamespace(namespace)); err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Items) != 1 || len(found.Items[0].Spec.Ingress) != 1 {
+		t.Fatalf("Expected the NetworkPolicy of the template, got %+v", found.Items)
+	}
+
+	// An empty template removes them.
+	if err := ioutil.WriteFile(r.NetworkPoliciesPath, []byte(`[]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reconcileNetworkPolicies(ctx, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: "kf-default-deny-ingress", Namespace: namespace},
+		&networkingv1.NetworkPolicy{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the NetworkPolicy to be deleted, got %v", err)
+	}
+}
+
+func TestGetDefaultNetworkPolicies(t *testing.T) {
+	t.Setenv("KUBEFLOW_SYSTEM_NAMESPACES", "kubeflow,kserve")
+	for _, networkPolicy := range getDefaultNetworkPolicies() {
+		if networkPolicy.Name != "kf-allow-kubeflow-system" {
+			continue
+		}
+		values := networkPolicy.Spec.Ingress[0].From[0].NamespaceSelector.MatchExpressions[0].Values
+		if len(values) != 2 || values[1] != "kserve" {
+			t.Errorf("Expected the namespaces of KUBEFLOW_SYSTEM_NAMESPACES, got %v", values)
+		}
+		return
+	}
+	t.Errorf("Expected a NetworkPolicy allowing the kubeflow system namespaces")
+}
+
+func TestReconcileNetworkPoliciesDefaults(t *testing.T) {
+	namespace := "kubeflow-user"
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	r := newTestReconciler(profile)
+	r.NetworkPoliciesPath = filepath.Join(t.TempDir(), "network-policies.yaml")
+	// The template omits the policy types and the protocol of the port, which the API server sets.
+	if err := ioutil.WriteFile(r.NetworkPoliciesPath, []byte(`
+- metadata:
+    name: allow-metrics
+  spec:
+    podSelector: {}
+    ingress:
+    - ports:
+      - port: 8080
+    egress:
+    - {}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := r.reconcileNetworkPolicies(ctx, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	key := types.NamespacedName{Name: "allow-metrics", Namespace: namespace}
+	found := &networkingv1.NetworkPolicy{}
+	if err := r.Get(ctx, key, found); err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Spec.PolicyTypes) != 2 || found.Spec.PolicyTypes[0] != networkingv1.PolicyTypeIngress ||
+		found.Spec.PolicyTypes[1] != networkingv1.PolicyTypeEgress {
+		t.Errorf("Expected the policy types to be defaulted, got %v", found.Spec.PolicyTypes)
+	}
+	if protocol := found.Spec.Ingress[0].Ports[0].Protocol; protocol == nil || *protocol != corev1.ProtocolTCP {
+		t.Errorf("Expected the protocol to be defaulted, got %v", protocol)
+	}
+
+	// The NetworkPolicy read back with the defaults isn't updated again.
+	resourceVersion := found.ResourceVersion
+	if err := r.reconcileNetworkPolicies(ctx, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Get(ctx, key, found); err != nil {
+		t.Fatal(err)
+	}
+	if found.ResourceVersion != resourceVersion {
+		t.Errorf("Expected the NetworkPolicy not to be updated, got resource version %v, was %v",
+			found.ResourceVersion, resourceVersion)
+	}
+}
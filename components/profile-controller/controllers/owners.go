@@ -0,0 +1,133 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	istioSecurity "istio.io/api/security/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// eventOwnerTransferred is the reason of the event recorded when the owner
+// of a Profile is replaced.
+const eventOwnerTransferred = "OwnerTransferred"
+
+// OWNER_TRANSFER_ANNOTATION records on the namespace the owner a pending
+// transfer is from. It is set with the new owner annotation, and removed once
+// the RoleBinding and the AuthorizationPolicy follow the new owner, so that the
+// transfer is recorded even if that takes several reconciliations.
+const OWNER_TRANSFER_ANNOTATION = "profiles.kubeflow.org/owner-transferred-from"
+
+// setOwnerAnnotation sets the owner annotation of the namespace to the primary
+// owner of the Profile, and records the transfer from the previous owner
+// unless one is already pending.
+func setOwnerAnnotation(profileIns *profilev1.Profile, ns *corev1.Namespace) {
+	owner, ok := ns.Annotations["owner"]
+	primaryOwner := getPrimaryOwner(profileIns)
+	if ok && owner == primaryOwner {
+		return
+	}
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations["owner"] = primaryOwner
+	if !ok {
+		return
+	}
+	from, pending := ns.Annotations[OWNER_TRANSFER_ANNOTATION]
+	switch {
+	case !pending:
+		ns.Annotations[OWNER_TRANSFER_ANNOTATION] = owner
+	case from == primaryOwner:
+		// Transferred back before the transfer completed.
+		delete(ns.Annotations, OWNER_TRANSFER_ANNOTATION)
+	}
+}
+
+// getProfileOwners returns spec.owner followed by spec.owners, without
+// duplicates and with the default API group of users and groups.
+func getProfileOwners(profileIns *profilev1.Profile) []rbacv1.Subject {
+	owners := []rbacv1.Subject{}
+	seen := map[string]bool{}
+	for _, owner := range append([]rbacv1.Subject{profileIns.Spec.Owner}, profileIns.Spec.Owners...) {
+		if owner.Name == "" {
+			continue
+		}
+		if owner.APIGroup == "" && owner.Kind != rbacv1.ServiceAccountKind {
+			owner.APIGroup = rbacv1.GroupName
+		}
+		key := owner.Kind + "/" + owner.Name
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		owners = append(owners, owner)
+	}
+	return owners
+}
+
+// getOwnerNames returns the names of the owners of kind, in the order of
+// getProfileOwners.
+func getOwnerNames(profileIns *profilev1.Profile, kind string) []string {
+	names := []string{}
+	for _, owner := range getProfileOwners(profileIns) {
+		if owner.Kind == kind {
+			names = append(names, owner.Name)
+		}
+	}
+	return names
+}
+
+// getPrimaryOwner returns the name recorded in the "owner" annotation of the
+// namespace: spec.owner, or the first of spec.owners when it isn't set.
+func getPrimaryOwner(profileIns *profilev1.Profile) string {
+	if owners := getProfileOwners(profileIns); len(owners) > 0 {
+		return owners[0].Name
+	}
+	return ""
+}
+
+// isProfileOwner tells whether name is one of the owners of the Profile.
+func isProfileOwner(profileIns *profilev1.Profile, name string) bool {
+	for _, owner := range getProfileOwners(profileIns) {
+		if owner.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// getGroupsCondition returns the Istio condition matching the requests of the
+// members of groups, or nil when no groups header is configured. The header
+// holds a comma separated list of groups, and Istio only supports prefix and
+// suffix matches, so a group is matched alone, first or last in the list.
+func (r *ProfileReconciler) getGroupsCondition(groups []string) *istioSecurity.Condition {
+	if r.GroupsHeader == "" || len(groups) == 0 {
+		return nil
+	}
+	values := []string{}
+	for _, group := range groups {
+		values = append(values, group, group+",*", "*,"+group)
+	}
+	return &istioSecurity.Condition{
+		Key:    fmt.Sprintf("request.headers[%v]", r.GroupsHeader),
+		Values: values,
+	}
+}
@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	istioSecurityClient "istio.io/client-go/pkg/apis/security/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func newTestGroupOwnedProfile() *profilev1.Profile {
+	return &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a"},
+		Spec: profilev1.ProfileSpec{
+			Owner: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "lead@example.com"},
+			Owners: []rbacv1.Subject{
+				{Kind: rbacv1.UserKind, Name: "deputy@example.com"},
+				{Kind: rbacv1.GroupKind, Name: "team-a"},
+				{Kind: rbacv1.UserKind, Name: "lead@example.com"},
+			},
+		},
+	}
+}
+
+func TestGetProfileOwners(t *testing.T) {
+	owners := getProfileOwners(newTestGroupOwnedProfile())
+	expected := []rbacv1.Subject{
+		{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "lead@example.com"},
+		{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "deputy@example.com"},
+		{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: "team-a"},
+	}
+	if !reflect.DeepEqual(owners, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, owners)
+	}
+
+	groupOnly := &profilev1.Profile{Spec: profilev1.ProfileSpec{
+		Owners: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "team-a"}},
+	}}
+	if owner := getPrimaryOwner(groupOnly); owner != "team-a" {
+		t.Errorf("Expected the first of spec.owners without spec.owner, got %q", owner)
+	}
+}
+
+func TestGetAuthorizationPolicyOwners(t *testing.T) {
+	profile := newTestGroupOwnedProfile()
+	r := newTestReconciler()
+	r.UserIdPrefix = "prefix:"
+
+	// Without groups header, only the users are matched.
+	rules := r.getAuthorizationPolicy(profile).Rules
+	expected := []string{"prefix:lead@example.com", "prefix:deputy@example.com"}
+	if values := rules[0].When[0].Values; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+	if len(rules) != 4 {
+		t.Errorf("Expected no rule for the groups, got %+v", rules)
+	}
+
+	r.GroupsHeader = "kubeflow-groups"
+	rules = r.getAuthorizationPolicy(profile).Rules
+	if len(rules) != 5 {
+		t.Fatalf("Expected a rule for the groups, got %+v", rules)
+	}
+	groups := rules[1].When[0]
+	if groups.Key != "request.headers[kubeflow-groups]" ||
+		!reflect.DeepEqual(groups.Values, []string{"team-a", "team-a,*", "*,team-a"}) {
+		t.Errorf("Unexpected groups condition %+v", groups)
+	}
+
+	// A Profile owned by groups only has no rule on the user id header.
+	profile.Spec.Owner = rbacv1.Subject{}
+	profile.Spec.Owners = profile.Spec.Owners[1:2]
+	rules = r.getAuthorizationPolicy(profile).Rules
+	if len(rules) != 4 || rules[0].When[0].Key != "request.headers[kubeflow-groups]" {
+		t.Errorf("Expected only the groups rule, got %+v", rules)
+	}
+}
+
+func TestReconcileOwners(t *testing.T) {
+	profile := newTestGroupOwnedProfile()
+	r := newTestReconciler(profile)
+	r.DefaultNamespaceLabelsPath = newTestLabelsFile(t)
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: profile.Name}}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	roleBinding := &rbacv1.RoleBinding{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "namespaceAdmin", Namespace: profile.Name},
+		roleBinding); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(roleBinding.Subjects, getProfileOwners(profile)) {
+		t.Errorf("Expected all the owners to be bound, got %+v", roleBinding.Subjects)
+	}
+
+	// The namespace stays with the team when its owner is replaced.
+	found := &profilev1.Profile{}
+	if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+		t.Fatal(err)
+	}
+	found.Spec.Owner = rbacv1.Subject{Kind: rbacv1.UserKind, Name: "deputy@example.com"}
+	if err := r.Update(ctx, found); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: profile.Name}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if ns.Annotations["owner"] != "deputy@example.com" {
+		t.Errorf("Expected the new owner in the annotation, got %v", ns.Annotations)
+	}
+	if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+		t.Fatal(err)
+	}
+	if ready := findCondition(found.Status.Conditions, profilev1.ProfileReady); ready.Status != "True" {
+		t.Errorf("Expected the Profile to be Ready, got %+v", found.Status.Conditions)
+	}
+}
+
+func TestGroupContributorAuthorizationPolicy(t *testing.T) {
+	profile := newTestGroupOwnedProfile()
+	c := profilev1.ProfileContributor{Subject: rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "auditors"}, Role: "view"}
+	r := newTestReconciler()
+	if r.hasAuthorizationPolicy(c) {
+		t.Errorf("Expected no AuthorizationPolicy for groups without groups header")
+	}
+	r.GroupsHeader = "kubeflow-groups"
+	if !r.hasAuthorizationPolicy(c) {
+		t.Fatalf("Expected an AuthorizationPolicy for groups with the groups header")
+	}
+	condition := r.getContributorAuthorizationPolicy(profile, c).Rules[0].When[0]
+	if condition.Key != "request.headers[kubeflow-groups]" || condition.Values[0] != "auditors" {
+		t.Errorf("Unexpected condition %+v", condition)
+	}
+}
+
+func TestTransferOwner(t *testing.T) {
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"},
+		Spec:       profilev1.ProfileSpec{Owner: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "leaver@example.com"}},
+	}
+	r := newTestReconciler(profile)
+	r.DefaultNamespaceLabelsPath = newTestLabelsFile(t)
+	recorder := r.Recorder.(*record.FakeRecorder)
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: profile.Name}}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	found := &profilev1.Profile{}
+	if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+		t.Fatal(err)
+	}
+	found.Spec.Owner = rbacv1.Subject{Kind: rbacv1.UserKind, Name: "successor@example.com"}
+	if err := r.Update(ctx, found); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: profile.Name}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if ns.Annotations["owner"] != "successor@example.com" || ns.Annotations[OWNER_TRANSFER_ANNOTATION] != "" {
+		t.Errorf("Expected the namespace to be transferred, got %v", ns.Annotations)
+	}
+	roleBinding := &rbacv1.RoleBinding{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "namespaceAdmin", Namespace: profile.Name},
+		roleBinding); err != nil {
+		t.Fatal(err)
+	}
+	if len(roleBinding.Subjects) != 1 || roleBinding.Subjects[0].Name != "successor@example.com" ||
+		roleBinding.Annotations[USER] != "successor@example.com" {
+		t.Errorf("Expected the RoleBinding to be transferred, got %+v", roleBinding)
+	}
+	policy := &istioSecurityClient.AuthorizationPolicy{}
+	if err := r.Get(ctx, types.NamespacedName{Name: AUTHZPOLICYISTIO, Namespace: profile.Name}, policy); err != nil {
+		t.Fatal(err)
+	}
+	if values := policy.Spec.Rules[0].When[0].Values; len(values) != 1 || values[0] != "successor@example.com" {
+		t.Errorf("Expected the AuthorizationPolicy to be transferred, got %v", values)
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, eventOwnerTransferred) || !strings.Contains(event, "leaver@example.com") {
+			t.Errorf("Unexpected event %v", event)
+		}
+	default:
+		t.Errorf("Expected the transfer to be recorded")
+	}
+}
+
+func TestSetOwnerAnnotation(t *testing.T) {
+	profile := func(owner string) *profilev1.Profile {
+		return &profilev1.Profile{Spec: profilev1.ProfileSpec{Owner: rbacv1.Subject{Kind: rbacv1.UserKind, Name: owner}}}
+	}
+	ns := &corev1.Namespace{}
+	setOwnerAnnotation(profile("a@example.com"), ns)
+	if ns.Annotations["owner"] != "a@example.com" || ns.Annotations[OWNER_TRANSFER_ANNOTATION] != "" {
+		t.Errorf("Expected the owner without transfer, got %v", ns.Annotations)
+	}
+
+	setOwnerAnnotation(profile("b@example.com"), ns)
+	if ns.Annotations["owner"] != "b@example.com" || ns.Annotations[OWNER_TRANSFER_ANNOTATION] != "a@example.com" {
+		t.Errorf("Expected a pending transfer from a, got %v", ns.Annotations)
+	}
+
+	// The pending transfer stays from the first owner.
+	setOwnerAnnotation(profile("c@example.com"), ns)
+	if ns.Annotations["owner"] != "c@example.com" || ns.Annotations[OWNER_TRANSFER_ANNOTATION] != "a@example.com" {
+		t.Errorf("Expected a pending transfer from a, got %v", ns.Annotations)
+	}
+
+	// Nothing is transferred back to the first owner.
+	setOwnerAnnotation(profile("a@example.com"), ns)
+	if _, ok := ns.Annotations[OWNER_TRANSFER_ANNOTATION]; ok || ns.Annotations["owner"] != "a@example.com" {
+		t.Errorf("Expected no pending transfer, got %v", ns.Annotations)
+	}
+}
@@ -0,0 +1,446 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// plugin kind
+	KIND_AZURE_WORKLOAD_IDENTITY = "AzureWorkloadIdentity"
+
+	AZURE_CLIENT_ID_ANNOTATION_KEY = "azure.workload.identity/client-id"
+	AZURE_TENANT_ID_ANNOTATION_KEY = "azure.workload.identity/tenant-id"
+	AZURE_TRUST_IDENTITY_SUBJECT   = "system:serviceaccount:%s:%s"
+	AZURE_DEFAULT_AUDIENCE         = "api://AzureADTokenExchange"
+
+	azureResourceManager            = "https://management.azure.com"
+	azureDefaultAuthorityHost       = "https://login.microsoftonline.com/"
+	azureFederatedCredentialVersion = "2023-01-31"
+)
+
+// AzureWorkloadIdentity: plugin that sets up AKS workload identity (credentials for Azure APIs) for the
+// service accounts of the profile namespace.
+type AzureWorkloadIdentity struct {
+	// Client ID of the user-assigned managed identity
+	ClientID string `json:"clientId,omitempty"`
+	// Tenant of the managed identity, AZURE_TENANT_ID of the controller by default
+	TenantID string `json:"tenantId,omitempty"`
+	// Resource ID of the managed identity, where the federated identity credentials are created:
+	// /subscriptions/<id>/resourceGroups/<group>/providers/Microsoft.ManagedIdentity/userAssignedIdentities/<name>
+	IdentityResourceID string `json:"identityResourceId,omitempty"`
+	// OIDC issuer URL of the AKS cluster, AZURE_OIDC_ISSUER_URL of the controller by default
+	OIDCIssuer string `json:"oidcIssuer,omitempty"`
+	// Service accounts of the profile namespace to federate, default-editor by default
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
+	// Only annotate the service accounts, the federated identity credentials are managed elsewhere
+	AnnotateOnly bool `json:"annotateOnly,omitempty"`
+
+	// client is set by tests, the ARM client of the controller is used otherwise
+	client AzureIdentityClient
+}
+
+// AzureFederatedCredential is the federated identity credential trusting the tokens of a service account.
+type AzureFederatedCredential struct {
+	Issuer    string   `json:"issuer"`
+	Subject   string   `json:"subject"`
+	Audiences []string `json:"audiences"`
+}
+
+// AzureIdentityClient manages the federated identity credentials of Azure managed identities.
+// Both calls must be idempotent.
+type AzureIdentityClient interface {
+	CreateOrUpdateFederatedCredential(ctx context.Context, identityID string, name string,
+		credential AzureFederatedCredential) error
+	// DeleteFederatedCredential returns no error if the credential doesn't exist.
+	DeleteFederatedCredential(ctx context.Context, identityID string, name string) error
+}
+
+// ApplyPlugin annotates the service accounts with the managed identity and creates their federated identity
+// credentials.
+func (azure *AzureWorkloadIdentity) ApplyPlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
+	logger := r.Log.WithValues("profile", profile.Name)
+	if err := azure.AnnotateServiceAccounts(r, profile); err != nil {
+		return err
+	}
+	if azure.AnnotateOnly {
+		logger.Info("AnnotateOnly set to true, federated identity credentials will not be mutated")
+		return nil
+	}
+	client, err := azure.getClient()
+	if err != nil {
+		return err
+	}
+	issuer := azure.getOIDCIssuer()
+	if issuer == "" {
+		return errors.New("failed to setup federated identity credential because oidcIssuer is empty")
+	}
+	ctx := context.Background()
+	for _, ksa := range azure.getServiceAccounts() {
+		logger.Info("Setting up federated identity credential.", "ServiceAccount", ksa,
+			"Identity", azure.IdentityResourceID)
+		err := client.CreateOrUpdateFederatedCredential(ctx, azure.IdentityResourceID,
+			getAzureFederatedCredentialName(profile.Name, ksa), AzureFederatedCredential{
+				Issuer:    issuer,
+				Subject:   fmt.Sprintf(AZURE_TRUST_IDENTITY_SUBJECT, profile.Name, ksa),
+				Audiences: []string{AZURE_DEFAULT_AUDIENCE},
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AnnotateServiceAccounts annotates the service accounts with the managed identity.
+func (azure *AzureWorkloadIdentity) AnnotateServiceAccounts(r *ProfileReconciler, profile *profilev1.Profile) error {
+	logger := r.Log.WithValues("profile", profile.Name)
+	if err := azure.validate(); err != nil {
+		return err
+	}
+	for _, ksa := range azure.getServiceAccounts() {
+		if err := azure.patchAnnotation(r, profile.Name, ksa, addAzureIdentityAnnotation, logger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokePlugin removes the annotations of the service accounts and deletes their federated identity credentials.
+func (azure *AzureWorkloadIdentity) RevokePlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
+	return azure.revokeServiceAccounts(r, profile, azure.getServiceAccounts())
+}
+
+// RevokeDroppedServiceAccounts revokes the service accounts next doesn't federate with the managed identity anymore.
+func (azure *AzureWorkloadIdentity) RevokeDroppedServiceAccounts(r *ProfileReconciler, profile *profilev1.Profile,
+	next Plugin) error {
+	nextAzure, ok := next.(*AzureWorkloadIdentity)
+	if !ok || nextAzure.ClientID != azure.ClientID || nextAzure.IdentityResourceID != azure.IdentityResourceID ||
+		nextAzure.AnnotateOnly != azure.AnnotateOnly {
+		return azure.revokeServiceAccounts(r, profile, azure.getServiceAccounts())
+	}
+	return azure.revokeServiceAccounts(r, profile, getDroppedServiceAccounts(azure.getServiceAccounts(),
+		nextAzure.getServiceAccounts()))
+}
+
+// revokeServiceAccounts removes the annotations of the service accounts and deletes their federated identity
+// credentials.
+func (azure *AzureWorkloadIdentity) revokeServiceAccounts(r *ProfileReconciler, profile *profilev1.Profile,
+	ksas []string) error {
+	logger := r.Log.WithValues("profile", profile.Name)
+	for _, ksa := range ksas {
+		err := azure.patchAnnotation(r, profile.Name, ksa, removeAzureIdentityAnnotation, logger)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	if azure.AnnotateOnly || azure.IdentityResourceID == "" || len(ksas) == 0 {
+		return nil
+	}
+	client, err := azure.getClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	for _, ksa := range ksas {
+		logger.Info("Clean up federated identity credential.", "ServiceAccount", ksa,
+			"Identity", azure.IdentityResourceID)
+		if err := client.DeleteFederatedCredential(ctx, azure.IdentityResourceID,
+			getAzureFederatedCredentialName(profile.Name, ksa)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (azure *AzureWorkloadIdentity) validate() error {
+	if azure.ClientID == "" {
+		return errors.New("failed to setup service account because clientId is empty")
+	}
+	if azure.getTenantID() == "" {
+		return errors.New("failed to setup service account because tenantId is empty")
+	}
+	if !azure.AnnotateOnly && azure.IdentityResourceID == "" {
+		return errors.New("failed to setup federated identity credential because identityResourceId is empty")
+	}
+	return nil
+}
+
+func (azure *AzureWorkloadIdentity) getServiceAccounts() []string {
+	if len(azure.ServiceAccounts) == 0 {
+		return []string{DEFAULT_EDITOR}
+	}
+	return azure.ServiceAccounts
+}
+
+func (azure *AzureWorkloadIdentity) getTenantID() string {
+	if azure.TenantID != "" {
+		return azure.TenantID
+	}
+	return os.Getenv("AZURE_TENANT_ID")
+}
+
+func (azure *AzureWorkloadIdentity) getOIDCIssuer() string {
+	if azure.OIDCIssuer != "" {
+		return azure.OIDCIssuer
+	}
+	return os.Getenv("AZURE_OIDC_ISSUER_URL")
+}
+
+func (azure *AzureWorkloadIdentity) getClient() (AzureIdentityClient, error) {
+	if azure.client != nil {
+		return azure.client, nil
+	}
+	return getDefaultAzureIdentityClient()
+}
+
+// patchAnnotation will patch the annotations of the k8s service account in order to pair up with the managed identity
+func (azure *AzureWorkloadIdentity) patchAnnotation(r *ProfileReconciler, namespace string, ksa string,
+	annotationFunc func(*corev1.ServiceAccount, string, string), logger logr.Logger) error {
+	ctx := context.Background()
+	found := &corev1.ServiceAccount{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ksa, Namespace: namespace}, found); err != nil {
+		return err
+	}
+	annotations := found.DeepCopy().Annotations
+	annotationFunc(found, azure.ClientID, azure.getTenantID())
+	if equality.Semantic.DeepEqual(annotations, found.Annotations) {
+		return nil
+	}
+	logger.Info("Patch Annotation for service account: ", "namespace ", namespace, "name ", ksa)
+	return r.Update(ctx, found)
+}
+
+// addAzureIdentityAnnotation adds the client and tenant ids of the managed identity to the service account annotations
+func addAzureIdentityAnnotation(sa *corev1.ServiceAccount, clientID string, tenantID string) {
+	if sa.Annotations == nil {
+		sa.Annotations = map[string]string{}
+	}
+	sa.Annotations[AZURE_CLIENT_ID_ANNOTATION_KEY] = clientID
+	sa.Annotations[AZURE_TENANT_ID_ANNOTATION_KEY] = tenantID
+}
+
+// removeAzureIdentityAnnotation removes the managed identity from the service account annotations
+func removeAzureIdentityAnnotation(sa *corev1.ServiceAccount, clientID string, tenantID string) {
+	delete(sa.Annotations, AZURE_CLIENT_ID_ANNOTATION_KEY)
+	delete(sa.Annotations, AZURE_TENANT_ID_ANNOTATION_KEY)
+}
+
+// azureFederatedCredentialPrefixLength bounds the readable part of the names of the federated identity credentials,
+// which are limited to 120 characters.
+const azureFederatedCredentialPrefixLength = 80
+
+// getAzureFederatedCredentialName returns the name of the federated identity credential of a service account.
+// Names may only contain alphanumerics, dashes and underscores, so the namespace and the service account are
+// followed by a hash of both, which tells apart the pairs that are the same once sanitized, or truncated.
+func getAzureFederatedCredentialName(namespace string, ksa string) string {
+	prefix := nonAlphanumeric.ReplaceAllString(fmt.Sprintf("%s-%s", namespace, ksa), "-")
+	if len(prefix) > azureFederatedCredentialPrefixLength {
+		prefix = prefix[:azureFederatedCredentialPrefixLength]
+	}
+	hash := sha256.Sum256([]byte(namespace + "/" + ksa))
+	return fmt.Sprintf("kubeflow-%s-%x", prefix, hash[:5])
+}
+
+// azureRequestTimeout bounds the requests to Azure AD and to the Resource Manager, so that a hung endpoint doesn't
+// block a reconcile worker.
+const azureRequestTimeout = 30 * time.Second
+
+var (
+	defaultAzureIdentityClient     AzureIdentityClient
+	defaultAzureIdentityClientLock sync.Mutex
+)
+
+// getDefaultAzureIdentityClient returns the ARM client of the controller, authenticated with the AZURE_*
+// environment variables set by Azure workload identity, or with a client secret.
+func getDefaultAzureIdentityClient() (AzureIdentityClient, error) {
+	defaultAzureIdentityClientLock.Lock()
+	defer defaultAzureIdentityClientLock.Unlock()
+	if defaultAzureIdentityClient != nil {
+		return defaultAzureIdentityClient, nil
+	}
+	tokenSource, err := newAzureTokenSource()
+	if err != nil {
+		return nil, err
+	}
+	defaultAzureIdentityClient = &armIdentityClient{
+		resourceManager: GetEnvDefault("AZURE_RESOURCE_MANAGER_ENDPOINT", azureResourceManager),
+		httpClient:      &http.Client{Timeout: azureRequestTimeout},
+		token:           tokenSource.Token,
+	}
+	return defaultAzureIdentityClient, nil
+}
+
+// armIdentityClient calls the Azure Resource Manager REST API.
+type armIdentityClient struct {
+	resourceManager string
+	httpClient      *http.Client
+	token           func(ctx context.Context) (string, error)
+}
+
+func (c *armIdentityClient) CreateOrUpdateFederatedCredential(ctx context.Context, identityID string, name string,
+	credential AzureFederatedCredential) error {
+	body, err := json.Marshal(map[string]interface{}{"properties": credential})
+	if err != nil {
+		return err
+	}
+	_, err = c.do(ctx, http.MethodPut, identityID, name, body)
+	return err
+}
+
+func (c *armIdentityClient) DeleteFederatedCredential(ctx context.Context, identityID string, name string) error {
+	status, err := c.do(ctx, http.MethodDelete, identityID, name, nil)
+	if status == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+func (c *armIdentityClient) do(ctx context.Context, method string, identityID string, name string,
+	body []byte) (int, error) {
+	token, err := c.token(ctx)
+	if err != nil {
+		return 0, err
+	}
+	u := fmt.Sprintf("%s/%s/federatedIdentityCredentials/%s?api-version=%s",
+		strings.TrimSuffix(c.resourceManager, "/"), strings.Trim(identityID, "/"), url.PathEscape(name),
+		azureFederatedCredentialVersion)
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("%s federated identity credential %s: %s: %s", method, name,
+			resp.Status, respBody)
+	}
+	return resp.StatusCode, nil
+}
+
+// azureTokenSource gets and caches Azure AD tokens for the Resource Manager with the client credentials flow.
+type azureTokenSource struct {
+	tokenURL   string
+	clientID   string
+	assertion  func() (string, error)
+	secret     string
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newAzureTokenSource() (*azureTokenSource, error) {
+	tenantID := os.Getenv("AZURE_TENANT_ID")
+	clientID := os.Getenv("AZURE_CLIENT_ID")
+	if tenantID == "" || clientID == "" {
+		return nil, errors.New("AZURE_TENANT_ID and AZURE_CLIENT_ID must be set to manage federated identity credentials")
+	}
+	source := &azureTokenSource{
+		tokenURL: fmt.Sprintf("%s%s/oauth2/v2.0/token",
+			GetEnvDefault("AZURE_AUTHORITY_HOST", azureDefaultAuthorityHost), tenantID),
+		clientID:   clientID,
+		secret:     os.Getenv("AZURE_CLIENT_SECRET"),
+		httpClient: &http.Client{Timeout: azureRequestTimeout},
+	}
+	if tokenFile := os.Getenv("AZURE_FEDERATED_TOKEN_FILE"); tokenFile != "" {
+		// The projected token is rotated, so read it for every request.
+		source.assertion = func() (string, error) {
+			token, err := ioutil.ReadFile(tokenFile)
+			return string(token), err
+		}
+	} else if source.secret == "" {
+		return nil, errors.New("AZURE_FEDERATED_TOKEN_FILE or AZURE_CLIENT_SECRET must be set to manage federated identity credentials")
+	}
+	return source, nil
+}
+
+func (s *azureTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+
+	form := url.Values{
+		"grant_type": {"client_credentials"},
+		"client_id":  {s.clientID},
+		"scope":      {azureResourceManager + "/.default"},
+	}
+	if s.assertion != nil {
+		assertion, err := s.assertion()
+		if err != nil {
+			return "", err
+		}
+		form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+		form.Set("client_assertion", assertion)
+	} else {
+		form.Set("client_secret", s.secret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error getting Azure AD token: %s: %s", resp.Status, body)
+	}
+	token := struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}{}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", err
+	}
+	s.token = token.AccessToken
+	// Renew the token a minute before it expires.
+	s.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const testIdentityID = "/subscriptions/sub/resourceGroups/kubeflow/providers/Microsoft.ManagedIdentity/userAssignedIdentities/kf"
+
+// fakeAzureIdentityClient keeps the federated identity credentials by identity and name.
+type fakeAzureIdentityClient map[string]AzureFederatedCredential
+
+func (f fakeAzureIdentityClient) CreateOrUpdateFederatedCredential(ctx context.Context, identityID string,
+	name string, credential AzureFederatedCredential) error {
+	f[identityID+"/"+name] = credential
+	return nil
+}
+
+func (f fakeAzureIdentityClient) DeleteFederatedCredential(ctx context.Context, identityID string, name string) error {
+	delete(f, identityID+"/"+name)
+	return nil
+}
+
+func TestAzureWorkloadIdentity(t *testing.T) {
+	namespace := "kubeflow-user"
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	r := newTestReconciler(profile, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: DEFAULT_EDITOR, Namespace: namespace},
+	})
+	credentials := fakeAzureIdentityClient{}
+	azure := &AzureWorkloadIdentity{
+		ClientID:           "client-id",
+		TenantID:           "tenant-id",
+		IdentityResourceID: testIdentityID,
+		OIDCIssuer:         "https://oidc.example.com/",
+		client:             credentials,
+	}
+
+	// Applying twice is idempotent.
+	for i := 0; i < 2; i++ {
+		if err := azure.ApplyPlugin(r, profile); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	sa := &corev1.ServiceAccount{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: DEFAULT_EDITOR, Namespace: namespace},
+		sa); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if sa.Annotations[AZURE_CLIENT_ID_ANNOTATION_KEY] != "client-id" ||
+		sa.Annotations[AZURE_TENANT_ID_ANNOTATION_KEY] != "tenant-id" {
+		t.Errorf("Expected the service account to be annotated, got %v", sa.Annotations)
+	}
+	credential, ok := credentials[testIdentityID+"/"+getAzureFederatedCredentialName(namespace, DEFAULT_EDITOR)]
+	if len(credentials) != 1 || !ok {
+		t.Fatalf("Expected a federated identity credential, got %v", credentials)
+	}
+	if credential.Subject != "system:serviceaccount:kubeflow-user:default-editor" ||
+		credential.Issuer != "https://oidc.example.com/" || credential.Audiences[0] != AZURE_DEFAULT_AUDIENCE {
+		t.Errorf("Unexpected federated identity credential %+v", credential)
+	}
+
+	// Revoking twice is idempotent.
+	for i := 0; i < 2; i++ {
+		if err := azure.RevokePlugin(r, profile); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: DEFAULT_EDITOR, Namespace: namespace},
+		sa); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := sa.Annotations[AZURE_CLIENT_ID_ANNOTATION_KEY]; ok {
+		t.Errorf("Expected the annotations to be removed, got %v", sa.Annotations)
+	}
+	if len(credentials) != 0 {
+		t.Errorf("Expected the federated identity credential to be deleted, got %v", credentials)
+	}
+}
+
+func TestAzureWorkloadIdentityAnnotateOnly(t *testing.T) {
+	namespace := "kubeflow-user"
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	r := newTestReconciler(profile, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: DEFAULT_EDITOR, Namespace: namespace},
+	})
+	credentials := fakeAzureIdentityClient{}
+	azure := &AzureWorkloadIdentity{ClientID: "client-id", TenantID: "tenant-id", AnnotateOnly: true,
+		client: credentials}
+	if err := azure.ApplyPlugin(r, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(credentials) != 0 {
+		t.Errorf("Expected no federated identity credential, got %v", credentials)
+	}
+
+	azure = &AzureWorkloadIdentity{ClientID: "client-id", TenantID: "tenant-id", client: credentials}
+	if err := azure.ApplyPlugin(r, profile); err == nil {
+		t.Errorf("Expected an error without identityResourceId")
+	}
+}
+
+func TestAzureWorkloadIdentityRevokeDroppedServiceAccounts(t *testing.T) {
+	namespace := "kubeflow-user"
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	r := newTestReconciler(profile,
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: namespace}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: namespace}})
+	credentials := fakeAzureIdentityClient{}
+	newAzure := func(identityID string, ksas ...string) *AzureWorkloadIdentity {
+		return &AzureWorkloadIdentity{
+			ClientID:           "client-id",
+			TenantID:           "tenant-id",
+			IdentityResourceID: identityID,
+			OIDCIssuer:         "https://oidc.example.com/",
+			ServiceAccounts:    ksas,
+			client:             credentials,
+		}
+	}
+	previous := newAzure(testIdentityID, "a", "b")
+	if err := previous.ApplyPlugin(r, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// b is dropped.
+	if err := previous.RevokeDroppedServiceAccounts(r, profile, newAzure(testIdentityID, "a")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := credentials[testIdentityID+"/"+getAzureFederatedCredentialName(namespace, "a")]; len(credentials) != 1 || !ok {
+		t.Errorf("Expected only the credential of a to be kept, got %v", credentials)
+	}
+
+	// Another identity revokes a from the previous one.
+	previous = newAzure(testIdentityID, "a")
+	if err := previous.RevokeDroppedServiceAccounts(r, profile, newAzure(testIdentityID+"-2", "a")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(credentials) != 0 {
+		t.Errorf("Expected no credential to be kept, got %v", credentials)
+	}
+}
+
+func TestARMIdentityClient(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != testIdentityID+"/federatedIdentityCredentials/kubeflow-user" ||
+			r.URL.Query().Get("api-version") != azureFederatedCredentialVersion {
+			t.Errorf("Unexpected request %v", r.URL)
+		}
+		methods = append(methods, r.Method)
+		if r.Method == http.MethodPut {
+			body := struct {
+				Properties AzureFederatedCredential `json:"properties"`
+			}{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Properties.Subject != "subject" {
+				t.Errorf("Unexpected body %+v, %v", body, err)
+			}
+			w.Write([]byte(`{}`))
+			return
+		}
+		// The credential is already deleted.
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := &armIdentityClient{
+		resourceManager: server.URL,
+		httpClient:      server.Client(),
+		token: func(ctx context.Context) (string, error) {
+			return "token", nil
+		},
+	}
+	ctx := context.Background()
+	if err := client.CreateOrUpdateFederatedCredential(ctx, testIdentityID, "kubeflow-user",
+		AzureFederatedCredential{Subject: "subject"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := client.DeleteFederatedCredential(ctx, testIdentityID, "kubeflow-user"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(methods) != 2 {
+		t.Errorf("Expected 2 requests, got %v", methods)
+	}
+}
+
+func TestGetAzureFederatedCredentialName(t *testing.T) {
+	// The pairs that are the same once sanitized get different names.
+	if a, b := getAzureFederatedCredentialName("team-a", "x"), getAzureFederatedCredentialName("team", "a-x"); a == b {
+		t.Errorf("Expected different names, got %v for both", a)
+	}
+	long := strings.Repeat("a", 63)
+	name := getAzureFederatedCredentialName(long, long+"b")
+	if len(name) > 120 || name == getAzureFederatedCredentialName(long, long+"c") {
+		t.Errorf("Expected a unique name of at most 120 characters, got %v", name)
+	}
+	if name := getAzureFederatedCredentialName("kubeflow-user", "default.editor"); !regexp.MustCompile(
+		"^kubeflow-kubeflow-user-default-editor-[0-9a-f]{10}$").MatchString(name) {
+		t.Errorf("Unexpected name %v", name)
+	}
+}
+
+func TestAzureTokenSourceTimeout(t *testing.T) {
+	t.Setenv("AZURE_TENANT_ID", "tenant")
+	t.Setenv("AZURE_CLIENT_ID", "client")
+	t.Setenv("AZURE_CLIENT_SECRET", "secret")
+	source, err := newAzureTokenSource()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if source.httpClient == nil || source.httpClient.Timeout != azureRequestTimeout {
+		t.Errorf("Expected the token requests to time out, got %+v", source.httpClient)
+	}
+}
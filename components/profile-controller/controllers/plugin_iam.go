@@ -15,6 +15,8 @@ import (
 	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
 	"github.com/tidwall/gjson"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -30,28 +32,77 @@ const (
 type AwsIAMForServiceAccount struct {
 	AwsIAMRole   string `json:"awsIamRole,omitempty"`
 	AnnotateOnly bool   `json:"annotateOnly,omitempty"`
+	// Service accounts of the profile namespace to bind, DEFAULT_SERVICE_ACCOUNT by default
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
 }
 
 // ApplyPlugin annotate service account with the ARN of the IAM role and update trust relationship of IAM role
 func (aws *AwsIAMForServiceAccount) ApplyPlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
 	logger := r.Log.WithValues("profile", profile.Name)
-	if err := aws.patchAnnotation(r, profile.Name, DEFAULT_SERVICE_ACCOUNT, addIAMRoleAnnotation, logger); err != nil {
-		return err
+	for _, ksa := range aws.getServiceAccounts() {
+		if err := aws.patchAnnotation(r, profile.Name, ksa, addIAMRoleAnnotation, logger); err != nil {
+			return err
+		}
+
+		logger.Info("Setting up iam roles and policy for service account.", "ServiceAccount", ksa, "Role", aws.AwsIAMRole)
+		if err := aws.updateIAMForServiceAccount(profile.Name, ksa, addServiceAccountInAssumeRolePolicy, logger); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	logger.Info("Setting up iam roles and policy for service account.", "ServiceAccount", DEFAULT_SERVICE_ACCOUNT, "Role", aws.AwsIAMRole)
-	return aws.updateIAMForServiceAccount(profile.Name, DEFAULT_SERVICE_ACCOUNT, addServiceAccountInAssumeRolePolicy, logger)
+// AnnotateServiceAccounts annotates the service accounts with the ARN of the IAM role.
+func (aws *AwsIAMForServiceAccount) AnnotateServiceAccounts(r *ProfileReconciler, profile *profilev1.Profile) error {
+	logger := r.Log.WithValues("profile", profile.Name)
+	for _, ksa := range aws.getServiceAccounts() {
+		if err := aws.patchAnnotation(r, profile.Name, ksa, addIAMRoleAnnotation, logger); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // RevokePlugin remove role in service account annotation and delete service account record in IAM trust relationship.
 func (aws *AwsIAMForServiceAccount) RevokePlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
+	return aws.revokeServiceAccounts(r, profile, aws.getServiceAccounts())
+}
+
+// RevokeDroppedServiceAccounts revokes the service accounts next doesn't bind to the IAM role anymore.
+func (aws *AwsIAMForServiceAccount) RevokeDroppedServiceAccounts(r *ProfileReconciler, profile *profilev1.Profile,
+	next Plugin) error {
+	nextAws, ok := next.(*AwsIAMForServiceAccount)
+	if !ok || nextAws.AwsIAMRole != aws.AwsIAMRole || nextAws.isAnnotateOnly() != aws.isAnnotateOnly() {
+		return aws.revokeServiceAccounts(r, profile, aws.getServiceAccounts())
+	}
+	return aws.revokeServiceAccounts(r, profile, getDroppedServiceAccounts(aws.getServiceAccounts(),
+		nextAws.getServiceAccounts()))
+}
+
+// revokeServiceAccounts removes the annotation of the service accounts, and the trust of the IAM role.
+func (aws *AwsIAMForServiceAccount) revokeServiceAccounts(r *ProfileReconciler, profile *profilev1.Profile,
+	ksas []string) error {
 	logger := r.Log.WithValues("profile", profile.Name)
-	if err := aws.patchAnnotation(r, profile.Name, DEFAULT_SERVICE_ACCOUNT, removeIAMRoleAnnotation, logger); err != nil {
-		return err
+	for _, ksa := range ksas {
+		err := aws.patchAnnotation(r, profile.Name, ksa, removeIAMRoleAnnotation, logger)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		logger.Info("Clean up AWS IAM Role for Service Account.", "ServiceAccount", ksa, "Role", aws.AwsIAMRole)
+		if err := aws.updateIAMForServiceAccount(profile.Name, ksa, removeServiceAccountInAssumeRolePolicy, logger); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	logger.Info("Clean up AWS IAM Role for Service Account.", "ServiceAccount", DEFAULT_SERVICE_ACCOUNT, "Role", aws.AwsIAMRole)
-	return aws.updateIAMForServiceAccount(profile.Name, DEFAULT_SERVICE_ACCOUNT, removeServiceAccountInAssumeRolePolicy, logger)
+// getServiceAccounts returns the service accounts bound to the IAM role
+func (aws *AwsIAMForServiceAccount) getServiceAccounts() []string {
+	if len(aws.ServiceAccounts) == 0 {
+		return []string{DEFAULT_SERVICE_ACCOUNT}
+	}
+	return aws.ServiceAccounts
 }
 
 // patchAnnotation will patch annotation to k8s service account in order to pair up with GCP identity
@@ -67,7 +118,11 @@ func (aws *AwsIAMForServiceAccount) patchAnnotation(r *ProfileReconciler, namesp
 		return errors.New("failed to setup service account because awsIamRole is empty")
 	}
 
+	annotations := found.DeepCopy().Annotations
 	annotationFunc(found, aws.AwsIAMRole)
+	if equality.Semantic.DeepEqual(annotations, found.Annotations) {
+		return nil
+	}
 	logger.Info("Patch Annotation for service account: ", "namespace ", namespace, "name ", ksa)
 	return r.Update(ctx, found)
 }
@@ -79,26 +134,11 @@ func (aws *AwsIAMForServiceAccount) updateIAMForServiceAccount(serviceAccountNam
 		return nil
 	}
 
-	sess, err := session.NewSession()
-	if err != nil {
-		return fmt.Errorf("error getting AWS session while retrieving region: %v", err)
-	}
-	svc := iam.New(sess)
-	roleName := getIAMRoleNameFromIAMRoleArn(aws.AwsIAMRole)
-	roleInput := &iam.GetRoleInput{
-		RoleName: awssdk.String(roleName),
-	}
-
-	output, err := svc.GetRole(roleInput)
-	if err != nil {
-		return err
-	}
-
-	// Seems AssumeRolePolicyDocument is URL encoded
-	decodeValue, err := url.QueryUnescape(awssdk.StringValue(output.Role.AssumeRolePolicyDocument))
+	svc, decodeValue, err := aws.getAssumeRolePolicy()
 	if err != nil {
 		return err
 	}
+	roleName := getIAMRoleNameFromIAMRoleArn(aws.AwsIAMRole)
 
 	updatedRolePolicy, err := updateAssumeRolePolicy(decodeValue, serviceAccountNamespace, serviceAccountName)
 	if err != nil {
@@ -118,6 +158,68 @@ func (aws *AwsIAMForServiceAccount) updateIAMForServiceAccount(serviceAccountNam
 	return nil
 }
 
+// getAssumeRolePolicy returns the IAM client and the decoded trust policy of AwsIAMRole.
+func (aws *AwsIAMForServiceAccount) getAssumeRolePolicy() (*iam.IAM, string, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, "", fmt.Errorf("error getting AWS session while retrieving region: %v", err)
+	}
+	svc := iam.New(sess)
+	roleInput := &iam.GetRoleInput{
+		RoleName: awssdk.String(getIAMRoleNameFromIAMRoleArn(aws.AwsIAMRole)),
+	}
+
+	output, err := svc.GetRole(roleInput)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Seems AssumeRolePolicyDocument is URL encoded
+	decodeValue, err := url.QueryUnescape(awssdk.StringValue(output.Role.AssumeRolePolicyDocument))
+	if err != nil {
+		return nil, "", err
+	}
+	return svc, decodeValue, nil
+}
+
+// CheckPluginDrift returns the service accounts that lost the annotation of AwsIAMRole, or that its trust policy
+// doesn't trust anymore. The trust policy is read once, and not when AnnotateOnly is set.
+func (aws *AwsIAMForServiceAccount) CheckPluginDrift(r *ProfileReconciler, profile *profilev1.Profile) ([]string, error) {
+	ctx := context.Background()
+	drift := []string{}
+	policyDocument := ""
+	for _, ksa := range aws.getServiceAccounts() {
+		found := &corev1.ServiceAccount{}
+		if err := r.Get(ctx, types.NamespacedName{Name: ksa, Namespace: profile.Name}, found); err != nil {
+			return nil, err
+		}
+		if found.Annotations[AWS_ANNOTATION_KEY] != aws.AwsIAMRole {
+			drift = append(drift, fmt.Sprintf("service account %v isn't annotated with %v", ksa, aws.AwsIAMRole))
+		}
+		if aws.isAnnotateOnly() {
+			continue
+		}
+		if policyDocument == "" {
+			_, document, err := aws.getAssumeRolePolicy()
+			if err != nil {
+				return nil, err
+			}
+			policyDocument = document
+		}
+		if !hasServiceAccountInAssumeRolePolicy(policyDocument, profile.Name, ksa) {
+			drift = append(drift, fmt.Sprintf("role %v doesn't trust service account %v", aws.AwsIAMRole, ksa))
+		}
+	}
+	return drift, nil
+}
+
+// hasServiceAccountInAssumeRolePolicy returns whether the trust policy trusts the service account.
+func hasServiceAccountInAssumeRolePolicy(policyDocument, serviceAccountNamespace, serviceAccountName string) bool {
+	_, err := addServiceAccountInAssumeRolePolicy(policyDocument, serviceAccountNamespace, serviceAccountName)
+	_, ok := err.(*ConditionExistError)
+	return ok
+}
+
 // addIAMRoleAnnotation add `eks.amazonaws.com/role-arn:roleArn` to service account annotations
 func addIAMRoleAnnotation(sa *corev1.ServiceAccount, iamRoleArn string) {
 	if sa.Annotations == nil {
@@ -0,0 +1,214 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PluginDriftChecker is implemented by the plugins that can check, without
+// changing anything, whether what they applied still holds. The plugins that
+// don't implement it are applied again when they are verified.
+type PluginDriftChecker interface {
+	// CheckPluginDrift returns what changed since the plugin was applied,
+	// nothing if it still holds.
+	CheckPluginDrift(*ProfileReconciler, *profilev1.Profile) ([]string, error)
+}
+
+// PluginServiceAccountAnnotator is implemented by the plugins that annotate
+// service accounts of the namespace. The service accounts can be recreated or
+// edited without changing the spec hash, so the annotations are applied at
+// every reconciliation, and only the calls to the cloud provider are skipped.
+type PluginServiceAccountAnnotator interface {
+	// AnnotateServiceAccounts annotates the service accounts of the plugin,
+	// without updating the ones that already are.
+	AnnotateServiceAccounts(*ProfileReconciler, *profilev1.Profile) error
+}
+
+// PluginServiceAccountRevoker is implemented by the plugins that bind service
+// accounts of the namespace to a cloud identity. When the spec of the plugin
+// changes, the plugin of the spec it was last applied with revokes what the
+// new one doesn't bind anymore, before the new one is applied.
+type PluginServiceAccountRevoker interface {
+	// RevokeDroppedServiceAccounts revokes the service accounts next doesn't
+	// bind, or all of them if next binds them to another identity.
+	RevokeDroppedServiceAccounts(r *ProfileReconciler, profile *profilev1.Profile, next Plugin) error
+}
+
+// getDroppedServiceAccounts returns the service accounts of previous that
+// aren't in next.
+func getDroppedServiceAccounts(previous []string, next []string) []string {
+	dropped := []string{}
+	for _, ksa := range previous {
+		if !containsString(next, ksa) {
+			dropped = append(dropped, ksa)
+		}
+	}
+	return dropped
+}
+
+// revokeDroppedServiceAccounts revokes with the spec the plugin was last
+// applied with the service accounts that the new plugin doesn't bind anymore.
+// Nothing is revoked if the spec wasn't recorded.
+func (r *ProfileReconciler) revokeDroppedServiceAccounts(profileIns *profilev1.Profile, spec profilev1.Plugin,
+	plugin Plugin, prev *profilev1.ProfilePluginStatus) error {
+	if prev == nil || prev.Spec == nil {
+		return nil
+	}
+	if _, ok := plugin.(PluginServiceAccountRevoker); !ok {
+		return nil
+	}
+	previous, err := r.getPlugin(profileIns, profilev1.Plugin{TypeMeta: spec.TypeMeta, Spec: prev.Spec})
+	if err != nil {
+		return err
+	}
+	revoker, ok := previous.(PluginServiceAccountRevoker)
+	if !ok {
+		return nil
+	}
+	return revoker.RevokeDroppedServiceAccounts(r, profileIns, plugin)
+}
+
+// getPluginSpecHash returns the hash of what the plugin is applied with: its
+// kind and spec, the generation of the ProfilePlugin of a webhook plugin and
+// the namespace, whose service accounts are recreated with it.
+func getPluginSpecHash(spec profilev1.Plugin, plugin Plugin, namespaceUID types.UID) (string, error) {
+	input := struct {
+		Kind                   string      `json:"kind"`
+		Spec                   interface{} `json:"spec,omitempty"`
+		RegistrationGeneration int64       `json:"registrationGeneration,omitempty"`
+		NamespaceUID           types.UID   `json:"namespaceUID,omitempty"`
+	}{Kind: spec.Kind, Spec: spec.Spec, NamespaceUID: namespaceUID}
+	if webhook, ok := plugin.(*WebhookPlugin); ok && webhook.Registration != nil {
+		input.RegistrationGeneration = webhook.Registration.Generation
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// getPluginStatus returns the status of the plugin of the kind, nil if it
+// wasn't applied.
+func getPluginStatus(statuses []profilev1.ProfilePluginStatus, kind string) *profilev1.ProfilePluginStatus {
+	for i := range statuses {
+		if statuses[i].Kind == kind {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
+
+// applyPlugins applies the plugins whose spec hash changed since they were
+// applied, once the service accounts they don't bind anymore are revoked, and
+// verifies the others once the drift interval passed since they were last
+// verified: the plugins that can check their drift are applied again only if
+// they drifted, the others are applied again. In between, only the service
+// accounts of the plugins are annotated again. It returns the status of the
+// plugins, and when the next one has to be verified. On error, the plugins
+// that weren't applied keep their previous status.
+func (r *ProfileReconciler) applyPlugins(profileIns *profilev1.Profile, specs []profilev1.Plugin, plugins []Plugin,
+	namespaceUID types.UID, now time.Time) ([]profilev1.ProfilePluginStatus, time.Duration, error) {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	previous := profileIns.Status.Plugins
+	statuses := []profilev1.ProfilePluginStatus{}
+	nextVerification := time.Duration(0)
+	for i, plugin := range plugins {
+		kind := specs[i].Kind
+		hash, err := getPluginSpecHash(specs[i], plugin, namespaceUID)
+		if err != nil {
+			return appendPreviousPluginStatuses(statuses, previous, specs[i:]), 0, err
+		}
+		status := profilev1.ProfilePluginStatus{Kind: kind, SpecHash: hash, Spec: specs[i].Spec.DeepCopy(),
+			LastAppliedTime: metav1.NewTime(now), LastVerifiedTime: metav1.NewTime(now)}
+		prev := getPluginStatus(previous, kind)
+
+		switch {
+		case prev == nil || prev.SpecHash != hash:
+			if err := r.revokeDroppedServiceAccounts(profileIns, specs[i], plugin, prev); err != nil {
+				return appendPreviousPluginStatuses(statuses, previous, specs[i:]), 0, err
+			}
+			logger.Info("Applying plugin", "kind", kind)
+			if err := plugin.ApplyPlugin(r, profileIns); err != nil {
+				return appendPreviousPluginStatuses(statuses, previous, specs[i:]), 0, err
+			}
+			IncRequestCounter("apply plugin")
+		case r.PluginDriftInterval <= 0 || now.Before(prev.LastVerifiedTime.Add(r.PluginDriftInterval)):
+			// Nothing changed, the external calls are skipped.
+			status = *prev.DeepCopy()
+			if annotator, ok := plugin.(PluginServiceAccountAnnotator); ok {
+				if err := annotator.AnnotateServiceAccounts(r, profileIns); err != nil {
+					return appendPreviousPluginStatuses(statuses, previous, specs[i:]), 0, err
+				}
+			}
+		default:
+			status.LastAppliedTime = prev.LastAppliedTime
+			checker, ok := plugin.(PluginDriftChecker)
+			if ok {
+				drift, err := checker.CheckPluginDrift(r, profileIns)
+				if err != nil {
+					return appendPreviousPluginStatuses(statuses, previous, specs[i:]), 0, err
+				}
+				status.Drift = strings.Join(drift, ", ")
+			}
+			if status.Drift != "" {
+				logger.Info("Plugin drifted, applying it again", "kind", kind, "drift", status.Drift)
+				IncRequestCounter("plugin drift")
+				r.Recorder.Event(profileIns, corev1.EventTypeWarning, reasonPluginDriftDetected,
+					fmt.Sprintf("Plugin %v drifted: %v", kind, status.Drift))
+			}
+			if !ok || status.Drift != "" {
+				if err := plugin.ApplyPlugin(r, profileIns); err != nil {
+					return appendPreviousPluginStatuses(statuses, previous, specs[i:]), 0, err
+				}
+				status.LastAppliedTime = metav1.NewTime(now)
+				IncRequestCounter("apply plugin")
+			}
+		}
+		statuses = append(statuses, status)
+
+		if r.PluginDriftInterval > 0 {
+			next := status.LastVerifiedTime.Add(r.PluginDriftInterval).Sub(now)
+			if nextVerification == 0 || next < nextVerification {
+				nextVerification = next
+			}
+		}
+	}
+	return statuses, nextVerification, nil
+}
+
+// appendPreviousPluginStatuses appends the previous status of the plugins
+// that weren't applied.
+func appendPreviousPluginStatuses(statuses []profilev1.ProfilePluginStatus, previous []profilev1.ProfilePluginStatus,
+	specs []profilev1.Plugin) []profilev1.ProfilePluginStatus {
+	for _, spec := range specs {
+		if prev := getPluginStatus(previous, spec.Kind); prev != nil {
+			statuses = append(statuses, *prev.DeepCopy())
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	"google.golang.org/api/iam/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// testPlugin counts how often it is applied, and reports drift when it can
+// check it.
+type testPlugin struct {
+	applied int
+	checked int
+	drift   []string
+}
+
+func (p *testPlugin) ApplyPlugin(*ProfileReconciler, *profilev1.Profile) error {
+	p.applied++
+	return nil
+}
+
+func (p *testPlugin) RevokePlugin(*ProfileReconciler, *profilev1.Profile) error {
+	return nil
+}
+
+type testDriftCheckerPlugin struct {
+	testPlugin
+}
+
+func (p *testDriftCheckerPlugin) CheckPluginDrift(*ProfileReconciler, *profilev1.Profile) ([]string, error) {
+	p.checked++
+	return p.drift, nil
+}
+
+func TestApplyPluginsSkipsUnchangedSpec(t *testing.T) {
+	r := newTestReconciler()
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"}}
+	specs := []profilev1.Plugin{{
+		TypeMeta: metav1.TypeMeta{Kind: KIND_AWS_IAM_FOR_SERVICE_ACCOUNT},
+		Spec:     &runtime.RawExtension{Raw: []byte(`{"awsIamRole":"arn:aws:iam::123456789012:role/a"}`)},
+	}}
+	plugin := &testPlugin{}
+	now := time.Now()
+
+	statuses, next, err := r.applyPlugins(profile, specs, []Plugin{plugin}, "uid", now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if plugin.applied != 1 || len(statuses) != 1 || statuses[0].SpecHash == "" || next != 0 {
+		t.Fatalf("Expected the plugin to be applied once, got %v applies, statuses %v", plugin.applied, statuses)
+	}
+
+	// Nothing changed, the plugin isn't applied again.
+	profile.Status.Plugins = statuses
+	if statuses, _, err = r.applyPlugins(profile, specs, []Plugin{plugin}, "uid", now.Add(time.Hour)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if plugin.applied != 1 {
+		t.Errorf("Expected the unchanged plugin to be skipped, got %v applies", plugin.applied)
+	}
+
+	// A recreated namespace applies the plugin again.
+	profile.Status.Plugins = statuses
+	if statuses, _, err = r.applyPlugins(profile, specs, []Plugin{plugin}, "other-uid", now); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if plugin.applied != 2 {
+		t.Errorf("Expected the plugin to be applied again, got %v applies", plugin.applied)
+	}
+
+	// So does a change of its spec.
+	profile.Status.Plugins = statuses
+	specs[0].Spec = &runtime.RawExtension{Raw: []byte(`{"awsIamRole":"arn:aws:iam::123456789012:role/b"}`)}
+	if _, _, err = r.applyPlugins(profile, specs, []Plugin{plugin}, "other-uid", now); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if plugin.applied != 3 {
+		t.Errorf("Expected the plugin to be applied again, got %v applies", plugin.applied)
+	}
+}
+
+func TestApplyPluginsAnnotatesUnchangedSpec(t *testing.T) {
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: DEFAULT_EDITOR, Namespace: "kubeflow-user"}}
+	r := newTestReconciler(sa)
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"}}
+	specs := []profilev1.Plugin{{
+		TypeMeta: metav1.TypeMeta{Kind: KIND_AWS_IAM_FOR_SERVICE_ACCOUNT},
+		Spec:     &runtime.RawExtension{Raw: []byte(`{"awsIamRole":"arn:aws:iam::123456789012:role/a"}`)},
+	}}
+	plugin := &AwsIAMForServiceAccount{AwsIAMRole: "arn:aws:iam::123456789012:role/a"}
+	hash, err := getPluginSpecHash(specs[0], plugin, "uid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	profile.Status.Plugins = []profilev1.ProfilePluginStatus{{Kind: KIND_AWS_IAM_FOR_SERVICE_ACCOUNT, SpecHash: hash,
+		LastAppliedTime: metav1.NewTime(now), LastVerifiedTime: metav1.NewTime(now)}}
+
+	// The recreated service account is annotated again.
+	if _, _, err := r.applyPlugins(profile, specs, []Plugin{plugin}, "uid", now); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	found := &corev1.ServiceAccount{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: DEFAULT_EDITOR, Namespace: "kubeflow-user"},
+		found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Annotations[AWS_ANNOTATION_KEY] != plugin.AwsIAMRole {
+		t.Errorf("Expected the service account to be annotated, got %v", found.Annotations)
+	}
+}
+
+func TestApplyPluginsRevokesDroppedServiceAccounts(t *testing.T) {
+	role := "arn:aws:iam::123456789012:role/a"
+	objects := []client.Object{}
+	for _, name := range []string{"a", "b"} {
+		objects = append(objects, &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name,
+			Namespace: "kubeflow-user", Annotations: map[string]string{AWS_ANNOTATION_KEY: role}}})
+	}
+	r := newTestReconciler(objects...)
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"}}
+	spec := func(ksas string) []profilev1.Plugin {
+		return []profilev1.Plugin{{
+			TypeMeta: metav1.TypeMeta{Kind: KIND_AWS_IAM_FOR_SERVICE_ACCOUNT},
+			Spec: &runtime.RawExtension{Raw: []byte(fmt.Sprintf(`{"awsIamRole":%q,"annotateOnly":true,`+
+				`"serviceAccounts":%v}`, role, ksas))},
+		}}
+	}
+	plugin := func(ksas ...string) []Plugin {
+		return []Plugin{&AwsIAMForServiceAccount{AwsIAMRole: role, AnnotateOnly: true, ServiceAccounts: ksas}}
+	}
+
+	statuses, _, err := r.applyPlugins(profile, spec(`["a","b"]`), plugin("a", "b"), "uid", time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if statuses[0].Spec == nil {
+		t.Fatalf("Expected the applied spec to be recorded, got %+v", statuses[0])
+	}
+
+	// b is dropped from the spec, its annotation is removed.
+	profile.Status.Plugins = statuses
+	if _, _, err := r.applyPlugins(profile, spec(`["a"]`), plugin("a"), "uid", time.Now()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for name, expected := range map[string]string{"a": role, "b": ""} {
+		found := &corev1.ServiceAccount{}
+		if err := r.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "kubeflow-user"},
+			found); err != nil {
+			t.Fatal(err)
+		}
+		if found.Annotations[AWS_ANNOTATION_KEY] != expected {
+			t.Errorf("Expected service account %v to be annotated with %q, got %v", name, expected,
+				found.Annotations)
+		}
+	}
+}
+
+func TestGetDroppedServiceAccounts(t *testing.T) {
+	dropped := getDroppedServiceAccounts([]string{"a", "b", "c"}, []string{"c", "a", "d"})
+	if !reflect.DeepEqual(dropped, []string{"b"}) {
+		t.Errorf("Expected b to be dropped, got %v", dropped)
+	}
+}
+
+func TestApplyPluginsVerifiesDrift(t *testing.T) {
+	r := newTestReconciler()
+	r.PluginDriftInterval = time.Hour
+	recorder := r.Recorder.(*record.FakeRecorder)
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"}}
+	specs := []profilev1.Plugin{
+		{TypeMeta: metav1.TypeMeta{Kind: "Checked"}},
+		{TypeMeta: metav1.TypeMeta{Kind: "Unchecked"}},
+	}
+	checked := &testDriftCheckerPlugin{}
+	unchecked := &testPlugin{}
+	plugins := []Plugin{checked, unchecked}
+	now := time.Now()
+
+	statuses, next, err := r.applyPlugins(profile, specs, plugins, "uid", now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if next != time.Hour {
+		t.Errorf("Expected the next verification in an hour, got %v", next)
+	}
+
+	// Before the interval, nothing is verified.
+	profile.Status.Plugins = statuses
+	statuses, next, err = r.applyPlugins(profile, specs, plugins, "uid", now.Add(40*time.Minute))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if checked.checked != 0 || checked.applied != 1 || unchecked.applied != 1 {
+		t.Errorf("Expected nothing to be verified, got %v checks, %v and %v applies",
+			checked.checked, checked.applied, unchecked.applied)
+	}
+	if next != 20*time.Minute {
+		t.Errorf("Expected the next verification in 20 minutes, got %v", next)
+	}
+
+	// Once it passed, the plugin that can check its drift is only checked,
+	// the other one is applied again.
+	profile.Status.Plugins = statuses
+	later := now.Add(2 * time.Hour)
+	statuses, _, err = r.applyPlugins(profile, specs, plugins, "uid", later)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if checked.checked != 1 || checked.applied != 1 || unchecked.applied != 2 {
+		t.Errorf("Expected 1 check, 1 and 2 applies, got %v checks, %v and %v applies",
+			checked.checked, checked.applied, unchecked.applied)
+	}
+	if !statuses[0].LastVerifiedTime.Time.Equal(metav1.NewTime(later).Time) ||
+		statuses[0].LastAppliedTime.Time.Equal(metav1.NewTime(later).Time) {
+		t.Errorf("Expected the plugin to be verified but not applied, got %v", statuses[0])
+	}
+
+	// A drifted plugin is applied again, and the drift is reported.
+	profile.Status.Plugins = statuses
+	checked.drift = []string{"service account default-editor isn't annotated"}
+	statuses, _, err = r.applyPlugins(profile, specs, plugins, "uid", later.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if checked.applied != 2 || statuses[0].Drift != checked.drift[0] {
+		t.Errorf("Expected the drifted plugin to be applied again, got %v applies, status %v",
+			checked.applied, statuses[0])
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, reasonPluginDriftDetected) {
+			t.Errorf("Unexpected event %v", event)
+		}
+	default:
+		t.Errorf("Expected the drift to be recorded")
+	}
+
+	c := &profileConditions{}
+	c.setPlugins(statuses)
+	if c.pluginsInSync.Status != string(corev1.ConditionFalse) || c.pluginsInSync.Reason != reasonPluginDriftDetected ||
+		!strings.Contains(c.pluginsInSync.Message, "Checked") {
+		t.Errorf("Unexpected condition %v", c.pluginsInSync)
+	}
+}
+
+func TestHasBinding(t *testing.T) {
+	member := "serviceAccount:project.svc.id.goog[kubeflow-user/default-editor]"
+	policy := &iam.Policy{Bindings: []*iam.Binding{
+		{Role: "roles/viewer", Members: []string{member}},
+	}}
+	if hasBinding(policy, member) {
+		t.Errorf("Expected only the %v bindings to count", WORKLOAD_IDENTITY_ROLE)
+	}
+	addBinding(policy, member)
+	if !hasBinding(policy, member) {
+		t.Errorf("Expected the binding to be found")
+	}
+	revokeBinding(policy, member)
+	if hasBinding(policy, member) {
+		t.Errorf("Expected the revoked binding not to be found")
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const defaultWebhookTimeout = 10 * time.Second
+
+// maxWebhookResponseSize bounds the body read from a plugin webhook.
+const maxWebhookResponseSize = 1 << 20
+
+// WebhookPlugin: plugin of a kind registered by a ProfilePlugin. Applying and
+// revoking it calls the webhook of the ProfilePlugin, which returns patches
+// of objects in the profile namespace.
+type WebhookPlugin struct {
+	Registration *profilev1.ProfilePlugin
+	Spec         *runtime.RawExtension
+}
+
+// ApplyPlugin calls the webhook with the apply phase.
+func (w *WebhookPlugin) ApplyPlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
+	return w.call(r, profile, profilev1.ProfilePluginApply)
+}
+
+// RevokePlugin calls the webhook with the revoke phase.
+func (w *WebhookPlugin) RevokePlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
+	return w.call(r, profile, profilev1.ProfilePluginRevoke)
+}
+
+func (w *WebhookPlugin) call(r *ProfileReconciler, profile *profilev1.Profile, phase profilev1.ProfilePluginPhase) error {
+	logger := r.Log.WithValues("profile", profile.Name, "plugin", w.Registration.Name)
+	spec := w.Registration.Spec
+
+	webhookURL, err := getWebhookURL(spec.ClientConfig)
+	if err != nil {
+		return fmt.Errorf("invalid ProfilePlugin %v: %v", w.Registration.Name, err)
+	}
+	httpClient, err := getWebhookClient(spec)
+	if err != nil {
+		return fmt.Errorf("invalid ProfilePlugin %v: %v", w.Registration.Name, err)
+	}
+
+	// The Profile read by the client has no type information.
+	profileCopy := profile.DeepCopy()
+	profileCopy.SetGroupVersionKind(profilev1.GroupVersion.WithKind("Profile"))
+	profileCopy.ManagedFields = nil
+	body, err := json.Marshal(profilev1.ProfilePluginRequest{
+		Phase:   phase,
+		Profile: profileCopy,
+		Spec:    w.Spec,
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Calling plugin webhook", "kind", spec.PluginKind, "phase", phase)
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%v plugin webhook %v: %v", spec.PluginKind, w.Registration.Name, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxWebhookResponseSize))
+	if err != nil {
+		return fmt.Errorf("%v plugin webhook %v: %v", spec.PluginKind, w.Registration.Name, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%v plugin webhook %v returned %v: %s", spec.PluginKind, w.Registration.Name,
+			resp.Status, respBody)
+	}
+
+	response := profilev1.ProfilePluginResponse{}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return fmt.Errorf("%v plugin webhook %v returned an invalid response: %v", spec.PluginKind,
+			w.Registration.Name, err)
+	}
+	if response.Status != profilev1.ProfilePluginSuccess {
+		return fmt.Errorf("%v plugin webhook %v failed: %v", spec.PluginKind, w.Registration.Name,
+			response.Message)
+	}
+
+	ctx := context.Background()
+	for _, patch := range response.Patches {
+		logger.Info("Applying plugin patch", "kind", patch.Kind, "name", patch.Name)
+		if err := applyPluginPatch(ctx, r, profile.Name, patch); err != nil {
+			return fmt.Errorf("%v plugin webhook %v: patching %v %v: %v", spec.PluginKind,
+				w.Registration.Name, patch.Kind, patch.Name, err)
+		}
+	}
+	return nil
+}
+
+// applyPluginPatch patches an object of the profile namespace, with the
+// permissions of the profile controller. Objects of cluster scoped kinds
+// can't be patched, since they aren't in the namespace.
+func applyPluginPatch(ctx context.Context, r *ProfileReconciler, namespace string,
+	patch profilev1.ProfilePluginPatch) error {
+	gvk := schema.FromAPIVersionAndKind(patch.APIVersion, patch.Kind)
+	mapping, err := r.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return fmt.Errorf("%v is not a namespaced kind", gvk)
+	}
+	patchType := patch.Type
+	if patchType == "" {
+		patchType = types.MergePatchType
+	}
+	if patchType != types.MergePatchType && patchType != types.JSONPatchType {
+		return fmt.Errorf("unsupported patch type %v", patchType)
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(patch.APIVersion)
+	obj.SetKind(patch.Kind)
+	obj.SetName(patch.Name)
+	obj.SetNamespace(namespace)
+	return r.Patch(ctx, obj, client.RawPatch(patchType, patch.Patch))
+}
+
+// getWebhookURL returns the https URL of the webhook, built from the service
+// reference if no URL is set.
+func getWebhookURL(config admissionregistrationv1.WebhookClientConfig) (string, error) {
+	if config.URL != nil {
+		u, err := url.Parse(*config.URL)
+		if err != nil {
+			return "", err
+		}
+		if u.Scheme != "https" {
+			return "", fmt.Errorf("webhook URL %v must use https", *config.URL)
+		}
+		return u.String(), nil
+	}
+	if config.Service == nil {
+		return "", fmt.Errorf("one of url or service must be set")
+	}
+	port := int32(443)
+	if config.Service.Port != nil {
+		port = *config.Service.Port
+	}
+	u := url.URL{
+		Scheme: "https",
+		Host:   fmt.Sprintf("%v.%v.svc:%v", config.Service.Name, config.Service.Namespace, port),
+	}
+	if config.Service.Path != nil {
+		u.Path = *config.Service.Path
+	}
+	return u.String(), nil
+}
+
+// getWebhookClient returns an HTTP client trusting the CA bundle of the
+// ProfilePlugin, or the system roots if it has none.
+func getWebhookClient(spec profilev1.ProfilePluginSpec) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(spec.ClientConfig.CABundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(spec.ClientConfig.CABundle) {
+			return nil, fmt.Errorf("caBundle has no valid PEM certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	timeout := defaultWebhookTimeout
+	if spec.TimeoutSeconds != nil {
+		timeout = time.Duration(*spec.TimeoutSeconds) * time.Second
+	}
+	// A client is built for every call, so don't keep idle connections.
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig:   tlsConfig,
+			DisableKeepAlives: true,
+		},
+	}, nil
+}
+
+// getProfilePlugin returns the ProfilePlugin registered for a plugin kind.
+func (r *ProfileReconciler) getProfilePlugin(kind string) (*profilev1.ProfilePlugin, error) {
+	registrations := &profilev1.ProfilePluginList{}
+	if err := r.List(context.TODO(), registrations); err != nil {
+		return nil, err
+	}
+	for i := range registrations.Items {
+		if registrations.Items[i].Spec.PluginKind == kind {
+			return &registrations.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("plugin kind %v is not registered by any ProfilePlugin", kind)
+}
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func newTestProfilePlugin(server *httptest.Server) *profilev1.ProfilePlugin {
+	url := server.URL + "/billing"
+	return &profilev1.ProfilePlugin{
+		ObjectMeta: metav1.ObjectMeta{Name: "acme-billing"},
+		Spec: profilev1.ProfilePluginSpec{
+			PluginKind: "AcmeBilling",
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				URL: &url,
+				CABundle: pem.EncodeToMemory(&pem.Block{
+					Type:  "CERTIFICATE",
+					Bytes: server.Certificate().Raw,
+				}),
+			},
+		},
+	}
+}
+
+func TestWebhookPlugin(t *testing.T) {
+	var requests []profilev1.ProfilePluginRequest
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := profilev1.ProfilePluginRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Invalid request: %v", err)
+		}
+		requests = append(requests, request)
+		response := profilev1.ProfilePluginResponse{Status: profilev1.ProfilePluginSuccess}
+		if request.Phase == profilev1.ProfilePluginApply {
+			response.Patches = []profilev1.ProfilePluginPatch{{
+				APIVersion: "v1",
+				Kind:       "ServiceAccount",
+				Name:       DEFAULT_EDITOR,
+				Patch:      json.RawMessage(`{"metadata":{"annotations":{"acme.com/account":"1234"}}}`),
+			}}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	namespace := "kubeflow-user"
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: namespace},
+		Spec: profilev1.ProfileSpec{
+			Plugins: []profilev1.Plugin{{
+				TypeMeta: metav1.TypeMeta{Kind: "AcmeBilling"},
+				Spec:     &runtime.RawExtension{Raw: []byte(`{"account":"1234"}`)},
+			}},
+		},
+	}
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: DEFAULT_EDITOR, Namespace: namespace},
+	}
+	r := newTestReconciler(profile, serviceAccount, newTestProfilePlugin(server))
+
+	plugins, err := r.GetPluginSpec(profile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(plugins) != 1 {
+		t.Fatalf("Expected 1 plugin, got %d", len(plugins))
+	}
+	if err := plugins[0].ApplyPlugin(r, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := plugins[0].RevokePlugin(r, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(requests) != 2 || requests[0].Phase != profilev1.ProfilePluginApply ||
+		requests[1].Phase != profilev1.ProfilePluginRevoke {
+		t.Fatalf("Expected an apply and a revoke request, got %+v", requests)
+	}
+	if requests[0].Profile.Name != namespace || requests[0].Profile.Kind != "Profile" ||
+		string(requests[0].Spec.Raw) != `{"account":"1234"}` {
+		t.Errorf("Unexpected request %+v", requests[0])
+	}
+
+	found := &corev1.ServiceAccount{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: DEFAULT_EDITOR, Namespace: namespace},
+		found); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if found.Annotations["acme.com/account"] != "1234" {
+		t.Errorf("Expected the ServiceAccount to be patched, got %v", found.Annotations)
+	}
+}
+
+func TestWebhookPluginFailure(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(profilev1.ProfilePluginResponse{
+			Status:  profilev1.ProfilePluginFailure,
+			Message: "account 1234 is closed",
+		})
+	}))
+	defer server.Close()
+
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"},
+		Spec: profilev1.ProfileSpec{
+			Plugins: []profilev1.Plugin{{TypeMeta: metav1.TypeMeta{Kind: "AcmeBilling"}}},
+		},
+	}
+	r := newTestReconciler(profile, newTestProfilePlugin(server))
+	plugins, err := r.GetPluginSpec(profile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = plugins[0].ApplyPlugin(r, profile)
+	if err == nil || !strings.Contains(err.Error(), "account 1234 is closed") {
+		t.Fatalf("Expected the message of the webhook, got %v", err)
+	}
+}
+
+func TestWebhookPluginClusterScopedPatch(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(profilev1.ProfilePluginResponse{
+			Status: profilev1.ProfilePluginSuccess,
+			Patches: []profilev1.ProfilePluginPatch{{
+				APIVersion: "rbac.authorization.k8s.io/v1",
+				Kind:       "ClusterRole",
+				Name:       kubeflowAdmin,
+				Patch:      json.RawMessage(`{"rules":[{"apiGroups":["*"],"resources":["*"],"verbs":["*"]}]}`),
+			}},
+		})
+	}))
+	defer server.Close()
+
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"},
+		Spec: profilev1.ProfileSpec{
+			Plugins: []profilev1.Plugin{{TypeMeta: metav1.TypeMeta{Kind: "AcmeBilling"}}},
+		},
+	}
+	clusterRole := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: kubeflowAdmin}}
+	r := newTestReconciler(profile, clusterRole, newTestProfilePlugin(server))
+	plugins, err := r.GetPluginSpec(profile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = plugins[0].ApplyPlugin(r, profile)
+	if err == nil || !strings.Contains(err.Error(), "not a namespaced kind") {
+		t.Fatalf("Expected the cluster scoped patch to be rejected, got %v", err)
+	}
+	found := &rbacv1.ClusterRole{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: kubeflowAdmin}, found); err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Rules) != 0 {
+		t.Errorf("Expected the ClusterRole not to be patched, got %v", found.Rules)
+	}
+}
+
+func TestGetPluginSpecUnregistered(t *testing.T) {
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"},
+		Spec: profilev1.ProfileSpec{
+			Plugins: []profilev1.Plugin{{TypeMeta: metav1.TypeMeta{Kind: "Unknown"}}},
+		},
+	}
+	if _, err := newTestReconciler(profile).GetPluginSpec(profile); err == nil {
+		t.Errorf("Expected an error for a plugin kind without ProfilePlugin")
+	}
+}
+
+func TestReconcileUnresolvedPlugin(t *testing.T) {
+	var phases []profilev1.ProfilePluginPhase
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := profilev1.ProfilePluginRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Invalid request: %v", err)
+		}
+		phases = append(phases, request.Phase)
+		json.NewEncoder(w).Encode(profilev1.ProfilePluginResponse{Status: profilev1.ProfilePluginSuccess})
+	}))
+	defer server.Close()
+
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user", Finalizers: []string{PROFILEFINALIZER}},
+		Spec: profilev1.ProfileSpec{
+			Owner: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "user@example.com"},
+			Plugins: []profilev1.Plugin{
+				{TypeMeta: metav1.TypeMeta{Kind: "AcmeBilling"}},
+				{TypeMeta: metav1.TypeMeta{Kind: "Unknown"}},
+			},
+		},
+	}
+	r := newTestReconciler(profile, newTestProfilePlugin(server))
+	r.DefaultNamespaceLabelsPath = newTestLabelsFile(t)
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: profile.Name}}
+
+	// The registered plugin is applied even though the other one can't be resolved.
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(phases) != 1 || phases[0] != profilev1.ProfilePluginApply {
+		t.Fatalf("Expected the registered plugin to be applied, got %v", phases)
+	}
+
+	// On deletion, it is revoked, but the finalizer is kept for the other one.
+	found := &profilev1.Profile{}
+	if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Delete(ctx, found); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(phases) != 2 || phases[1] != profilev1.ProfilePluginRevoke {
+		t.Errorf("Expected the registered plugin to be revoked, got %v", phases)
+	}
+	if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+		t.Fatalf("Expected the finalizer to be kept, got %v", err)
+	}
+	for _, c := range found.Status.Conditions {
+		if c.Type == profilev1.ProfilePluginsReady &&
+			(c.Status != string(corev1.ConditionFalse) || !strings.Contains(c.Message, "Unknown")) {
+			t.Errorf("Unexpected condition %+v", c)
+		}
+	}
+}
+
+func TestGetWebhookURL(t *testing.T) {
+	path := "/apply"
+	port := int32(8443)
+	url, err := getWebhookURL(admissionregistrationv1.WebhookClientConfig{
+		Service: &admissionregistrationv1.ServiceReference{
+			Name:      "billing",
+			Namespace: "acme",
+			Path:      &path,
+			Port:      &port,
+		},
+	})
+	if err != nil || url != "https://billing.acme.svc:8443/apply" {
+		t.Errorf("Unexpected URL %v, %v", url, err)
+	}
+
+	insecure := "http://billing.acme.svc/apply"
+	if _, err := getWebhookURL(admissionregistrationv1.WebhookClientConfig{URL: &insecure}); err == nil {
+		t.Errorf("Expected an error for a plain http URL")
+	}
+}
@@ -38,16 +38,42 @@ const WORKLOAD_IDENTITY_ROLE = "roles/iam.workloadIdentityUser"
 // GcpWorkloadIdentity: plugin that setup GKE workload identity (credentials for GCP API) for target profile namespace.
 type GcpWorkloadIdentity struct {
 	GcpServiceAccount string `json:"gcpServiceAccount,omitempty"`
+	// Service accounts of the profile namespace to bind, DEFAULT_EDITOR by default
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
 }
 
-// ApplyPlugin will grant GCP workload identity to service account DEFAULT_EDITOR
+// ApplyPlugin will grant GCP workload identity to the service accounts, DEFAULT_EDITOR by default
 func (gcp *GcpWorkloadIdentity) ApplyPlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
 	logger := r.Log.WithValues("profile", profile.Name)
-	if err := gcp.patchAnnotation(r, profile.Name, DEFAULT_EDITOR, logger); err != nil {
-		return err
+	for _, ksa := range gcp.getServiceAccounts() {
+		if err := gcp.patchAnnotation(r, profile.Name, ksa, logger); err != nil {
+			return err
+		}
+		logger.Info("Setting up iam policy.", "ServiceAccount", gcp.GcpServiceAccount, "KubernetesServiceAccount", ksa)
+		if err := gcp.updateWorkloadIdentity(profile.Name, ksa, addBinding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AnnotateServiceAccounts annotates the service accounts with GcpServiceAccount.
+func (gcp *GcpWorkloadIdentity) AnnotateServiceAccounts(r *ProfileReconciler, profile *profilev1.Profile) error {
+	logger := r.Log.WithValues("profile", profile.Name)
+	for _, ksa := range gcp.getServiceAccounts() {
+		if err := gcp.patchAnnotation(r, profile.Name, ksa, logger); err != nil {
+			return err
+		}
 	}
-	logger.Info("Setting up iam policy.", "ServiceAccount", gcp.GcpServiceAccount)
-	return gcp.updateWorkloadIdentity(profile.Name, DEFAULT_EDITOR, addBinding)
+	return nil
+}
+
+// getServiceAccounts returns the service accounts bound to GcpServiceAccount
+func (gcp *GcpWorkloadIdentity) getServiceAccounts() []string {
+	if len(gcp.ServiceAccounts) == 0 {
+		return []string{DEFAULT_EDITOR}
+	}
+	return gcp.ServiceAccounts
 }
 
 // GetProjectID will return GCP project id of GcpServiceAccount. Will return empty string if cannot parse GcpServiceAccount
@@ -72,6 +98,9 @@ func (gcp *GcpWorkloadIdentity) patchAnnotation(r *ProfileReconciler, namespace
 	if err != nil {
 		return err
 	}
+	if found.Annotations[GCP_ANNOTATION_KEY] == gcp.GcpServiceAccount {
+		return nil
+	}
 	if found.Annotations == nil {
 		found.Annotations = map[string]string{GCP_ANNOTATION_KEY: gcp.GcpServiceAccount}
 	} else {
@@ -83,52 +112,106 @@ func (gcp *GcpWorkloadIdentity) patchAnnotation(r *ProfileReconciler, namespace
 
 // updateWorkloadIdentity update GCP service account IAM binding with provided binding update function f
 func (gcp *GcpWorkloadIdentity) updateWorkloadIdentity(namespace string, ksa string, f func(*iam.Policy, string)) error {
-	projectID, err := gcp.GetProjectID()
+	ctx := context.Background()
+	iamService, saResource, currentPolicy, ksaProjectID, err := gcp.getIamPolicy(ctx)
 	if err != nil {
 		return err
 	}
-	ctx := context.Background()
+
+	// Update policy
+	f(currentPolicy, getBindingMember(ksaProjectID, namespace, ksa))
+
+	// Set iam policy
+	req := &iam.SetIamPolicyRequest{
+		Policy: currentPolicy,
+	}
+	_, err = iamService.Projects.ServiceAccounts.SetIamPolicy(saResource, req).Context(ctx).Do()
+	return err
+}
+
+// getIamPolicy returns the Cloud IAM service, the resource and the IAM policy of GcpServiceAccount, and the project
+// of the identity namespace of the bindings.
+func (gcp *GcpWorkloadIdentity) getIamPolicy(ctx context.Context) (*iam.Service, string, *iam.Policy, string, error) {
+	projectID, err := gcp.GetProjectID()
+	if err != nil {
+		return nil, "", nil, "", err
+	}
 	gcpSa := gcp.GcpServiceAccount
 	// Get client.
 	client, err := google.DefaultClient(ctx, iam.CloudPlatformScope)
 	if err != nil {
-		return err
+		return nil, "", nil, "", err
 	}
 
 	// Create the Cloud IAM service object.
 	iamService, err := iam.New(client)
 	if err != nil {
-		return err
+		return nil, "", nil, "", err
 	}
 	saResource := fmt.Sprintf("projects/%v/serviceAccounts/%v", projectID, gcpSa)
 
 	// Get credentials.
 	credentials, err := google.FindDefaultCredentials(ctx, iam.CloudPlatformScope)
 	if err != nil {
-		return err
+		return nil, "", nil, "", err
 	}
 
 	// Get policy
 	currentPolicy, err := iamService.Projects.ServiceAccounts.GetIamPolicy(saResource).Context(ctx).Do()
 	if err != nil {
-		return err
+		return nil, "", nil, "", err
 	}
 
-	// Update policy
 	// Use ProjectID from the default credentials for identity namespace if it's not empty in case gcpSa is from a different project
 	ksaProjectID := credentials.ProjectID
 	if ksaProjectID == "" {
 		ksaProjectID = projectID
 	}
-	bindingMember := fmt.Sprintf("serviceAccount:%v.svc.id.goog[%v/%v]", ksaProjectID, namespace, ksa)
-	f(currentPolicy, bindingMember)
+	return iamService, saResource, currentPolicy, ksaProjectID, nil
+}
 
-	// Set iam policy
-	req := &iam.SetIamPolicyRequest{
-		Policy: currentPolicy,
+// getBindingMember returns the IAM member of the k8s service account
+func getBindingMember(ksaProjectID string, namespace string, ksa string) string {
+	return fmt.Sprintf("serviceAccount:%v.svc.id.goog[%v/%v]", ksaProjectID, namespace, ksa)
+}
+
+// CheckPluginDrift returns the service accounts that lost the annotation of GcpServiceAccount, or its
+// WORKLOAD_IDENTITY_ROLE binding. The IAM policy is read once.
+func (gcp *GcpWorkloadIdentity) CheckPluginDrift(r *ProfileReconciler, profile *profilev1.Profile) ([]string, error) {
+	ctx := context.Background()
+	_, _, currentPolicy, ksaProjectID, err := gcp.getIamPolicy(ctx)
+	if err != nil {
+		return nil, err
 	}
-	_, err = iamService.Projects.ServiceAccounts.SetIamPolicy(saResource, req).Context(ctx).Do()
-	return err
+	drift := []string{}
+	for _, ksa := range gcp.getServiceAccounts() {
+		found := &corev1.ServiceAccount{}
+		if err := r.Get(ctx, types.NamespacedName{Name: ksa, Namespace: profile.Name}, found); err != nil {
+			return nil, err
+		}
+		if found.Annotations[GCP_ANNOTATION_KEY] != gcp.GcpServiceAccount {
+			drift = append(drift, fmt.Sprintf("service account %v isn't annotated with %v", ksa, gcp.GcpServiceAccount))
+		}
+		if !hasBinding(currentPolicy, getBindingMember(ksaProjectID, profile.Name, ksa)) {
+			drift = append(drift, fmt.Sprintf("%v isn't bound to service account %v", gcp.GcpServiceAccount, ksa))
+		}
+	}
+	return drift, nil
+}
+
+// hasBinding returns whether the policy has a binding for <member, WORKLOAD_IDENTITY_ROLE>
+func hasBinding(currentPolicy *iam.Policy, member string) bool {
+	for _, binding := range currentPolicy.Bindings {
+		if binding.Role != WORKLOAD_IDENTITY_ROLE {
+			continue
+		}
+		for _, m := range binding.Members {
+			if m == member {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // addBinding add binding for <member, WORKLOAD_IDENTITY_ROLE>
@@ -154,7 +237,30 @@ func revokeBinding(currentPolicy *iam.Policy, member string) {
 
 // RevokePlugin: undo changes made by ApplyPlugin.
 func (gcp *GcpWorkloadIdentity) RevokePlugin(r *ProfileReconciler, profile *profilev1.Profile) error {
+	return gcp.revokeServiceAccounts(r, profile, gcp.getServiceAccounts())
+}
+
+// RevokeDroppedServiceAccounts revokes the bindings of the service accounts next doesn't bind to GcpServiceAccount
+// anymore.
+func (gcp *GcpWorkloadIdentity) RevokeDroppedServiceAccounts(r *ProfileReconciler, profile *profilev1.Profile,
+	next Plugin) error {
+	nextGcp, ok := next.(*GcpWorkloadIdentity)
+	if !ok || nextGcp.GcpServiceAccount != gcp.GcpServiceAccount {
+		return gcp.revokeServiceAccounts(r, profile, gcp.getServiceAccounts())
+	}
+	return gcp.revokeServiceAccounts(r, profile, getDroppedServiceAccounts(gcp.getServiceAccounts(),
+		nextGcp.getServiceAccounts()))
+}
+
+// revokeServiceAccounts removes the bindings of the service accounts to GcpServiceAccount.
+func (gcp *GcpWorkloadIdentity) revokeServiceAccounts(r *ProfileReconciler, profile *profilev1.Profile,
+	ksas []string) error {
 	logger := r.Log.WithValues("profile", profile.Name)
-	logger.Info("Clean up Gcp Workload Identity.", "ServiceAccount", gcp.GcpServiceAccount)
-	return gcp.updateWorkloadIdentity(profile.Name, DEFAULT_EDITOR, revokeBinding)
+	for _, ksa := range ksas {
+		logger.Info("Clean up Gcp Workload Identity.", "ServiceAccount", gcp.GcpServiceAccount, "KubernetesServiceAccount", ksa)
+		if err := gcp.updateWorkloadIdentity(profile.Name, ksa, revokeBinding); err != nil {
+			return err
+		}
+	}
+	return nil
 }
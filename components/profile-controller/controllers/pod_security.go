@@ -0,0 +1,281 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Prefix of the Pod Security Admission labels of a namespace, followed by
+// the mode, and by -version for the version of the mode.
+const POD_SECURITY_LABEL_PREFIX = "pod-security.kubernetes.io/"
+
+// Levels of the Pod Security Standards, from the least to the most strict
+const (
+	podSecurityPrivileged = "privileged"
+	podSecurityBaseline   = "baseline"
+	podSecurityRestricted = "restricted"
+)
+
+var podSecurityLevels = map[string]int{
+	podSecurityPrivileged: 0,
+	podSecurityBaseline:   1,
+	podSecurityRestricted: 2,
+}
+
+// podSecurityRecheckInterval is how often the pods that hold back a stricter
+// enforced level are checked again, since they aren't watched.
+const podSecurityRecheckInterval = 10 * time.Minute
+
+// The violations of this many pods at most are listed in the condition.
+const maxReportedPodSecurityViolations = 5
+
+// POD_SECURITY_LABELS_ANNOTATION lists the Pod Security labels the
+// controller set on the namespace, to remove them once no level is set.
+const POD_SECURITY_LABELS_ANNOTATION = "profiles.kubeflow.org/pod-security-labels"
+
+// readDefaultPodSecurityFromFile reads the Pod Security Admission levels of
+// the namespaces whose Profile doesn't set them. There are none if the file
+// doesn't exist or is empty.
+func readDefaultPodSecurityFromFile(path string) (*profilev1.ProfilePodSecurity, error) {
+	if path == "" {
+		return nil, nil
+	}
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	podSecurity := &profilev1.ProfilePodSecurity{}
+	if err := yaml.UnmarshalStrict(dat, podSecurity); err != nil {
+		return nil, errors.Wrapf(err, "Unable to parse default pod security %s", path)
+	}
+	for _, level := range []string{podSecurity.Enforce, podSecurity.Audit, podSecurity.Warn} {
+		if _, ok := podSecurityLevels[level]; level != "" && !ok {
+			return nil, fmt.Errorf("Unknown pod security level %v in %s", level, path)
+		}
+	}
+	if *podSecurity == (profilev1.ProfilePodSecurity{}) {
+		return nil, nil
+	}
+	return podSecurity, nil
+}
+
+// getProfilePodSecurity returns the levels of the Profile, with the cluster
+// default for the ones it doesn't set, or nil if there are none.
+func getProfilePodSecurity(profileIns *profilev1.Profile,
+	defaultPodSecurity *profilev1.ProfilePodSecurity) *profilev1.ProfilePodSecurity {
+	podSecurity := &profilev1.ProfilePodSecurity{}
+	if defaultPodSecurity != nil {
+		*podSecurity = *defaultPodSecurity
+	}
+	if spec := profileIns.Spec.PodSecurity; spec != nil {
+		if spec.Enforce != "" {
+			podSecurity.Enforce = spec.Enforce
+		}
+		if spec.Audit != "" {
+			podSecurity.Audit = spec.Audit
+		}
+		if spec.Warn != "" {
+			podSecurity.Warn = spec.Warn
+		}
+		if spec.Version != "" {
+			podSecurity.Version = spec.Version
+		}
+	}
+	if podSecurity.Enforce == "" && podSecurity.Audit == "" && podSecurity.Warn == "" {
+		return nil
+	}
+	return podSecurity
+}
+
+// getPodSecurityLabels returns the namespace labels of the levels. The
+// version labels are only set with a version.
+func getPodSecurityLabels(podSecurity *profilev1.ProfilePodSecurity) map[string]string {
+	labels := map[string]string{}
+	for mode, level := range map[string]string{
+		"enforce": podSecurity.Enforce,
+		"audit":   podSecurity.Audit,
+		"warn":    podSecurity.Warn,
+	} {
+		if level == "" {
+			continue
+		}
+		labels[POD_SECURITY_LABEL_PREFIX+mode] = level
+		if podSecurity.Version != "" {
+			labels[POD_SECURITY_LABEL_PREFIX+mode+"-version"] = podSecurity.Version
+		}
+	}
+	return labels
+}
+
+// podSecurityWarnings collects the warnings of the API server about the pods
+// that violate a Pod Security level.
+type podSecurityWarnings struct {
+	violations []string
+}
+
+func (w *podSecurityWarnings) HandleWarningHeader(code int, agent string, text string) {
+	// The first warning introduces the pods that follow.
+	if code != 299 || text == "" || strings.HasPrefix(text, "existing pods in namespace") {
+		return
+	}
+	w.violations = append(w.violations, text)
+}
+
+// getPodSecurityViolations updates the enforce labels of the namespace to
+// the level and the version with a server-side dry-run. The API server checks
+// the pods of the namespace against them, and returns a warning for every pod
+// that violates them, with its violations. The pods aren't checked without
+// REST client.
+func (r *ProfileReconciler) getPodSecurityViolations(ctx context.Context, ns *corev1.Namespace,
+	level string, version string) ([]string, error) {
+	if r.CoreRESTClient == nil {
+		return nil, nil
+	}
+	updated := ns.DeepCopy()
+	// The namespace may be updated by the caller.
+	updated.ResourceVersion = ""
+	updated.Labels[POD_SECURITY_LABEL_PREFIX+"enforce"] = level
+	if version != "" {
+		updated.Labels[POD_SECURITY_LABEL_PREFIX+"enforce-version"] = version
+	} else {
+		delete(updated.Labels, POD_SECURITY_LABEL_PREFIX+"enforce-version")
+	}
+	warnings := &podSecurityWarnings{}
+	err := r.CoreRESTClient.Put().
+		Resource("namespaces").
+		Name(ns.Name).
+		Param("dryRun", metav1.DryRunAll).
+		Body(updated).
+		WarningHandler(warnings).
+		Do(ctx).
+		Error()
+	if err != nil {
+		return nil, err
+	}
+	return warnings.violations, nil
+}
+
+// formatPodSecurityViolations describes the pods that hold back the level
+// in a condition message.
+func formatPodSecurityViolations(level string, violations []string) string {
+	pods := violations
+	if len(pods) > maxReportedPodSecurityViolations {
+		pods = append(pods[:maxReportedPodSecurityViolations:maxReportedPodSecurityViolations],
+			fmt.Sprintf("and %d more", len(violations)-maxReportedPodSecurityViolations))
+	}
+	return fmt.Sprintf("the %v level isn't enforced yet, pods violate it: %v", level, strings.Join(pods, "; "))
+}
+
+// reconcilePodSecurity sets the Pod Security Admission labels of the Profile
+// on the namespace, which is updated by the caller, and removes the ones it
+// set before that the Profile doesn't have anymore. A change of the enforced
+// level or version is checked first against the pods of the namespace, and
+// held back while some violate it: the namespace keeps enforcing its current
+// level, the PodSecurityEnforced condition lists the violations and a warning
+// event is recorded.
+func (r *ProfileReconciler) reconcilePodSecurity(ctx context.Context, profileIns *profilev1.Profile,
+	ns *corev1.Namespace, conditions *profileConditions) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	defaultPodSecurity, err := readDefaultPodSecurityFromFile(r.DefaultPodSecurityPath)
+	if err != nil {
+		return err
+	}
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	previous := getAppliedNamespaceMetadataKeys(ns, POD_SECURITY_LABELS_ANNOTATION)
+	podSecurity := getProfilePodSecurity(profileIns, defaultPodSecurity)
+	labels := map[string]string{}
+	if podSecurity != nil {
+		labels = getPodSecurityLabels(podSecurity)
+	}
+
+	violations := []string{}
+	enforceLabel := POD_SECURITY_LABEL_PREFIX + "enforce"
+	current := ns.Labels[enforceLabel]
+	// A namespace that isn't created yet has no pods.
+	if ns.ResourceVersion != "" && labels[enforceLabel] != "" && (labels[enforceLabel] != current ||
+		labels[enforceLabel+"-version"] != ns.Labels[enforceLabel+"-version"]) {
+		violations, err = r.getPodSecurityViolations(ctx, ns, podSecurity.Enforce, podSecurity.Version)
+		if err != nil {
+			return err
+		}
+	}
+	applied := []string{}
+	if len(violations) > 0 {
+		// The enforce labels set before stay as they are.
+		delete(labels, enforceLabel)
+		delete(labels, enforceLabel+"-version")
+		for _, k := range previous {
+			if k == enforceLabel || k == enforceLabel+"-version" {
+				applied = append(applied, k)
+			}
+		}
+	}
+	for k, v := range labels {
+		ns.Labels[k] = v
+		applied = append(applied, k)
+	}
+	for _, k := range previous {
+		if _, ok := labels[k]; !ok && !containsString(applied, k) {
+			delete(ns.Labels, k)
+		}
+	}
+	setAppliedNamespaceMetadataKeys(ns, POD_SECURITY_LABELS_ANNOTATION, applied)
+	if podSecurity == nil {
+		return nil
+	}
+
+	if len(violations) == 0 {
+		message := ""
+		if podSecurity.Enforce != "" {
+			message = fmt.Sprintf("the %v level is enforced", podSecurity.Enforce)
+		}
+		conditions.setPodSecurity(corev1.ConditionTrue, reasonReconciled, message)
+		return nil
+	}
+	message := formatPodSecurityViolations(podSecurity.Enforce, violations)
+	conditions.setPodSecurity(corev1.ConditionFalse, reasonPodSecurityViolations, message)
+	logger.Info("Holding back pod security level", "level", podSecurity.Enforce, "current", current,
+		"violations", len(violations))
+	for _, c := range profileIns.Status.Conditions {
+		if c.Type == profilev1.ProfilePodSecurityEnforced && c.Message == message {
+			return nil
+		}
+	}
+	IncRequestCounter("hold back pod security level")
+	r.Recorder.Event(profileIns, corev1.EventTypeWarning, reasonPodSecurityViolations, message)
+	return nil
+}
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// newTestPodSecurityServer returns the REST client of an API server whose
+// dry-runs of namespace updates warn about the pods returned by violations.
+func newTestPodSecurityServer(t *testing.T, violations func() []string) rest.Interface {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPut || req.URL.Query().Get("dryRun") != metav1.DryRunAll {
+			t.Errorf("Unexpected request %v %v", req.Method, req.URL)
+		}
+		if pods := violations(); len(pods) > 0 {
+			w.Header().Add("Warning", `299 - "existing pods in namespace \"kubeflow-user\" violate the new PodSecurity enforce level \"restricted:latest\""`)
+			for _, pod := range pods {
+				w.Header().Add("Warning", fmt.Sprintf("299 - %q", pod))
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.Copy(w, req.Body)
+	}))
+	t.Cleanup(server.Close)
+	client, err := rest.RESTClientFor(&rest.Config{
+		Host:    server.URL,
+		APIPath: "/api",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion:         &corev1.SchemeGroupVersion,
+			NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestGetProfilePodSecurity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pod-security.yaml")
+	if err := ioutil.WriteFile(path, []byte("enforce: baseline\nwarn: restricted\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defaultPodSecurity, err := readDefaultPodSecurityFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := &profilev1.Profile{Spec: profilev1.ProfileSpec{
+		PodSecurity: &profilev1.ProfilePodSecurity{Enforce: podSecurityRestricted, Version: "v1.24"},
+	}}
+	expected := map[string]string{
+		"pod-security.kubernetes.io/enforce":         "restricted",
+		"pod-security.kubernetes.io/enforce-version": "v1.24",
+		"pod-security.kubernetes.io/warn":            "restricted",
+		"pod-security.kubernetes.io/warn-version":    "v1.24",
+	}
+	if labels := getPodSecurityLabels(getProfilePodSecurity(profile, defaultPodSecurity)); !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Expected labels %v, got %v", expected, labels)
+	}
+	if podSecurity := getProfilePodSecurity(&profilev1.Profile{}, nil); podSecurity != nil {
+		t.Errorf("Expected no pod security without default, got %+v", podSecurity)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("enforce: strict\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readDefaultPodSecurityFromFile(path); err == nil {
+		t.Errorf("Expected an unknown level to be rejected")
+	}
+}
+
+func TestReconcilePodSecurity(t *testing.T) {
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"},
+		Spec: profilev1.ProfileSpec{
+			Owner:       rbacv1.Subject{Kind: rbacv1.UserKind, Name: "user@example.com"},
+			PodSecurity: &profilev1.ProfilePodSecurity{Enforce: podSecurityBaseline},
+		},
+	}
+	violations := []string{"notebook-0: allowPrivilegeEscalation != false"}
+	r := newTestReconciler(profile)
+	r.CoreRESTClient = newTestPodSecurityServer(t, func() []string { return violations })
+	r.DefaultNamespaceLabelsPath = newTestLabelsFile(t)
+	recorder := r.Recorder.(*record.FakeRecorder)
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: profile.Name}}
+	found := &profilev1.Profile{}
+	ns := &corev1.Namespace{}
+	reconcile := func(podSecurity *profilev1.ProfilePodSecurity) ctrl.Result {
+		if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+			t.Fatal(err)
+		}
+		found.Spec.PodSecurity = podSecurity
+		if err := r.Update(ctx, found); err != nil {
+			t.Fatal(err)
+		}
+		result, err := r.Reconcile(ctx, request)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Get(ctx, types.NamespacedName{Name: profile.Name}, ns); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	reconcile(&profilev1.ProfilePodSecurity{Enforce: podSecurityBaseline})
+	if level := ns.Labels["pod-security.kubernetes.io/enforce"]; level != podSecurityBaseline {
+		t.Errorf("Expected the baseline level to be enforced, got %v", ns.Labels)
+	}
+
+	// The pod holds back the restricted level.
+	result := reconcile(&profilev1.ProfilePodSecurity{Enforce: podSecurityRestricted, Warn: podSecurityRestricted})
+	if level := ns.Labels["pod-security.kubernetes.io/enforce"]; level != podSecurityBaseline {
+		t.Errorf("Expected the baseline level to stay enforced, got %v", ns.Labels)
+	}
+	condition := findCondition(found.Status.Conditions, profilev1.ProfilePodSecurityEnforced)
+	if condition.Status != "False" || !strings.Contains(condition.Message, violations[0]) ||
+		strings.Contains(condition.Message, "existing pods") {
+		t.Errorf("Expected the violations in the condition, got %+v", condition)
+	}
+	if result.RequeueAfter != podSecurityRecheckInterval {
+		t.Errorf("Expected a requeue after %v, got %+v", podSecurityRecheckInterval, result)
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, reasonPodSecurityViolations) {
+			t.Errorf("Unexpected event %v", event)
+		}
+	default:
+		t.Errorf("Expected the violations to be recorded")
+	}
+	if ready := findCondition(found.Status.Conditions, profilev1.ProfileReady); ready.Status != "True" {
+		t.Errorf("Expected the profile to stay ready, got %+v", ready)
+	}
+
+	// The restricted level is enforced once the pod is gone.
+	violations = nil
+	reconcile(&profilev1.ProfilePodSecurity{Enforce: podSecurityRestricted, Warn: podSecurityRestricted})
+	if level := ns.Labels["pod-security.kubernetes.io/enforce"]; level != podSecurityRestricted {
+		t.Errorf("Expected the restricted level to be enforced, got %v", ns.Labels)
+	}
+	condition = findCondition(found.Status.Conditions, profilev1.ProfilePodSecurityEnforced)
+	if condition.Status != "True" {
+		t.Errorf("Expected the level to be enforced, got %+v", condition)
+	}
+
+	// The labels are removed with the levels of the Profile.
+	ns.Labels["pod-security.kubernetes.io/audit"] = podSecurityBaseline
+	if err := r.Update(ctx, ns); err != nil {
+		t.Fatal(err)
+	}
+	reconcile(nil)
+	expected := map[string]string{"pod-security.kubernetes.io/audit": podSecurityBaseline}
+	for k, v := range ns.Labels {
+		if strings.HasPrefix(k, POD_SECURITY_LABEL_PREFIX) && expected[k] != v {
+			t.Errorf("Expected only the labels not set by the controller to be kept, got %v", ns.Labels)
+		}
+	}
+	if _, ok := ns.Annotations[POD_SECURITY_LABELS_ANNOTATION]; ok {
+		t.Errorf("Expected no applied pod security labels, got %v", ns.Annotations)
+	}
+	if condition := findCondition(found.Status.Conditions, profilev1.ProfilePodSecurityEnforced); condition.Type != "" {
+		t.Errorf("Expected no pod security condition, got %+v", condition)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/cenkalti/backoff"
@@ -34,12 +35,16 @@ import (
 	istioSecurity "istio.io/api/security/v1beta1"
 	istioSecurityClient "istio.io/client-go/pkg/apis/security/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -89,22 +94,56 @@ type ProfileReconciler struct {
 	Log                        logr.Logger
 	UserIdHeader               string
 	UserIdPrefix               string
+	GroupsHeader               string
 	WorkloadIdentity           string
 	DefaultNamespaceLabelsPath string
+	DefaultServiceAccountsPath string
+	DefaultLimitRangePath      string
+	NetworkPoliciesPath        string
+	DefaultPodSecurityPath     string
+	SeedResourcesPath          string
+	ExpirationWarning          time.Duration
+	ExpirationGracePeriod      time.Duration
+	StopNotebooksOnExpiration  bool
+	Recorder                   record.EventRecorder
+	// CoreRESTClient is the REST client of the core API group. Its server-side
+	// dry-runs of the Pod Security labels of the namespaces return the pods
+	// that violate them. The levels are set without checking the pods if nil.
+	CoreRESTClient rest.Interface
+	// NamespaceLabelsQPS and NamespaceLabelsBurst limit the rate the
+	// namespaces are updated at when the labels file changes.
+	NamespaceLabelsQPS   float64
+	NamespaceLabelsBurst int
+	// PluginDriftInterval is how often the plugins are verified, they are
+	// only applied when their spec changes if zero.
+	PluginDriftInterval time.Duration
+	// CopySourceNamespaces are the namespaces whose sources of the copied
+	// objects are watched. They are only cached in these namespaces.
+	CopySourceNamespaces []string
+
+	seedResources seedResourcesCache
 }
 
 // +kubebuilder:rbac:groups=core,resources=namespaces,verbs="*"
 // +kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs="*"
+// +kubebuilder:rbac:groups=core,resources=resourcequotas;limitranges,verbs="*"
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs="*"
 // +kubebuilder:rbac:groups=security.istio.io,resources=authorizationpolicies,verbs="*"
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs="*"
 // +kubebuilder:rbac:groups=kubeflow.org,resources=profiles;profiles/status;profiles/finalizers,verbs="*"
+// +kubebuilder:rbac:groups=kubeflow.org,resources=profileplugins;profiletemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kubeflow.org,resources=poddefaults,verbs=get;list;create;update;delete
+// +kubebuilder:rbac:groups=core,resources=configmaps;secrets,verbs=get;list;create;update;delete
+// +kubebuilder:rbac:groups=kubeflow.org,namespace=kubeflow,resources=poddefaults,verbs=list;watch
+// +kubebuilder:rbac:groups=core,namespace=kubeflow,resources=configmaps;secrets,verbs=list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=kubeflow.org,resources=notebooks,verbs=get;list;watch;patch
 
 // Reconcile reads that state of the cluster for a Profile object and makes changes based on the state read
 // and what is in the Profile.Spec
 // Automatically generate RBAC rules to allow the Controller to read and write Deployments
 func (r *ProfileReconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
 	logger := r.Log.WithValues("profile", request.NamespacedName)
-	defaultKubeflowNamespaceLabels := r.readDefaultLabelsFromFile(r.DefaultNamespaceLabelsPath)
 
 	// Fetch the Profile instance
 	instance := &profilev1.Profile{}
@@ -123,10 +162,65 @@ func (r *ProfileReconciler) Reconcile(ctx context.Context, request ctrl.Request)
 		return reconcile.Result{}, err
 	}
 
+	// Every step records its condition, they are written once the reconciliation is done.
+	conditions := newProfileConditions(instance)
+	// Expired Profiles are deleted, their next reconciliation runs the finalizer.
+	expiresIn, deleted, err := r.reconcileExpiration(ctx, instance, conditions, time.Now())
+	if deleted {
+		return reconcile.Result{}, nil
+	}
+	result := ctrl.Result{}
+	if err == nil {
+		result, err = r.reconcileProfile(ctx, instance, conditions)
+		// Reconcile again at the next step of the expiration.
+		if err == nil && expiresIn > 0 && (result.RequeueAfter == 0 || expiresIn < result.RequeueAfter) {
+			result.RequeueAfter = expiresIn
+		}
+		// Check again whether pods still hold back the enforced pod security level.
+		if err == nil && conditions.podSecurityHeldBack() &&
+			(result.RequeueAfter == 0 || result.RequeueAfter > podSecurityRecheckInterval) {
+			result.RequeueAfter = podSecurityRecheckInterval
+		}
+	}
+	if err2 := r.updateProfileConditions(ctx, instance, conditions); err2 != nil {
+		logger.Error(err2, "error updating profile conditions")
+		IncRequestErrorCounter("error updating profile conditions", SEVERITY_MAJOR)
+		if err == nil {
+			return reconcile.Result{}, err2
+		}
+	}
+	return result, err
+}
+
+// reconcileProfile creates the namespace of the Profile and its resources, and records the result of every step in
+// conditions.
+func (r *ProfileReconciler) reconcileProfile(ctx context.Context, instance *profilev1.Profile,
+	conditions *profileConditions) (ctrl.Result, error) {
+	logger := r.Log.WithValues("profile", instance.Name)
+
+	// Resolve the ProfileTemplate, whose fields apply where the Profile doesn't set them.
+	template, err := r.getProfileTemplate(ctx, instance)
+	if err != nil {
+		logger.Error(err, "error getting ProfileTemplate", "template", instance.Spec.Template)
+		IncRequestErrorCounter("error getting profile template", SEVERITY_MAJOR)
+		if apierrors.IsNotFound(err) {
+			// The Profile is reconciled again once the template is created.
+			conditions.setFalse(profilev1.ProfileTemplateReady, reasonTemplateNotFound, err.Error())
+			return reconcile.Result{}, nil
+		}
+		conditions.setFalse(profilev1.ProfileTemplateReady, reasonTemplateFailed, err.Error())
+		return reconcile.Result{}, err
+	}
+	conditions.setTrue(profilev1.ProfileTemplateReady)
+	profile := applyProfileTemplate(instance, template)
+
+	defaultKubeflowNamespaceLabels := getTemplateNamespaceLabels(
+		r.readDefaultLabelsFromFile(r.DefaultNamespaceLabelsPath), template)
+
 	// Update namespace
 	ns := &corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
-			Annotations: map[string]string{"owner": instance.Spec.Owner.Name},
+			Annotations: map[string]string{"owner": getPrimaryOwner(instance)},
 			// inject istio sidecar to all pods in target namespace by default.
 			Labels: map[string]string{
 				istioInjectionLabel: "enabled",
@@ -139,17 +233,26 @@ func (r *ProfileReconciler) Reconcile(ctx context.Context, request ctrl.Request)
 	if err := controllerutil.SetControllerReference(instance, ns, r.Scheme); err != nil {
 		IncRequestErrorCounter("error setting ControllerReference", SEVERITY_MAJOR)
 		logger.Error(err, "error setting ControllerReference")
+		conditions.setFalse(profilev1.ProfileNamespaceReady, reasonNamespaceFailed, err.Error())
 		return reconcile.Result{}, err
 	}
 	foundNs := &corev1.Namespace{}
 	err = r.Get(ctx, types.NamespacedName{Name: ns.Name}, foundNs)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
+			r.reconcileNamespaceMetadata(instance, ns, defaultKubeflowNamespaceLabels)
+			if err = r.reconcilePodSecurity(ctx, instance, ns, conditions); err != nil {
+				IncRequestErrorCounter("error reconciling pod security", SEVERITY_MAJOR)
+				logger.Error(err, "error reconciling pod security")
+				conditions.setFalse(profilev1.ProfileNamespaceReady, reasonNamespaceFailed, err.Error())
+				return reconcile.Result{}, err
+			}
 			logger.Info("Creating Namespace: " + ns.Name)
 			err = r.Create(ctx, ns)
 			if err != nil {
 				IncRequestErrorCounter("error creating namespace", SEVERITY_MAJOR)
 				logger.Error(err, "error creating namespace")
+				conditions.setFalse(profilev1.ProfileNamespaceReady, reasonNamespaceFailed, err.Error())
 				return reconcile.Result{}, err
 			}
 			// wait 15 seconds for new namespace creation.
@@ -161,65 +264,124 @@ func (r *ProfileReconciler) Reconcile(ctx context.Context, request ctrl.Request)
 			if err != nil {
 				IncRequestErrorCounter("error namespace create completion", SEVERITY_MAJOR)
 				logger.Error(err, "error namespace create completion")
-				return r.appendErrorConditionAndReturn(ctx, instance,
+				conditions.setFalse(profilev1.ProfileNamespaceReady, reasonNamespaceTimeout,
 					"Owning namespace failed to create within 15 seconds")
+				return reconcile.Result{}, nil
 			}
 			logger.Info("Created Namespace: "+foundNs.Name, "status", foundNs.Status.Phase)
 		} else {
 			IncRequestErrorCounter("error reading namespace", SEVERITY_MAJOR)
 			logger.Error(err, "error reading namespace")
+			conditions.setFalse(profilev1.ProfileNamespaceReady, reasonNamespaceFailed, err.Error())
 			return reconcile.Result{}, err
 		}
 	} else {
-		// Check exising namespace ownership before move forward
+		// Check exising namespace ownership before move forward. The namespace stays owned when
+		// the owner it was created for is replaced.
 		owner, ok := foundNs.Annotations["owner"]
-		if ok && owner == instance.Spec.Owner.Name {
+		if metav1.IsControlledBy(foundNs, instance) || (ok && isProfileOwner(instance, owner)) {
 			oldLabels := map[string]string{}
 			for k, v := range foundNs.Labels {
 				oldLabels[k] = v
 			}
+			oldAnnotations := map[string]string{}
+			for k, v := range foundNs.Annotations {
+				oldAnnotations[k] = v
+			}
 			setNamespaceLabels(foundNs, defaultKubeflowNamespaceLabels)
 			logger.Info("List of labels to be added to found namespace", "labels", ns.Labels)
-			if !reflect.DeepEqual(oldLabels, foundNs.Labels) {
+			r.reconcileNamespaceMetadata(instance, foundNs, defaultKubeflowNamespaceLabels)
+			if err = r.reconcilePodSecurity(ctx, instance, foundNs, conditions); err != nil {
+				IncRequestErrorCounter("error reconciling pod security", SEVERITY_MAJOR)
+				logger.Error(err, "error reconciling pod security")
+				conditions.setFalse(profilev1.ProfileNamespaceReady, reasonNamespaceFailed, err.Error())
+				return reconcile.Result{}, err
+			}
+			// A transfer is recorded once the RoleBinding and the AuthorizationPolicy of the owner follow.
+			setOwnerAnnotation(instance, foundNs)
+			if !reflect.DeepEqual(oldLabels, foundNs.Labels) || !reflect.DeepEqual(oldAnnotations, foundNs.Annotations) {
 				err = r.Update(ctx, foundNs)
 				if err != nil {
 					IncRequestErrorCounter("error updating namespace label", SEVERITY_MAJOR)
 					logger.Error(err, "error updating namespace label")
+					conditions.setFalse(profilev1.ProfileNamespaceReady, reasonNamespaceFailed, err.Error())
 					return reconcile.Result{}, err
 				}
 			}
+		} else if canAdoptNamespace(instance, foundNs) {
+			// Adopt the namespace only if the Profile takes over no resource it doesn't own.
+			conflicts, err := r.getAdoptionConflicts(ctx, profile, foundNs)
+			if err != nil {
+				IncRequestErrorCounter("error checking namespace adoption", SEVERITY_MAJOR)
+				logger.Error(err, "error checking namespace adoption")
+				conditions.setFalse(profilev1.ProfileNamespaceReady, reasonNamespaceFailed, err.Error())
+				return reconcile.Result{}, err
+			}
+			conditions.setAdoptionConflicts(conflicts)
+			if len(conflicts) > 0 {
+				logger.Info("namespace can't be adopted", "conflicts", conflicts)
+				IncRequestCounter("reject profile adopting namespace with conflicts")
+				conditions.setFalse(profilev1.ProfileNamespaceReady, reasonNamespaceAdoptionConflict,
+					formatAdoptionConflicts(conflicts))
+				return reconcile.Result{}, nil
+			}
+			r.reconcileNamespaceMetadata(instance, foundNs, defaultKubeflowNamespaceLabels)
+			if err = r.reconcilePodSecurity(ctx, instance, foundNs, conditions); err != nil {
+				IncRequestErrorCounter("error reconciling pod security", SEVERITY_MAJOR)
+				logger.Error(err, "error reconciling pod security")
+				conditions.setFalse(profilev1.ProfileNamespaceReady, reasonNamespaceFailed, err.Error())
+				return reconcile.Result{}, err
+			}
+			if err = r.adoptNamespace(ctx, instance, foundNs, defaultKubeflowNamespaceLabels); err != nil {
+				IncRequestErrorCounter("error adopting namespace", SEVERITY_MAJOR)
+				logger.Error(err, "error adopting namespace")
+				conditions.setFalse(profilev1.ProfileNamespaceReady, reasonNamespaceFailed, err.Error())
+				return reconcile.Result{}, err
+			}
+			IncRequestCounter("adopt existing namespace")
 		} else {
 			logger.Info(fmt.Sprintf("namespace already exist, but not owned by profile creator %v",
-				instance.Spec.Owner.Name))
+				getPrimaryOwner(instance)))
 			IncRequestCounter("reject profile taking over existing namespace")
-			return r.appendErrorConditionAndReturn(ctx, instance, fmt.Sprintf(
-				"namespace already exist, but not owned by profile creator %v", instance.Spec.Owner.Name))
+			message := fmt.Sprintf("namespace already exist, but not owned by profile creator %v",
+				getPrimaryOwner(instance))
+			if instance.Spec.AdoptNamespace {
+				message += fmt.Sprintf(", annotate it with %v: %q to adopt it", NAMESPACE_ADOPT_ANNOTATION,
+					getPrimaryOwner(instance))
+			}
+			conditions.setFalse(profilev1.ProfileNamespaceReady, reasonNamespaceNotOwned, message)
+			return reconcile.Result{}, nil
 		}
 	}
+	conditions.setTrue(profilev1.ProfileNamespaceReady)
 
 	// Update Istio AuthorizationPolicy
 	// Create Istio AuthorizationPolicy in target namespace, which will give ns owner permission to access services in ns.
 	if err = r.updateIstioAuthorizationPolicy(instance); err != nil {
 		logger.Error(err, "error Updating Istio AuthorizationPolicy permission", "namespace", instance.Name)
 		IncRequestErrorCounter("error updating Istio AuthorizationPolicy permission", SEVERITY_MAJOR)
+		conditions.setFalse(profilev1.ProfileAuthorizationPolicyReady, reasonAuthorizationPolicyFailed, err.Error())
 		return reconcile.Result{}, err
 	}
+	conditions.setTrue(profilev1.ProfileAuthorizationPolicyReady)
 
-	// Update service accounts
-	// Create service account "default-editor" in target namespace.
-	// "default-editor" would have kubeflowEdit permission: edit all resources in target namespace except rbac.
-	if err = r.updateServiceAccount(instance, DEFAULT_EDITOR, kubeflowEdit); err != nil {
-		logger.Error(err, "error Updating ServiceAccount", "namespace", instance.Name, "name",
-			"defaultEditor")
-		IncRequestErrorCounter("error updating ServiceAccount", SEVERITY_MAJOR)
+	// Update NetworkPolicies
+	// Isolate target namespace from the other profiles for the traffic that AuthorizationPolicies don't cover.
+	if err = r.reconcileNetworkPolicies(ctx, instance); err != nil {
+		logger.Error(err, "error Updating NetworkPolicies", "namespace", instance.Name)
+		IncRequestErrorCounter("error updating NetworkPolicies", SEVERITY_MAJOR)
+		conditions.setFalse(profilev1.ProfileNetworkPolicyReady, reasonNetworkPolicyFailed, err.Error())
 		return reconcile.Result{}, err
 	}
-	// Create service account "default-viewer" in target namespace.
-	// "default-viewer" would have k8s default "view" permission: view all resources in target namespace.
-	if err = r.updateServiceAccount(instance, DEFAULT_VIEWER, kubeflowView); err != nil {
-		logger.Error(err, "error Updating ServiceAccount", "namespace", instance.Name, "name",
-			"defaultViewer")
+	conditions.setTrue(profilev1.ProfileNetworkPolicyReady)
+
+	// Update service accounts
+	// Create the service accounts of the profile and of the cluster default in target namespace, by default
+	// "default-editor" with kubeflowEdit and "default-viewer" with kubeflowView.
+	if err = r.reconcileServiceAccounts(ctx, profile); err != nil {
+		logger.Error(err, "error Updating ServiceAccounts", "namespace", instance.Name)
 		IncRequestErrorCounter("error updating ServiceAccount", SEVERITY_MAJOR)
+		conditions.setFalse(profilev1.ProfileRBACReady, reasonServiceAccountFailed, err.Error())
 		return reconcile.Result{}, err
 	}
 
@@ -229,7 +391,7 @@ func (r *ProfileReconciler) Reconcile(ctx context.Context, request ctrl.Request)
 	// When ClusterRole was referred by namespaced roleBinding, the result permission will be namespaced as well.
 	roleBinding := &rbacv1.RoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
-			Annotations: map[string]string{USER: instance.Spec.Owner.Name, ROLE: ADMIN},
+			Annotations: map[string]string{USER: getPrimaryOwner(instance), ROLE: ADMIN},
 			Name:        "namespaceAdmin",
 			Namespace:   instance.Name,
 		},
@@ -239,28 +401,50 @@ func (r *ProfileReconciler) Reconcile(ctx context.Context, request ctrl.Request)
 			Kind:     "ClusterRole",
 			Name:     kubeflowAdmin,
 		},
-		Subjects: []rbacv1.Subject{
-			instance.Spec.Owner,
-		},
+		// All the owners, users and groups, are bound to the role.
+		Subjects: getProfileOwners(instance),
 	}
 	if err = r.updateRoleBinding(instance, roleBinding); err != nil {
 		logger.Error(err, "error Updating Owner Rolebinding", "namespace", instance.Name, "name",
 			"defaultEdittor")
 		IncRequestErrorCounter("error updating Owner Rolebinding", SEVERITY_MAJOR)
+		conditions.setFalse(profilev1.ProfileRBACReady, reasonRoleBindingFailed, err.Error())
 		return reconcile.Result{}, err
 	}
+	// Update contributor rbac and istio permissions
+	if err = r.reconcileContributors(ctx, instance); err != nil {
+		logger.Error(err, "error reconciling contributors", "namespace", instance.Name)
+		IncRequestErrorCounter("error reconciling contributors", SEVERITY_MAJOR)
+		conditions.setFalse(profilev1.ProfileRBACReady, reasonContributorsFailed, err.Error())
+		return reconcile.Result{}, err
+	}
+	conditions.setTrue(profilev1.ProfileRBACReady)
+	if transferredFrom, ok := foundNs.Annotations[OWNER_TRANSFER_ANNOTATION]; ok {
+		original := foundNs.DeepCopy()
+		delete(foundNs.Annotations, OWNER_TRANSFER_ANNOTATION)
+		if err = r.Patch(ctx, foundNs, client.MergeFrom(original)); err != nil {
+			logger.Error(err, "error completing profile ownership transfer", "namespace", instance.Name)
+			IncRequestErrorCounter("error completing profile ownership transfer", SEVERITY_MAJOR)
+			return reconcile.Result{}, err
+		}
+		logger.Info("Transferred profile ownership", "from", transferredFrom, "to", getPrimaryOwner(instance))
+		IncRequestCounter("transfer profile ownership")
+		r.Recorder.Eventf(instance, corev1.EventTypeNormal, eventOwnerTransferred,
+			"Ownership transferred from %v to %v", transferredFrom, getPrimaryOwner(instance))
+	}
 	// Create resource quota for target namespace if resources are specified in profile.
-	if len(instance.Spec.ResourceQuotaSpec.Hard) > 0 {
+	if len(profile.Spec.ResourceQuotaSpec.Hard) > 0 {
 		resourceQuota := &corev1.ResourceQuota{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      KFQUOTA,
 				Namespace: instance.Name,
 			},
-			Spec: instance.Spec.ResourceQuotaSpec,
+			Spec: profile.Spec.ResourceQuotaSpec,
 		}
 		if err = r.updateResourceQuota(instance, resourceQuota); err != nil {
 			logger.Error(err, "error Updating resource quota", "namespace", instance.Name)
 			IncRequestErrorCounter("error updating resource quota", SEVERITY_MAJOR)
+			conditions.setFalse(profilev1.ProfileQuotaReady, reasonResourceQuotaFailed, err.Error())
 			return reconcile.Result{}, err
 		}
 	} else {
@@ -269,13 +453,49 @@ func (r *ProfileReconciler) Reconcile(ctx context.Context, request ctrl.Request)
 		if err == nil {
 			if err := r.Delete(ctx, found); err != nil {
 				logger.Error(err, "error deleting resource quota", "namespace", instance.Name)
+				conditions.setFalse(profilev1.ProfileQuotaReady, reasonResourceQuotaFailed, err.Error())
 				return ctrl.Result{}, err
 			}
 		} else if !apierrors.IsNotFound(err) {
 			logger.Error(err, "error getting resource quota", "namespace", instance.Name)
+			conditions.setFalse(profilev1.ProfileQuotaReady, reasonResourceQuotaFailed, err.Error())
 			return ctrl.Result{}, err
 		} else {
-			logger.Info("No update on resource quota", "spec", instance.Spec.ResourceQuotaSpec.String())
+			logger.Info("No update on resource quota", "spec", profile.Spec.ResourceQuotaSpec.String())
+		}
+	}
+	// Create the limit range of the profile, or the cluster default, so that pods get default resources.
+	if err = r.reconcileLimitRange(ctx, profile); err != nil {
+		logger.Error(err, "error reconciling limit range", "namespace", instance.Name)
+		IncRequestErrorCounter("error reconciling limit range", SEVERITY_MAJOR)
+		conditions.setFalse(profilev1.ProfileQuotaReady, reasonLimitRangeFailed, err.Error())
+		return reconcile.Result{}, err
+	}
+	// Mirror the usage of the resource quota, updated by the quota controller, into the status.
+	quota, err := r.readQuotaStatus(ctx, instance)
+	if err != nil {
+		logger.Error(err, "error reading resource quota status", "namespace", instance.Name)
+		IncRequestErrorCounter("error reading resource quota status", SEVERITY_MAJOR)
+		conditions.setFalse(profilev1.ProfileQuotaReady, reasonResourceQuotaFailed, err.Error())
+		return reconcile.Result{}, err
+	}
+	conditions.setQuota(quota)
+	conditions.setTrue(profilev1.ProfileQuotaReady)
+	// Copy the PodDefaults, ConfigMaps and Secrets of the ProfileTemplate, and the seed resources. They are
+	// deleted with the namespace of a deleted Profile. The objects that can't be copied are reported, and don't
+	// block the rest of the reconciliation.
+	if instance.DeletionTimestamp.IsZero() {
+		problems, err := r.reconcileProfileTemplateResources(ctx, profile, template)
+		if err != nil {
+			logger.Error(err, "error reconciling profile template resources", "namespace", instance.Name)
+			IncRequestErrorCounter("error reconciling profile template resources", SEVERITY_MAJOR)
+			conditions.setFalse(profilev1.ProfileTemplateReady, reasonTemplateResourcesFailed, err.Error())
+			return reconcile.Result{}, err
+		}
+		if len(problems) > 0 {
+			logger.Info("Skipped profile template resources", "namespace", instance.Name, "problems", problems)
+			conditions.setFalse(profilev1.ProfileTemplateReady, reasonTemplateResourcesFailed,
+				strings.Join(problems, "; "))
 		}
 	}
 	if err := r.PatchDefaultPluginSpec(ctx, instance); err != nil {
@@ -283,15 +503,30 @@ func (r *ProfileReconciler) Reconcile(ctx context.Context, request ctrl.Request)
 		logger.Error(err, "Failed patching DefaultPluginSpec", "namespace", instance.Name)
 		return reconcile.Result{}, err
 	}
-	if plugins, err := r.GetPluginSpec(instance); err == nil {
-		for _, plugin := range plugins {
-			if err2 := plugin.ApplyPlugin(r, instance); err2 != nil {
-				logger.Error(err2, "Failed applying plugin", "namespace", instance.Name)
-				IncRequestErrorCounter("error applying plugin", SEVERITY_MAJOR)
-				return reconcile.Result{}, err2
-			}
-		}
+	// The plugins of the template apply to the ones the Profile, patched with the default ones, doesn't have.
+	profile = applyProfileTemplate(instance, template)
+	if !instance.DeletionTimestamp.IsZero() {
+		profile = applyRecordedPlugins(profile)
 	}
+	specs, plugins, unresolved, pluginErrors := r.resolvePlugins(profile)
+	if len(unresolved) > 0 {
+		logger.Info("Failed loading plugins", "namespace", instance.Name, "errors", pluginErrors)
+		IncRequestErrorCounter("error loading plugins", SEVERITY_MAJOR)
+		conditions.setFalse(profilev1.ProfilePluginsReady, reasonPluginsInvalid, strings.Join(pluginErrors, "; "))
+	} else {
+		conditions.setTrue(profilev1.ProfilePluginsReady)
+	}
+	// The plugins are only applied again when their spec changed, or when they are verified for drift. The ones
+	// that can't be resolved keep their previous status.
+	statuses, nextVerification, err := r.applyPlugins(profile, specs, plugins, foundNs.UID, time.Now())
+	conditions.setPlugins(appendPreviousPluginStatuses(statuses, instance.Status.Plugins, unresolved))
+	if err != nil {
+		logger.Error(err, "Failed applying plugin", "namespace", instance.Name)
+		IncRequestErrorCounter("error applying plugin", SEVERITY_MAJOR)
+		conditions.setFalse(profilev1.ProfilePluginsReady, reasonPluginApplyFailed, err.Error())
+		return reconcile.Result{}, err
+	}
+	conditions.setTemplate(template)
 
 	// examine DeletionTimestamp to determine if object is under deletion
 	if instance.ObjectMeta.DeletionTimestamp.IsZero() {
@@ -310,15 +545,21 @@ func (r *ProfileReconciler) Reconcile(ctx context.Context, request ctrl.Request)
 		// The object is being deleted
 		if containsString(instance.ObjectMeta.Finalizers, PROFILEFINALIZER) {
 			// our finalizer is present, so lets revoke all Plugins to clean up any external dependencies
-			if plugins, err := r.GetPluginSpec(instance); err == nil {
-				for _, plugin := range plugins {
-					if err := plugin.RevokePlugin(r, instance); err != nil {
-						logger.Error(err, "error revoking plugin", "namespace", instance.Name)
-						IncRequestErrorCounter("error revoking plugin", SEVERITY_MAJOR)
-						return reconcile.Result{}, err
-					}
+			for _, plugin := range plugins {
+				if err := plugin.RevokePlugin(r, profile); err != nil {
+					logger.Error(err, "error revoking plugin", "namespace", instance.Name)
+					IncRequestErrorCounter("error revoking plugin", SEVERITY_MAJOR)
+					conditions.setFalse(profilev1.ProfilePluginsReady, reasonPluginRevokeFailed, err.Error())
+					return reconcile.Result{}, err
 				}
 			}
+			// The plugins that can't be resolved can't be revoked either, so keep the finalizer until they can.
+			if len(unresolved) > 0 {
+				logger.Info("Keeping the finalizer until the plugins can be revoked", "namespace", instance.Name)
+				conditions.setFalse(profilev1.ProfilePluginsReady, reasonPluginsInvalid,
+					"can't revoke the plugins, keeping the finalizer: "+strings.Join(pluginErrors, "; "))
+				return reconcile.Result{}, nil
+			}
 
 			// remove our finalizer from the list and update it.
 			instance.ObjectMeta.Finalizers = removeString(instance.ObjectMeta.Finalizers, PROFILEFINALIZER)
@@ -330,20 +571,7 @@ func (r *ProfileReconciler) Reconcile(ctx context.Context, request ctrl.Request)
 		}
 	}
 	IncRequestCounter("reconcile")
-	return ctrl.Result{}, nil
-}
-
-// appendErrorConditionAndReturn append failure status to profile CR and mark Reconcile done. If update condition failed, request will be requeued.
-func (r *ProfileReconciler) appendErrorConditionAndReturn(ctx context.Context, instance *profilev1.Profile,
-	message string) (ctrl.Result, error) {
-	instance.Status.Conditions = append(instance.Status.Conditions, profilev1.ProfileCondition{
-		Type:    profilev1.ProfileFailed,
-		Message: message,
-	})
-	if err := r.Update(ctx, instance); err != nil {
-		return reconcile.Result{}, err
-	}
-	return reconcile.Result{}, nil
+	return ctrl.Result{RequeueAfter: nextVerification}, nil
 }
 
 // mapEventToRequest maps an event to reconcile requests for all Profiles
@@ -366,17 +594,86 @@ func (r *ProfileReconciler) mapEventToRequest(o client.Object) []reconcile.Reque
 }
 
 func (r *ProfileReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	// Watch config file with namespace labels. If the file changes, trigger
-	// a reconciliation for all Profiles.
+	// Watch config file with namespace labels. If the file changes, update
+	// the labels of the namespaces of all Profiles, without reconciling them.
+	qps, burst := r.NamespaceLabelsQPS, r.NamespaceLabelsBurst
+	if qps <= 0 {
+		qps = 10
+	}
+	if burst <= 0 {
+		burst = 100
+	}
+	syncer := newNamespaceLabelsSyncer(r, qps, burst)
+	if err := mgr.Add(syncer); err != nil {
+		return err
+	}
+	if err := r.watchConfigFile(r.DefaultNamespaceLabelsPath, syncer.fileChanged); err != nil {
+		return err
+	}
+
+	// Watch the config files of the NetworkPolicies, of the LimitRange and of
+	// the seed resources. If they change, reconcile all Profiles.
+	configChanged := make(chan event.GenericEvent)
+	for _, path := range []string{r.NetworkPoliciesPath, r.DefaultLimitRangePath, r.SeedResourcesPath} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			r.Log.Info("Config file doesn't exist, its changes aren't watched", "path", path)
+			continue
+		}
+		if err := r.watchConfigFile(path, func() {
+			configChanged <- event.GenericEvent{Object: &profilev1.Profile{}}
+		}); err != nil {
+			return err
+		}
+	}
+
+	c := ctrl.NewControllerManagedBy(mgr).
+		For(&profilev1.Profile{}).
+		Owns(&corev1.Namespace{}).
+		Owns(&istioSecurityClient.AuthorizationPolicy{}).
+		Owns(&corev1.ServiceAccount{}).
+		Owns(&rbacv1.RoleBinding{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Owns(&corev1.LimitRange{}).
+		// The usage of the quota is mirrored by the quotaStatusReconciler.
+		Owns(&corev1.ResourceQuota{}, builder.WithPredicates(quotaSpecChanged)).
+		Watches(
+			&source.Kind{Type: &profilev1.ProfilePlugin{}},
+			handler.EnqueueRequestsFromMapFunc(r.mapEventToRequest),
+		).
+		Watches(
+			&source.Kind{Type: &profilev1.ProfileTemplate{}},
+			handler.EnqueueRequestsFromMapFunc(r.mapProfileTemplateToRequests),
+		).
+		Watches(
+			&source.Channel{Source: configChanged},
+			handler.EnqueueRequestsFromMapFunc(r.mapEventToRequest),
+		)
+	if err := r.watchCopySources(mgr, c); err != nil {
+		return err
+	}
+
+	err := c.Complete(r)
+	if err != nil {
+		return err
+	}
+	return r.setupQuotaStatusWithManager(mgr)
+}
+
+// watchConfigFile calls changed whenever the config file is written or
+// replaced.
+func (r *ProfileReconciler) watchConfigFile(path string, changed func()) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return errors.Wrap(err, "Failed to start file watcher")
 	}
-	if err := watcher.Add(r.DefaultNamespaceLabelsPath); err != nil {
-		return errors.Wrapf(err, "Failed to watch file %s", r.DefaultNamespaceLabelsPath)
+	if err := watcher.Add(path); err != nil {
+		watcher.Close()
+		return errors.Wrapf(err, "Failed to watch file %s", path)
 	}
-	events := make(chan event.GenericEvent)
-	go func(watcher *fsnotify.Watcher, reconcileEvents chan event.GenericEvent) {
+	go func() {
 		defer watcher.Close()
 		for {
 			select {
@@ -388,31 +685,14 @@ func (r *ProfileReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				// https://martensson.io/go-fsnotify-and-kubernetes-configmaps/
 				if fsEvent.Op == fsnotify.Remove {
 					watcher.Remove(fsEvent.Name)
-					watcher.Add(r.DefaultNamespaceLabelsPath)
+					watcher.Add(path)
 				}
-				reconcileEvents <- event.GenericEvent{}
+				changed()
 			case err := <-watcher.Errors:
-				r.Log.Error(err, "Error while watching config file", "path",
-					r.DefaultNamespaceLabelsPath)
+				r.Log.Error(err, "Error while watching config file", "path", path)
 			}
 		}
-	}(watcher, events)
-
-	c := ctrl.NewControllerManagedBy(mgr).
-		For(&profilev1.Profile{}).
-		Owns(&corev1.Namespace{}).
-		Owns(&istioSecurityClient.AuthorizationPolicy{}).
-		Owns(&corev1.ServiceAccount{}).
-		Owns(&rbacv1.RoleBinding{}).
-		Watches(
-			&source.Channel{Source: events},
-			handler.EnqueueRequestsFromMapFunc(r.mapEventToRequest),
-		)
-
-	err = c.Complete(r)
-	if err != nil {
-		return err
-	}
+	}()
 	return nil
 }
 
@@ -429,31 +709,52 @@ func (r *ProfileReconciler) getAuthorizationPolicy(profileIns *profilev1.Profile
 		"KFP_UI_PRINCIPAL",
 		"cluster.local/ns/kubeflow/sa/ml-pipeline-ui")
 
+	ownerValues := []string{}
+	for _, name := range getOwnerNames(profileIns, rbacv1.UserKind) {
+		ownerValues = append(ownerValues, r.UserIdPrefix+name)
+	}
+
+	rules := []*istioSecurity.Rule{}
+	if len(ownerValues) > 0 {
+		rules = append(rules, &istioSecurity.Rule{
+			When: []*istioSecurity.Condition{
+				{
+					// Namespace Owners can access all workloads in the
+					// namespace
+					Key:    fmt.Sprintf("request.headers[%v]", r.UserIdHeader),
+					Values: ownerValues,
+				},
+			},
+			From: []*istioSecurity.Rule_From{{
+				Source: &istioSecurity.Source{
+					Principals: []string{
+						istioIGWPrincipal,
+						kfpUIPrincipal,
+					},
+				},
+			}},
+		})
+	}
+	// So can the members of the owner groups, when the requests carry their groups
+	if groups := r.getGroupsCondition(getOwnerNames(profileIns, rbacv1.GroupKind)); groups != nil {
+		rules = append(rules, &istioSecurity.Rule{
+			When: []*istioSecurity.Condition{groups},
+			From: []*istioSecurity.Rule_From{{
+				Source: &istioSecurity.Source{
+					Principals: []string{
+						istioIGWPrincipal,
+						kfpUIPrincipal,
+					},
+				},
+			}},
+		})
+	}
+
 	return istioSecurity.AuthorizationPolicy{
 		Action: istioSecurity.AuthorizationPolicy_ALLOW,
 		// Empty selector == match all workloads in namespace
 		Selector: nil,
-		Rules: []*istioSecurity.Rule{
-			{
-				When: []*istioSecurity.Condition{
-					{
-						// Namespace Owner can access all workloads in the
-						// namespace
-						Key: fmt.Sprintf("request.headers[%v]", r.UserIdHeader),
-						Values: []string{
-							r.UserIdPrefix + profileIns.Spec.Owner.Name,
-						},
-					},
-				},
-				From: []*istioSecurity.Rule_From{{
-					Source: &istioSecurity.Source{
-						Principals: []string{
-							istioIGWPrincipal,
-							kfpUIPrincipal,
-						},
-					},
-				}},
-			},
+		Rules: append(rules, []*istioSecurity.Rule{
 			{
 				When: []*istioSecurity.Condition{
 					{
@@ -499,7 +800,7 @@ func (r *ProfileReconciler) getAuthorizationPolicy(profileIns *profilev1.Profile
 					},
 				},
 			},
-		},
+		}...),
 	}
 }
 
@@ -507,16 +808,22 @@ func (r *ProfileReconciler) getAuthorizationPolicy(profileIns *profilev1.Profile
 // resources in target namespace owned by "profileIns". The goal is to allow
 // service access for profile owner.
 func (r *ProfileReconciler) updateIstioAuthorizationPolicy(profileIns *profilev1.Profile) error {
-	logger := r.Log.WithValues("profile", profileIns.Name)
-
 	istioAuth := &istioSecurityClient.AuthorizationPolicy{
 		ObjectMeta: metav1.ObjectMeta{
-			Annotations: map[string]string{USER: profileIns.Spec.Owner.Name, ROLE: ADMIN},
+			Annotations: map[string]string{USER: getPrimaryOwner(profileIns), ROLE: ADMIN},
 			Name:        AUTHZPOLICYISTIO,
 			Namespace:   profileIns.Name,
 		},
 		Spec: r.getAuthorizationPolicy(profileIns),
 	}
+	return r.updateAuthorizationPolicy(profileIns, istioAuth)
+}
+
+// updateAuthorizationPolicy create or update AuthorizationPolicy "istioAuth"
+// in target namespace owned by "profileIns"
+func (r *ProfileReconciler) updateAuthorizationPolicy(profileIns *profilev1.Profile,
+	istioAuth *istioSecurityClient.AuthorizationPolicy) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
 
 	if err := controllerutil.SetControllerReference(profileIns, istioAuth, r.Scheme); err != nil {
 		return err
@@ -542,7 +849,10 @@ func (r *ProfileReconciler) updateIstioAuthorizationPolicy(profileIns *profilev1
 			return err
 		}
 	} else {
-		if !reflect.DeepEqual(*istioAuth.Spec.DeepCopy(), *foundAuthorizationPolicy.Spec.DeepCopy()) {
+		labelsChanged := mergeStringMap(&foundAuthorizationPolicy.Labels, istioAuth.Labels)
+		annotationsChanged := mergeStringMap(&foundAuthorizationPolicy.Annotations, istioAuth.Annotations)
+		if labelsChanged || annotationsChanged ||
+			!reflect.DeepEqual(*istioAuth.Spec.DeepCopy(), *foundAuthorizationPolicy.Spec.DeepCopy()) {
 			foundAuthorizationPolicy.Spec = *istioAuth.Spec.DeepCopy()
 			logger.Info("Updating Istio AuthorizationPolicy", "namespace", istioAuth.ObjectMeta.Namespace,
 				"name", istioAuth.ObjectMeta.Name)
@@ -588,15 +898,17 @@ func (r *ProfileReconciler) updateResourceQuota(profileIns *profilev1.Profile,
 	return nil
 }
 
-// updateServiceAccount create or update service account "saName" with role "ClusterRoleName" in target namespace owned by "profileIns"
-func (r *ProfileReconciler) updateServiceAccount(profileIns *profilev1.Profile, saName string,
-	ClusterRoleName string) error {
+// updateServiceAccount create or update service account "sa" with its RoleBinding in target namespace owned by "profileIns"
+func (r *ProfileReconciler) updateServiceAccount(profileIns *profilev1.Profile, sa profilev1.ProfileServiceAccount) error {
 	logger := r.Log.WithValues("profile", profileIns.Name)
 	serviceAccount := &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      saName,
-			Namespace: profileIns.Name,
+			Annotations: sa.Annotations,
+			Labels:      map[string]string{SERVICE_ACCOUNT_LABEL: "true"},
+			Name:        sa.Name,
+			Namespace:   profileIns.Name,
 		},
+		ImagePullSecrets: sa.ImagePullSecrets,
 	}
 	if err := controllerutil.SetControllerReference(profileIns, serviceAccount, r.Scheme); err != nil {
 		return err
@@ -614,22 +926,37 @@ func (r *ProfileReconciler) updateServiceAccount(profileIns *profilev1.Profile,
 		} else {
 			return err
 		}
+	} else {
+		// Plugins annotate the service accounts as well, so annotations are only added.
+		labelsChanged := mergeStringMap(&found.Labels, serviceAccount.Labels)
+		annotationsChanged := mergeStringMap(&found.Annotations, serviceAccount.Annotations)
+		secretsChanged := mergeImagePullSecrets(&found.ImagePullSecrets, serviceAccount.ImagePullSecrets)
+		if labelsChanged || annotationsChanged || secretsChanged {
+			logger.Info("Updating ServiceAccount", "namespace", serviceAccount.Namespace,
+				"name", serviceAccount.Name)
+			if err := r.Update(context.TODO(), found); err != nil {
+				return err
+			}
+		}
+	}
+	if sa.ClusterRole == "" {
+		return nil
 	}
 	roleBinding := &rbacv1.RoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      saName,
+			Labels:    map[string]string{SERVICE_ACCOUNT_LABEL: "true"},
+			Name:      sa.Name,
 			Namespace: profileIns.Name,
 		},
-		// Use default ClusterRole 'admin' for profile/namespace owner
 		RoleRef: rbacv1.RoleRef{
 			APIGroup: "rbac.authorization.k8s.io",
 			Kind:     "ClusterRole",
-			Name:     ClusterRoleName,
+			Name:     sa.ClusterRole,
 		},
 		Subjects: []rbacv1.Subject{
 			{
 				Kind:      rbacv1.ServiceAccountKind,
-				Name:      saName,
+				Name:      sa.Name,
 				Namespace: profileIns.Name,
 			},
 		},
@@ -657,7 +984,10 @@ func (r *ProfileReconciler) updateRoleBinding(profileIns *profilev1.Profile,
 			return err
 		}
 	} else {
-		if !(reflect.DeepEqual(roleBinding.RoleRef, found.RoleRef) && reflect.DeepEqual(roleBinding.Subjects, found.Subjects)) {
+		labelsChanged := mergeStringMap(&found.Labels, roleBinding.Labels)
+		annotationsChanged := mergeStringMap(&found.Annotations, roleBinding.Annotations)
+		if labelsChanged || annotationsChanged ||
+			!(reflect.DeepEqual(roleBinding.RoleRef, found.RoleRef) && reflect.DeepEqual(roleBinding.Subjects, found.Subjects)) {
 			found.RoleRef = roleBinding.RoleRef
 			found.Subjects = roleBinding.Subjects
 			logger.Info("Updating RoleBinding", "namespace", roleBinding.Namespace, "name", roleBinding.Name)
@@ -673,37 +1003,76 @@ func (r *ProfileReconciler) updateRoleBinding(profileIns *profilev1.Profile,
 // GetPluginSpec will try to unmarshal the plugin spec inside profile for the specified plugin
 // Returns an error if the plugin isn't defined or if there is a problem
 func (r *ProfileReconciler) GetPluginSpec(profileIns *profilev1.Profile) ([]Plugin, error) {
-	logger := r.Log.WithValues("profile", profileIns.Name)
 	plugins := []Plugin{}
 	for _, p := range profileIns.Spec.Plugins {
-		var pluginIns Plugin
-		switch p.Kind {
-		case KIND_WORKLOAD_IDENTITY:
-			pluginIns = &GcpWorkloadIdentity{}
-		case KIND_AWS_IAM_FOR_SERVICE_ACCOUNT:
-			pluginIns = &AwsIAMForServiceAccount{}
-		default:
-			logger.Info("Plugin not recgonized: ", "Kind", p.Kind)
-			continue
+		pluginIns, err := r.getPlugin(profileIns, p)
+		if err != nil {
+			return nil, err
 		}
+		plugins = append(plugins, pluginIns)
+	}
+	return plugins, nil
+}
 
-		// To deserialize it to a specific type we need to first serialize it to bytes
-		// and then unserialize it.
-		specBytes, err := json.Marshal(p.Spec)
-
+// resolvePlugins resolves the plugins of the profile kind by kind, so that
+// a kind that can't be resolved doesn't keep the others from being applied
+// or revoked. It returns the specs of the resolved plugins with the plugins,
+// and the specs and errors of the others.
+func (r *ProfileReconciler) resolvePlugins(profileIns *profilev1.Profile) ([]profilev1.Plugin, []Plugin,
+	[]profilev1.Plugin, []string) {
+	specs := []profilev1.Plugin{}
+	plugins := []Plugin{}
+	unresolved := []profilev1.Plugin{}
+	errs := []string{}
+	for _, p := range profileIns.Spec.Plugins {
+		pluginIns, err := r.getPlugin(profileIns, p)
 		if err != nil {
-			logger.Info("Could not marshal plugin ", p.Kind, "; error: ", err)
-			return nil, err
+			unresolved = append(unresolved, p)
+			errs = append(errs, fmt.Sprintf("%v: %v", p.Kind, err))
+			continue
 		}
+		specs = append(specs, p)
+		plugins = append(plugins, pluginIns)
+	}
+	return specs, plugins, unresolved, errs
+}
 
-		err = json.Unmarshal(specBytes, pluginIns)
+// getPlugin returns the plugin of a spec of the profile.
+func (r *ProfileReconciler) getPlugin(profileIns *profilev1.Profile, p profilev1.Plugin) (Plugin, error) {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	var pluginIns Plugin
+	switch p.Kind {
+	case KIND_WORKLOAD_IDENTITY:
+		pluginIns = &GcpWorkloadIdentity{}
+	case KIND_AWS_IAM_FOR_SERVICE_ACCOUNT:
+		pluginIns = &AwsIAMForServiceAccount{}
+	case KIND_AZURE_WORKLOAD_IDENTITY:
+		pluginIns = &AzureWorkloadIdentity{}
+	default:
+		// Any other kind is handled by the webhook of a ProfilePlugin.
+		registration, err := r.getProfilePlugin(p.Kind)
 		if err != nil {
-			logger.Info("Could not unmarshal plugin ", p.Kind, "; error: ", err)
+			logger.Info("Plugin not recgonized: ", "Kind", p.Kind)
 			return nil, err
 		}
-		plugins = append(plugins, pluginIns)
+		return &WebhookPlugin{Registration: registration, Spec: p.Spec}, nil
 	}
-	return plugins, nil
+
+	// To deserialize it to a specific type we need to first serialize it to bytes
+	// and then unserialize it.
+	specBytes, err := json.Marshal(p.Spec)
+
+	if err != nil {
+		logger.Info("Could not marshal plugin ", p.Kind, "; error: ", err)
+		return nil, err
+	}
+
+	err = json.Unmarshal(specBytes, pluginIns)
+	if err != nil {
+		logger.Info("Could not unmarshal plugin ", p.Kind, "; error: ", err)
+		return nil, err
+	}
+	return pluginIns, nil
 }
 
 // PatchDefaultPluginSpec patch default plugins to profile CR instance if user doesn't specify plugin of same kind in CR.
@@ -123,6 +123,7 @@ type getPluginSpecSuite struct {
 func TestGetPluginSpec(t *testing.T) {
 	role_arn := "arn:aws:iam::123456789012:role/test-iam-role"
 	gcp_sa := "kubeflow2@project-id.iam.gserviceaccount.com"
+	azure_client_id := "00000000-0000-0000-0000-000000000000"
 	tests := []getPluginSpecSuite{
 		{
 			&profilev1.Profile{
@@ -174,6 +175,31 @@ func TestGetPluginSpec(t *testing.T) {
 				},
 			},
 		},
+		{
+			&profilev1.Profile{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "azure-user-profile",
+					Namespace: "k8snamespace",
+				},
+				Spec: profilev1.ProfileSpec{
+					Plugins: []profilev1.Plugin{
+						{
+							TypeMeta: metav1.TypeMeta{
+								Kind: KIND_AZURE_WORKLOAD_IDENTITY,
+							},
+							Spec: &runtime.RawExtension{
+								Raw: []byte(fmt.Sprintf(`{"clientId": "%v"}`, azure_client_id)),
+							},
+						},
+					},
+				},
+			},
+			[]Plugin{
+				&AzureWorkloadIdentity{
+					ClientID: azure_client_id,
+				},
+			},
+		},
 	}
 	for _, test := range tests {
 		loadedPlugins, err := createMockReconciler().GetPluginSpec(test.profile)
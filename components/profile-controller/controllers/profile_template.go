@@ -0,0 +1,397 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// TEMPLATE_LABEL is set on the objects copied into the profile namespace, to
+// the name of the ProfileTemplate they are copied from, or empty for the seed
+// resources, so that the ones removed from them are pruned.
+const TEMPLATE_LABEL = "profiles.kubeflow.org/template"
+
+// COPY_SOURCE_ANNOTATION is set to the namespace/name of the object a copied
+// object was copied from.
+const COPY_SOURCE_ANNOTATION = "profiles.kubeflow.org/source"
+
+var podDefaultGVK = schema.GroupVersionKind{Group: "kubeflow.org", Version: "v1alpha1", Kind: "PodDefault"}
+
+// getProfileTemplate returns the ProfileTemplate of the Profile, nil if it
+// has none. A missing template doesn't block the deletion of the Profile, see
+// applyRecordedPlugins.
+func (r *ProfileReconciler) getProfileTemplate(ctx context.Context,
+	profileIns *profilev1.Profile) (*profilev1.ProfileTemplate, error) {
+	if profileIns.Spec.Template == "" {
+		return nil, nil
+	}
+	template := &profilev1.ProfileTemplate{}
+	if err := r.Get(ctx, types.NamespacedName{Name: profileIns.Spec.Template}, template); err != nil {
+		if apierrors.IsNotFound(err) && !profileIns.DeletionTimestamp.IsZero() {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return template, nil
+}
+
+// applyProfileTemplate returns a copy of the Profile whose unset fields are
+// the ones of the template.
+func applyProfileTemplate(profileIns *profilev1.Profile, template *profilev1.ProfileTemplate) *profilev1.Profile {
+	profile := profileIns.DeepCopy()
+	if template == nil {
+		return profile
+	}
+	spec := template.Spec.DeepCopy()
+	if len(profile.Spec.ResourceQuotaSpec.Hard) == 0 {
+		profile.Spec.ResourceQuotaSpec = spec.ResourceQuotaSpec
+	}
+	if profile.Spec.LimitRange == nil {
+		profile.Spec.LimitRange = spec.LimitRange
+	}
+	kinds := map[string]bool{}
+	for _, p := range profile.Spec.Plugins {
+		kinds[p.Kind] = true
+	}
+	for _, p := range spec.Plugins {
+		if !kinds[p.Kind] {
+			profile.Spec.Plugins = append(profile.Spec.Plugins, p)
+		}
+	}
+	// The service accounts are merged by name, the last ones win.
+	profile.Spec.ServiceAccounts = append(spec.ServiceAccounts, profile.Spec.ServiceAccounts...)
+	return profile
+}
+
+// applyRecordedPlugins returns a copy of the deleted Profile with the plugins
+// recorded in its status that it doesn't have anymore, e.g. the ones of a
+// template that is gone, from the spec they were last applied with, so that
+// they are revoked too.
+func applyRecordedPlugins(profileIns *profilev1.Profile) *profilev1.Profile {
+	profile := profileIns.DeepCopy()
+	kinds := map[string]bool{}
+	for _, p := range profile.Spec.Plugins {
+		kinds[p.Kind] = true
+	}
+	for _, status := range profile.Status.Plugins {
+		if kinds[status.Kind] || status.Spec == nil {
+			continue
+		}
+		profile.Spec.Plugins = append(profile.Spec.Plugins, profilev1.Plugin{
+			TypeMeta: metav1.TypeMeta{Kind: status.Kind},
+			Spec:     status.Spec,
+		})
+	}
+	return profile
+}
+
+// getTemplateNamespaceLabels returns the default namespace labels overridden
+// by the ones of the template.
+func getTemplateNamespaceLabels(defaults map[string]string, template *profilev1.ProfileTemplate) map[string]string {
+	if template == nil || len(template.Spec.NamespaceLabels) == 0 {
+		return defaults
+	}
+	labels := map[string]string{}
+	for k, v := range defaults {
+		labels[k] = v
+	}
+	for k, v := range template.Spec.NamespaceLabels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// copiedObjectKinds are the kinds copied into the profile namespaces.
+var copiedObjectKinds = map[string]schema.GroupVersionKind{
+	"ConfigMap":  {Version: "v1", Kind: "ConfigMap"},
+	"Secret":     {Version: "v1", Kind: "Secret"},
+	"PodDefault": podDefaultGVK,
+}
+
+// copiedObject is an object copied into the profile namespace, from a source
+// object or from a PodDefault of the template.
+type copiedObject struct {
+	kind string
+	name string
+	// source is the namespace/name of the source object, empty for the
+	// PodDefaults of the template.
+	source string
+	// template is the name of the ProfileTemplate, empty for the seed
+	// resources.
+	template   string
+	syncPolicy string
+	// content is the fields copied, all of them but the metadata and the
+	// status.
+	content map[string]interface{}
+}
+
+// getCopiedContent returns the fields of the object that are copied, all of
+// them but its metadata and status.
+func getCopiedContent(obj *unstructured.Unstructured) map[string]interface{} {
+	content := map[string]interface{}{}
+	for k, v := range obj.Object {
+		switch k {
+		case "apiVersion", "kind", "metadata", "status":
+			continue
+		}
+		content[k] = v
+	}
+	return content
+}
+
+// reconcileProfileTemplateResources copies the PodDefaults, ConfigMaps and
+// Secrets of the template, and the seed resources, into the profile
+// namespace, and deletes the ones that were removed from them. The objects
+// kept in sync are updated when their source changed, the others are only
+// created. It returns the objects it skipped: the ones whose source is
+// missing, whose copy is kept until it is back, the ones whose kind isn't
+// installed, the ones copied twice, and the existing objects that weren't
+// copied by the Profile, which are left as is.
+func (r *ProfileReconciler) reconcileProfileTemplateResources(ctx context.Context, profileIns *profilev1.Profile,
+	template *profilev1.ProfileTemplate) ([]string, error) {
+	objects, problems, err := r.getCopiedObjects(ctx, template)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]map[string]bool{}
+	for kind := range copiedObjectKinds {
+		names[kind] = map[string]bool{}
+	}
+	for _, obj := range objects {
+		if names[obj.kind][obj.name] {
+			problems = append(problems, fmt.Sprintf("%s %s is copied twice, from the template and the seed resources",
+				obj.kind, obj.name))
+			continue
+		}
+		names[obj.kind][obj.name] = true
+		if obj.content == nil {
+			continue
+		}
+		err := r.copyObject(ctx, profileIns, obj)
+		if err == nil {
+			continue
+		}
+		if obj.source != "" {
+			err = errors.Wrapf(err, "%s %s", obj.kind, obj.source)
+		} else {
+			err = errors.Wrapf(err, "%s %s", obj.kind, obj.name)
+		}
+		if errors.Cause(err) != errCopyConflict && !meta.IsNoMatchError(errors.Cause(err)) {
+			return problems, err
+		}
+		problems = append(problems, err.Error())
+	}
+	for kind, gvk := range copiedObjectKinds {
+		err := r.pruneCopiedObjects(ctx, profileIns, gvk, names[kind])
+		if meta.IsNoMatchError(err) {
+			// The kind isn't installed, so there are none to prune.
+			continue
+		}
+		if err != nil {
+			return problems, err
+		}
+	}
+	return problems, nil
+}
+
+// getCopiedObjects returns the objects of the template and the seed resources,
+// without content if their source is missing, and the problems with the
+// sources.
+func (r *ProfileReconciler) getCopiedObjects(ctx context.Context,
+	template *profilev1.ProfileTemplate) ([]copiedObject, []string, error) {
+	sources := []SeedResource{}
+	templateName := ""
+	objects := []copiedObject{}
+	problems := []string{}
+	if template != nil {
+		templateName = template.Name
+		for _, source := range template.Spec.ConfigMaps {
+			sources = append(sources, SeedResource{Kind: "ConfigMap", Namespace: source.Namespace,
+				Name: source.Name, SyncPolicy: SyncKeepInSync})
+		}
+		for _, source := range template.Spec.Secrets {
+			sources = append(sources, SeedResource{Kind: "Secret", Namespace: source.Namespace,
+				Name: source.Name, SyncPolicy: SyncKeepInSync})
+		}
+		for _, podDefault := range template.Spec.PodDefaults {
+			copied := copiedObject{kind: podDefaultGVK.Kind, name: podDefault.Name, template: templateName,
+				syncPolicy: SyncKeepInSync}
+			spec := map[string]interface{}{}
+			if len(podDefault.Spec.Raw) > 0 {
+				if err := utiljson.Unmarshal(podDefault.Spec.Raw, &spec); err != nil {
+					problems = append(problems, fmt.Sprintf("PodDefault %s: %v", podDefault.Name, err))
+					objects = append(objects, copied)
+					continue
+				}
+			}
+			copied.content = map[string]interface{}{"spec": spec}
+			objects = append(objects, copied)
+		}
+	}
+	seeds, err := r.getSeedResources()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, source := range append(sources, seeds...) {
+		copied := copiedObject{kind: source.Kind, name: source.Name,
+			source: source.Namespace + "/" + source.Name, syncPolicy: source.SyncPolicy}
+		if i < len(sources) {
+			copied.template = templateName
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(copiedObjectKinds[source.Kind])
+		err := r.Get(ctx, types.NamespacedName{Name: source.Name, Namespace: source.Namespace}, obj)
+		switch {
+		case apierrors.IsNotFound(err) || meta.IsNoMatchError(err):
+			problems = append(problems, fmt.Sprintf("%s %s: %v", source.Kind, copied.source, err))
+		case err != nil:
+			return nil, nil, errors.Wrapf(err, "%s %s", source.Kind, copied.source)
+		default:
+			copied.content = getCopiedContent(obj)
+		}
+		objects = append(objects, copied)
+	}
+	return objects, problems, nil
+}
+
+// errCopyConflict is the cause of the errors of the objects of the profile
+// namespace with the name of a copied object that weren't copied by the
+// Profile.
+var errCopyConflict = errors.New("conflict")
+
+// checkTemplateObject returns an error if an object of the profile namespace
+// with the name of a copied object wasn't copied by the Profile, so that it
+// isn't overwritten.
+func checkTemplateObject(profileIns *profilev1.Profile, kind string, obj metav1.Object) error {
+	if _, ok := obj.GetLabels()[TEMPLATE_LABEL]; ok && metav1.IsControlledBy(obj, profileIns) {
+		return nil
+	}
+	return errors.Wrapf(errCopyConflict, "%v %v/%v already exists and wasn't copied by the profile", kind,
+		obj.GetNamespace(), obj.GetName())
+}
+
+// copyObject creates or updates the copied object in the profile namespace.
+func (r *ProfileReconciler) copyObject(ctx context.Context, profileIns *profilev1.Profile, obj copiedObject) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	gvk := copiedObjectKinds[obj.kind]
+	labels := map[string]string{TEMPLATE_LABEL: obj.template}
+	annotations := map[string]string{}
+	if obj.source != "" {
+		annotations[COPY_SOURCE_ANNOTATION] = obj.source
+	}
+
+	found := &unstructured.Unstructured{}
+	found.SetGroupVersionKind(gvk)
+	err := r.Get(ctx, types.NamespacedName{Name: obj.name, Namespace: profileIns.Name}, found)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		created := &unstructured.Unstructured{Object: runtime.DeepCopyJSON(obj.content)}
+		created.SetGroupVersionKind(gvk)
+		created.SetName(obj.name)
+		created.SetNamespace(profileIns.Name)
+		created.SetLabels(labels)
+		if len(annotations) > 0 {
+			created.SetAnnotations(annotations)
+		}
+		if err := controllerutil.SetControllerReference(profileIns, created, r.Scheme); err != nil {
+			return err
+		}
+		logger.Info(fmt.Sprintf("Creating %s", obj.kind), "namespace", created.GetNamespace(),
+			"name", created.GetName())
+		return r.Create(ctx, created)
+	}
+	if err := checkTemplateObject(profileIns, obj.kind, found); err != nil {
+		return err
+	}
+	if obj.syncPolicy == SyncCreateOnce {
+		return nil
+	}
+	foundLabels := found.GetLabels()
+	foundAnnotations := found.GetAnnotations()
+	metadataChanged := mergeStringMap(&foundLabels, labels)
+	metadataChanged = mergeStringMap(&foundAnnotations, annotations) || metadataChanged
+	if !metadataChanged && reflect.DeepEqual(getCopiedContent(found), obj.content) {
+		return nil
+	}
+	updated := &unstructured.Unstructured{Object: runtime.DeepCopyJSON(obj.content)}
+	updated.SetGroupVersionKind(gvk)
+	updated.Object["metadata"] = found.Object["metadata"]
+	updated.SetLabels(foundLabels)
+	updated.SetAnnotations(foundAnnotations)
+	logger.Info(fmt.Sprintf("Updating %s", obj.kind), "namespace", found.GetNamespace(), "name", found.GetName())
+	return r.Update(ctx, updated)
+}
+
+// pruneCopiedObjects deletes the objects of the kind copied by the Profile
+// whose name isn't in names.
+func (r *ProfileReconciler) pruneCopiedObjects(ctx context.Context, profileIns *profilev1.Profile,
+	gvk schema.GroupVersionKind, names map[string]bool) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err := r.List(ctx, list, client.InNamespace(profileIns.Name), client.HasLabels{TEMPLATE_LABEL}); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		obj := &list.Items[i]
+		if names[obj.GetName()] || !metav1.IsControlledBy(obj, profileIns) {
+			continue
+		}
+		logger.Info("Deleting object removed from profile template or seed resources", "kind", gvk.Kind,
+			"namespace", obj.GetNamespace(), "name", obj.GetName())
+		if err := r.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapProfileTemplateToRequests maps a ProfileTemplate to reconcile requests
+// for the Profiles referencing it
+func (r *ProfileReconciler) mapProfileTemplateToRequests(o client.Object) []reconcile.Request {
+	req := []reconcile.Request{}
+	profileList := &profilev1.ProfileList{}
+	if err := r.List(context.TODO(), profileList); err != nil {
+		r.Log.Error(err, "Failed to list profiles in order to trigger reconciliation")
+		return req
+	}
+	for _, p := range profileList.Items {
+		if p.Spec.Template != o.GetName() {
+			continue
+		}
+		req = append(req, reconcile.Request{NamespacedName: types.NamespacedName{Name: p.Name}})
+	}
+	return req
+}
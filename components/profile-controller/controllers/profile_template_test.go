@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func newTestProfileTemplate() *profilev1.ProfileTemplate {
+	return &profilev1.ProfileTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "standard", Generation: 3},
+		Spec: profilev1.ProfileTemplateSpec{
+			ResourceQuotaSpec: corev1.ResourceQuotaSpec{
+				Hard: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("8")},
+			},
+			NamespaceLabels: map[string]string{"example.com/tier": "standard"},
+			Plugins: []profilev1.Plugin{{
+				TypeMeta: metav1.TypeMeta{Kind: KIND_WORKLOAD_IDENTITY},
+				Spec:     &runtime.RawExtension{Raw: []byte(`{"gcpServiceAccount":"template@example.com"}`)},
+			}},
+			ServiceAccounts: []profilev1.ProfileServiceAccount{{Name: "pipeline-runner", ClusterRole: kubeflowEdit}},
+			PodDefaults: []profilev1.ProfileTemplatePodDefault{{
+				Name: "add-gcp-secret",
+				Spec: runtime.RawExtension{Raw: []byte(`{"selector":{"matchLabels":{"add-gcp-secret":"true"}}}`)},
+			}},
+			ConfigMaps: []profilev1.ProfileTemplateSource{{Namespace: "kubeflow", Name: "pip-config"}},
+			Secrets:    []profilev1.ProfileTemplateSource{{Namespace: "kubeflow", Name: "registry"}},
+		},
+	}
+}
+
+// newTestLabelsFile writes a namespace labels file, which Reconcile requires.
+func newTestLabelsFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "namespace-labels.yaml")
+	if err := ioutil.WriteFile(path, []byte("app.kubernetes.io/part-of: kubeflow-profile\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApplyProfileTemplate(t *testing.T) {
+	profile := &profilev1.Profile{
+		Spec: profilev1.ProfileSpec{
+			Plugins: []profilev1.Plugin{{
+				TypeMeta: metav1.TypeMeta{Kind: KIND_WORKLOAD_IDENTITY},
+				Spec:     &runtime.RawExtension{Raw: []byte(`{"gcpServiceAccount":"profile@example.com"}`)},
+			}},
+			ServiceAccounts: []profilev1.ProfileServiceAccount{{Name: "pipeline-runner", ClusterRole: kubeflowView}},
+		},
+	}
+	applied := applyProfileTemplate(profile, newTestProfileTemplate())
+
+	if _, ok := applied.Spec.ResourceQuotaSpec.Hard[corev1.ResourceCPU]; !ok {
+		t.Errorf("Expected the quota of the template, got %+v", applied.Spec.ResourceQuotaSpec)
+	}
+	if len(applied.Spec.Plugins) != 1 || string(applied.Spec.Plugins[0].Spec.Raw) !=
+		`{"gcpServiceAccount":"profile@example.com"}` {
+		t.Errorf("Expected the plugin of the Profile to override the template, got %+v", applied.Spec.Plugins)
+	}
+	serviceAccounts := getProfileServiceAccounts(applied, nil)
+	if len(serviceAccounts) != 1 || serviceAccounts[0].ClusterRole != kubeflowView {
+		t.Errorf("Expected the service account of the Profile to override the template, got %+v", serviceAccounts)
+	}
+	if len(profile.Spec.ResourceQuotaSpec.Hard) != 0 || len(profile.Spec.ServiceAccounts) != 1 {
+		t.Errorf("Expected the Profile to be left unchanged, got %+v", profile.Spec)
+	}
+}
+
+func TestReconcileProfileTemplate(t *testing.T) {
+	template := newTestProfileTemplate()
+	// The plugins of the template are covered by TestApplyProfileTemplate.
+	template.Spec.Plugins = nil
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"},
+		Spec: profilev1.ProfileSpec{
+			Owner:    rbacv1.Subject{Kind: rbacv1.UserKind, Name: "user@example.com"},
+			Template: template.Name,
+		},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "pip-config", Namespace: "kubeflow"},
+		Data:       map[string]string{"pip.conf": "[global]"},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "kubeflow"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte("{}")},
+	}
+	r := newTestReconciler(profile, template, configMap, secret)
+	r.DefaultNamespaceLabelsPath = newTestLabelsFile(t)
+	r.Scheme.AddKnownTypeWithName(podDefaultGVK, &unstructured.Unstructured{})
+	r.Scheme.AddKnownTypeWithName(podDefaultGVK.GroupVersion().WithKind(podDefaultGVK.Kind+"List"),
+		&unstructured.UnstructuredList{})
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: profile.Name}}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: profile.Name}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if ns.Labels["example.com/tier"] != "standard" {
+		t.Errorf("Expected the labels of the template, got %v", ns.Labels)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: KFQUOTA, Namespace: profile.Name},
+		&corev1.ResourceQuota{}); err != nil {
+		t.Errorf("Expected the quota of the template: %v", err)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: "pipeline-runner", Namespace: profile.Name},
+		&corev1.ServiceAccount{}); err != nil {
+		t.Errorf("Expected the service account of the template: %v", err)
+	}
+	copied := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "pip-config", Namespace: profile.Name}, copied); err != nil {
+		t.Fatalf("Expected the ConfigMap to be copied: %v", err)
+	}
+	if copied.Data["pip.conf"] != "[global]" || copied.Labels[TEMPLATE_LABEL] != template.Name {
+		t.Errorf("Unexpected ConfigMap %+v", copied)
+	}
+	copiedSecret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "registry", Namespace: profile.Name}, copiedSecret); err != nil {
+		t.Fatalf("Expected the Secret to be copied: %v", err)
+	}
+	if copiedSecret.Type != corev1.SecretTypeDockerConfigJson {
+		t.Errorf("Unexpected Secret %+v", copiedSecret)
+	}
+	podDefault := &unstructured.Unstructured{}
+	podDefault.SetGroupVersionKind(podDefaultGVK)
+	if err := r.Get(ctx, types.NamespacedName{Name: "add-gcp-secret", Namespace: profile.Name}, podDefault); err != nil {
+		t.Fatalf("Expected the PodDefault to be created: %v", err)
+	}
+
+	found := &profilev1.Profile{}
+	if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Status.ObservedTemplateGeneration != template.Generation {
+		t.Errorf("Expected the generation of the template in the status, got %+v", found.Status)
+	}
+	if len(found.Spec.Plugins) != 0 || len(found.Spec.ResourceQuotaSpec.Hard) != 0 {
+		t.Errorf("Expected the template not to be written to the Profile, got %+v", found.Spec)
+	}
+
+	// The objects removed from the template are deleted.
+	if err := r.Get(ctx, types.NamespacedName{Name: template.Name}, template); err != nil {
+		t.Fatal(err)
+	}
+	template.Spec.ConfigMaps = nil
+	if err := r.Update(ctx, template); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: "pip-config", Namespace: profile.Name},
+		&corev1.ConfigMap{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the ConfigMap to be deleted, got %v", err)
+	}
+	if requests := r.mapProfileTemplateToRequests(template); len(requests) != 1 ||
+		requests[0].Name != profile.Name {
+		t.Errorf("Expected the Profile to be reconciled on template changes, got %v", requests)
+	}
+}
+
+func TestCopyTemplateConfigMapConflict(t *testing.T) {
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user", UID: "profile-uid"}}
+	source := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "pip-config", Namespace: "kubeflow"},
+		Data:       map[string]string{"pip.conf": "[global]"},
+	}
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "pip-config", Namespace: profile.Name},
+		Data:       map[string]string{"pip.conf": "[user]"},
+	}
+	template := &profilev1.ProfileTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "standard"},
+		Spec: profilev1.ProfileTemplateSpec{
+			ConfigMaps: []profilev1.ProfileTemplateSource{{Namespace: "kubeflow", Name: "pip-config"}},
+		},
+	}
+	r := newTestReconciler(profile, source, existing)
+	r.Scheme.AddKnownTypeWithName(podDefaultGVK, &unstructured.Unstructured{})
+	r.Scheme.AddKnownTypeWithName(podDefaultGVK.GroupVersion().WithKind(podDefaultGVK.Kind+"List"),
+		&unstructured.UnstructuredList{})
+	ctx := context.Background()
+	problems, err := r.reconcileProfileTemplateResources(ctx, profile, template)
+	if err != nil || len(problems) != 1 || !strings.Contains(problems[0], "conflict") {
+		t.Errorf("Expected a conflict, got %v, %v", problems, err)
+	}
+	found := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "pip-config", Namespace: profile.Name}, found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Data["pip.conf"] != "[user]" {
+		t.Errorf("Expected the existing ConfigMap to be kept, got %v", found.Data)
+	}
+}
+
+func TestApplyRecordedPlugins(t *testing.T) {
+	spec := &runtime.RawExtension{Raw: []byte(`{"gcpServiceAccount":"sa@project.iam.gserviceaccount.com"}`)}
+	profile := &profilev1.Profile{
+		Spec: profilev1.ProfileSpec{Plugins: []profilev1.Plugin{{
+			TypeMeta: metav1.TypeMeta{Kind: KIND_AWS_IAM_FOR_SERVICE_ACCOUNT},
+		}}},
+		Status: profilev1.ProfileStatus{Plugins: []profilev1.ProfilePluginStatus{
+			{Kind: KIND_AWS_IAM_FOR_SERVICE_ACCOUNT, Spec: spec},
+			{Kind: KIND_WORKLOAD_IDENTITY, Spec: spec},
+			{Kind: KIND_AZURE_WORKLOAD_IDENTITY},
+		}},
+	}
+	// The template of the workload identity plugin is gone, the azure one has no recorded spec.
+	plugins := applyRecordedPlugins(profile).Spec.Plugins
+	if len(plugins) != 2 || plugins[1].Kind != KIND_WORKLOAD_IDENTITY || string(plugins[1].Spec.Raw) != string(spec.Raw) {
+		t.Errorf("Expected the recorded plugin to be added, got %+v", plugins)
+	}
+	if len(profile.Spec.Plugins) != 1 {
+		t.Errorf("Expected the Profile to be unchanged, got %+v", profile.Spec.Plugins)
+	}
+}
+
+func TestReconcileProfileTemplateNotFound(t *testing.T) {
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"},
+		Spec: profilev1.ProfileSpec{
+			Owner:    rbacv1.Subject{Kind: rbacv1.UserKind, Name: "user@example.com"},
+			Template: "missing",
+		},
+	}
+	r := newTestReconciler(profile)
+	r.DefaultNamespaceLabelsPath = newTestLabelsFile(t)
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: profile.Name}}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	found := &profilev1.Profile{}
+	if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+		t.Fatal(err)
+	}
+	ready := findCondition(found.Status.Conditions, profilev1.ProfileReady)
+	if ready.Status != "False" || ready.Reason != reasonTemplateNotFound {
+		t.Errorf("Expected the missing template to be reported, got %+v", ready)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: profile.Name}, &corev1.Namespace{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected no namespace without template, got %v", err)
+	}
+}
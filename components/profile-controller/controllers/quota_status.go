@@ -0,0 +1,138 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// getQuotaStatus returns the hard limits and the usage of the ResourceQuota,
+// with what remains of every hard limit.
+func getQuotaStatus(resourceQuota *corev1.ResourceQuota) *profilev1.ProfileQuotaStatus {
+	status := &profilev1.ProfileQuotaStatus{
+		Hard:      resourceQuota.Status.Hard.DeepCopy(),
+		Used:      resourceQuota.Status.Used.DeepCopy(),
+		Remaining: corev1.ResourceList{},
+	}
+	for name, hard := range status.Hard {
+		remaining := hard.DeepCopy()
+		if used, ok := status.Used[name]; ok {
+			remaining.Sub(used)
+		}
+		// The usage exceeds the limits lowered below it.
+		if remaining.Sign() < 0 {
+			remaining = *resource.NewQuantity(0, hard.Format)
+		}
+		status.Remaining[name] = remaining
+	}
+	return status
+}
+
+// readQuotaStatus returns the status of the ResourceQuota of the Profile, nil
+// if it has none or if the quota controller didn't compute its usage yet.
+func (r *ProfileReconciler) readQuotaStatus(ctx context.Context,
+	profileIns *profilev1.Profile) (*profilev1.ProfileQuotaStatus, error) {
+	resourceQuota := &corev1.ResourceQuota{}
+	err := r.Get(ctx, types.NamespacedName{Name: KFQUOTA, Namespace: profileIns.Name}, resourceQuota)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(resourceQuota.Status.Hard) == 0 {
+		return nil, nil
+	}
+	return getQuotaStatus(resourceQuota), nil
+}
+
+// quotaSpecChanged lets through the events of the ResourceQuotas, but for the
+// updates of their status by the quota controller, which only change the
+// usage the quotaStatusReconciler mirrors.
+var quotaSpecChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldQuota, ok := e.ObjectOld.(*corev1.ResourceQuota)
+		if !ok {
+			return true
+		}
+		newQuota, ok := e.ObjectNew.(*corev1.ResourceQuota)
+		if !ok {
+			return true
+		}
+		return !equality.Semantic.DeepEqual(oldQuota.Spec, newQuota.Spec) ||
+			!equality.Semantic.DeepEqual(oldQuota.Labels, newQuota.Labels) ||
+			!equality.Semantic.DeepEqual(oldQuota.OwnerReferences, newQuota.OwnerReferences) ||
+			!newQuota.DeletionTimestamp.Equal(oldQuota.DeletionTimestamp)
+	},
+}
+
+// quotaStatusReconciler mirrors the usage of the kf-resource-quota
+// ResourceQuota of a profile namespace into the status of its Profile and
+// into the quota gauges, without reconciling the Profile.
+type quotaStatusReconciler struct {
+	*ProfileReconciler
+}
+
+// setupQuotaStatusWithManager watches the status of the kf-resource-quota
+// ResourceQuotas.
+func (r *ProfileReconciler) setupQuotaStatusWithManager(mgr ctrl.Manager) error {
+	isQuota := predicate.NewPredicateFuncs(func(o client.Object) bool {
+		return o.GetName() == KFQUOTA
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("profile-quota-status").
+		For(&corev1.ResourceQuota{}, builder.WithPredicates(isQuota)).
+		Complete(&quotaStatusReconciler{r})
+}
+
+// Reconcile updates status.quota of the Profile of the namespace of the
+// ResourceQuota, and the quota gauges, if the usage changed.
+func (r *quotaStatusReconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
+	profileIns := &profilev1.Profile{}
+	if err := r.Get(ctx, types.NamespacedName{Name: request.Namespace}, profileIns); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !profileIns.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	quota, err := r.readQuotaStatus(ctx, profileIns)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if equality.Semantic.DeepEqual(quota, profileIns.Status.Quota) {
+		return ctrl.Result{}, nil
+	}
+	SetQuotaGauges(profileIns.Name, profileIns.Status.Quota, quota)
+	patch := client.MergeFrom(profileIns.DeepCopy())
+	profileIns.Status.Quota = quota
+	if err := r.Status().Patch(ctx, profileIns, patch); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestGetQuotaStatus(t *testing.T) {
+	status := getQuotaStatus(&corev1.ResourceQuota{
+		Status: corev1.ResourceQuotaStatus{
+			Hard: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("4"),
+				corev1.ResourceMemory: resource.MustParse("8Gi"),
+				corev1.ResourcePods:   resource.MustParse("10"),
+			},
+			Used: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1500m"),
+				corev1.ResourceMemory: resource.MustParse("10Gi"),
+			},
+		},
+	})
+	for name, expected := range map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    "2500m",
+		corev1.ResourceMemory: "0",
+		corev1.ResourcePods:   "10",
+	} {
+		remaining := status.Remaining[name]
+		if remaining.Cmp(resource.MustParse(expected)) != 0 {
+			t.Errorf("Expected %v of %v to remain, got %v", expected, name, remaining.String())
+		}
+	}
+}
+
+func TestReconcileQuotaStatus(t *testing.T) {
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"},
+		Spec: profilev1.ProfileSpec{
+			Owner: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "user@example.com"},
+			ResourceQuotaSpec: corev1.ResourceQuotaSpec{
+				Hard: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+			},
+		},
+	}
+	r := newTestReconciler(profile)
+	r.DefaultNamespaceLabelsPath = newTestLabelsFile(t)
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: profile.Name}}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	found := &profilev1.Profile{}
+	if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Status.Quota != nil {
+		t.Errorf("Expected no usage before the quota controller computes it, got %+v", found.Status.Quota)
+	}
+
+	// The quota controller computes the usage.
+	resourceQuota := &corev1.ResourceQuota{}
+	if err := r.Get(ctx, types.NamespacedName{Name: KFQUOTA, Namespace: profile.Name}, resourceQuota); err != nil {
+		t.Fatal(err)
+	}
+	resourceQuota.Status = corev1.ResourceQuotaStatus{
+		Hard: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+		Used: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("3")},
+	}
+	updated := resourceQuota.DeepCopy()
+	if err := r.Status().Update(ctx, updated); err != nil {
+		t.Fatal(err)
+	}
+	// Only the usage changed, so the Profile isn't reconciled, its status is updated.
+	if quotaSpecChanged.Update(event.UpdateEvent{ObjectOld: resourceQuota, ObjectNew: updated}) {
+		t.Errorf("Expected the update of the quota status not to reconcile the Profile")
+	}
+	quotaRequest := ctrl.Request{NamespacedName: types.NamespacedName{Name: KFQUOTA, Namespace: profile.Name}}
+	if _, err := (&quotaStatusReconciler{r}).Reconcile(ctx, quotaRequest); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Status.Quota == nil {
+		t.Fatalf("Expected the usage of the quota in the status")
+	}
+	remaining := found.Status.Quota.Remaining[corev1.ResourceCPU]
+	if remaining.Cmp(resource.MustParse("1")) != 0 {
+		t.Errorf("Expected 1 CPU to remain, got %+v", found.Status.Quota)
+	}
+	labels := prometheus.Labels{PROFILE_NAME: profile.Name, RESOURCE: string(corev1.ResourceCPU)}
+	if used := testutil.ToFloat64(quotaUsedGauge.With(labels)); used != 3 {
+		t.Errorf("Expected the usage to be exported, got %v", used)
+	}
+
+	// Removing the quota removes its usage.
+	found.Spec.ResourceQuotaSpec = corev1.ResourceQuotaSpec{}
+	if err := r.Update(ctx, found); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Status.Quota != nil {
+		t.Errorf("Expected no usage without quota, got %+v", found.Status.Quota)
+	}
+	if count := testutil.CollectAndCount(quotaUsedGauge); count != 0 {
+		t.Errorf("Expected the gauges of the quota to be removed, got %v", count)
+	}
+}
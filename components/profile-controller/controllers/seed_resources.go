@@ -0,0 +1,211 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sigs.k8s.io/yaml"
+)
+
+// Sync policies of the objects copied into the profile namespaces.
+const (
+	// SyncKeepInSync updates the copies when the source changes.
+	SyncKeepInSync = "KeepInSync"
+	// SyncCreateOnce only creates the copies, which the users can change.
+	SyncCreateOnce = "CreateOnce"
+)
+
+// SeedResource is an object copied with the same name into every profile
+// namespace, like the ConfigMaps and Secrets of a ProfileTemplate.
+type SeedResource struct {
+	// Kind of the object: ConfigMap, Secret or PodDefault
+	Kind string `json:"kind"`
+	// Namespace of the object to copy
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// SyncPolicy is KeepInSync, the default, or CreateOnce
+	SyncPolicy string `json:"syncPolicy,omitempty"`
+}
+
+// readSeedResourcesFromFile reads the list of the objects to copy into every
+// profile namespace. There are none if the file doesn't exist.
+func readSeedResourcesFromFile(path string) ([]SeedResource, error) {
+	if path == "" {
+		return nil, nil
+	}
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	seeds := []SeedResource{}
+	if err := yaml.UnmarshalStrict(dat, &seeds); err != nil {
+		return nil, errors.Wrapf(err, "Unable to parse seed resources %s", path)
+	}
+	names := map[string]bool{}
+	for i, seed := range seeds {
+		if _, ok := copiedObjectKinds[seed.Kind]; !ok {
+			return nil, errors.Errorf("Unsupported seed resource kind %q in %s", seed.Kind, path)
+		}
+		if seed.Namespace == "" || seed.Name == "" {
+			return nil, errors.Errorf("%s without namespace or name in %s", seed.Kind, path)
+		}
+		switch seed.SyncPolicy {
+		case "":
+			seeds[i].SyncPolicy = SyncKeepInSync
+		case SyncKeepInSync, SyncCreateOnce:
+		default:
+			return nil, errors.Errorf("Unsupported sync policy %q of %s %s/%s in %s", seed.SyncPolicy, seed.Kind,
+				seed.Namespace, seed.Name, path)
+		}
+		key := seed.Kind + "/" + seed.Name
+		if names[key] {
+			return nil, errors.Errorf("%s %s is seeded twice in %s", seed.Kind, seed.Name, path)
+		}
+		names[key] = true
+	}
+	return seeds, nil
+}
+
+// seedResourcesCache keeps the seed resources read from the file until it
+// changes, since they are read for every event of the sources.
+type seedResourcesCache struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	size    int64
+	seeds   []SeedResource
+}
+
+// getSeedResources returns the seed resources of the file of
+// SeedResourcesPath, which is only read again when it changed.
+func (r *ProfileReconciler) getSeedResources() ([]SeedResource, error) {
+	c := &r.seedResources
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	path := r.SeedResourcesPath
+	info, err := os.Stat(path)
+	if path == "" || os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.path == path && c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+		return c.seeds, nil
+	}
+	seeds, err := readSeedResourcesFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c.path, c.modTime, c.size, c.seeds = path, info.ModTime(), info.Size(), seeds
+	return seeds, nil
+}
+
+// watchCopySources watches the ConfigMaps and Secrets, and the PodDefaults if
+// their CRD is installed, of CopySourceNamespaces, so that the copies are kept
+// in sync with their source. They are cached in these namespaces only.
+func (r *ProfileReconciler) watchCopySources(mgr ctrl.Manager, c *builder.Builder) error {
+	if len(r.CopySourceNamespaces) == 0 {
+		return nil
+	}
+	sourceCache, err := cache.MultiNamespacedCacheBuilder(r.CopySourceNamespaces)(mgr.GetConfig(),
+		cache.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+	if err != nil {
+		return err
+	}
+	if err := mgr.Add(sourceCache); err != nil {
+		return err
+	}
+	sources := []client.Object{&corev1.ConfigMap{}, &corev1.Secret{}}
+	if _, err := mgr.GetRESTMapper().RESTMapping(podDefaultGVK.GroupKind(), podDefaultGVK.Version); err == nil {
+		podDefault := &unstructured.Unstructured{}
+		podDefault.SetGroupVersionKind(podDefaultGVK)
+		sources = append(sources, podDefault)
+	} else {
+		r.Log.Info("PodDefault kind isn't installed, the PodDefault seed resources aren't watched")
+	}
+	for _, obj := range sources {
+		c.Watches(source.NewKindWithCache(obj, sourceCache), handler.EnqueueRequestsFromMapFunc(r.mapCopySourceToRequests))
+	}
+	return nil
+}
+
+// mapCopySourceToRequests maps a source of the seed resources to reconcile
+// requests for all Profiles, and a source of a ProfileTemplate to the
+// Profiles referencing it.
+func (r *ProfileReconciler) mapCopySourceToRequests(o client.Object) []reconcile.Request {
+	// The objects of the cache have no type information.
+	kind := ""
+	switch obj := o.(type) {
+	case *corev1.ConfigMap:
+		kind = "ConfigMap"
+	case *corev1.Secret:
+		kind = "Secret"
+	case *unstructured.Unstructured:
+		kind = obj.GetKind()
+	}
+	seeds, err := r.getSeedResources()
+	if err != nil {
+		r.Log.Error(err, "Failed to read seed resources in order to trigger reconciliation")
+	}
+	for _, seed := range seeds {
+		if seed.Kind == kind && seed.Namespace == o.GetNamespace() && seed.Name == o.GetName() {
+			return r.mapEventToRequest(o)
+		}
+	}
+
+	req := []reconcile.Request{}
+	templates := &profilev1.ProfileTemplateList{}
+	if err := r.List(context.TODO(), templates); err != nil {
+		r.Log.Error(err, "Failed to list profile templates in order to trigger reconciliation")
+		return req
+	}
+	for i := range templates.Items {
+		sources := templates.Items[i].Spec.ConfigMaps
+		if kind == "Secret" {
+			sources = templates.Items[i].Spec.Secrets
+		} else if kind != "ConfigMap" {
+			continue
+		}
+		for _, source := range sources {
+			if source.Namespace == o.GetNamespace() && source.Name == o.GetName() {
+				req = append(req, r.mapProfileTemplateToRequests(&templates.Items[i])...)
+				break
+			}
+		}
+	}
+	return req
+}
@@ -0,0 +1,325 @@
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestReadSeedResourcesFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seed-resources.yaml")
+	seeds, err := readSeedResourcesFromFile(path)
+	if err != nil || len(seeds) != 0 {
+		t.Fatalf("Expected no seed resources without file, got %v, %v", seeds, err)
+	}
+
+	for content, valid := range map[string]bool{
+		"- {kind: ConfigMap, namespace: kubeflow, name: pip-config}":                                               true,
+		"- {kind: Secret, namespace: kubeflow, name: registry, syncPolicy: CreateOnce}":                            true,
+		"- {kind: Deployment, namespace: kubeflow, name: app}":                                                     false,
+		"- {kind: ConfigMap, name: pip-config}":                                                                    false,
+		"- {kind: ConfigMap, namespace: kubeflow, name: pip-config, syncPolicy: Sometimes}":                        false,
+		"- {kind: ConfigMap, namespace: a, name: pip-config}\n- {kind: ConfigMap, namespace: b, name: pip-config}": false,
+	} {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		seeds, err := readSeedResourcesFromFile(path)
+		if valid && err != nil {
+			t.Errorf("Unexpected error for %q: %v", content, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected an error for %q", content)
+		}
+		if valid && seeds[0].SyncPolicy == "" {
+			t.Errorf("Expected a sync policy for %q", content)
+		}
+	}
+}
+
+func TestGetSeedResources(t *testing.T) {
+	r := &ProfileReconciler{SeedResourcesPath: filepath.Join(t.TempDir(), "seed-resources.yaml")}
+	if seeds, err := r.getSeedResources(); err != nil || len(seeds) != 0 {
+		t.Fatalf("Expected no seed resources without file, got %v, %v", seeds, err)
+	}
+
+	modTime := time.Now().Add(-time.Hour)
+	write := func(content string) {
+		if err := ioutil.WriteFile(r.SeedResourcesPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(r.SeedResourcesPath, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("- {kind: ConfigMap, namespace: kubeflow, name: pip-config}")
+	seeds, err := r.getSeedResources()
+	if err != nil || len(seeds) != 1 || seeds[0].Name != "pip-config" {
+		t.Fatalf("Expected the pip-config seed resource, got %v, %v", seeds, err)
+	}
+
+	// A file with the same size and modification time isn't read again.
+	write("- {kind: ConfigMap, namespace: kubeflow, name: pip-cfg-02}")
+	if seeds, err := r.getSeedResources(); err != nil || seeds[0].Name != "pip-config" {
+		t.Errorf("Expected the cached seed resources, got %v, %v", seeds, err)
+	}
+
+	modTime = modTime.Add(time.Minute)
+	write("- {kind: ConfigMap, namespace: kubeflow, name: pip-cfg-02}")
+	if seeds, err := r.getSeedResources(); err != nil || seeds[0].Name != "pip-cfg-02" {
+		t.Errorf("Expected the seed resources to be read again, got %v, %v", seeds, err)
+	}
+
+	write("- {kind: Deployment, namespace: kubeflow, name: app}")
+	if _, err := r.getSeedResources(); err == nil {
+		t.Errorf("Expected an error for an invalid file")
+	}
+}
+
+func TestReconcileSeedResources(t *testing.T) {
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"}}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "pip-config", Namespace: "kubeflow", Labels: map[string]string{"a": "b"}},
+		Data:       map[string]string{"pip.conf": "[global]"},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "kubeflow"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte("{}")},
+	}
+	// An object of the users is never pruned.
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "defaults", Namespace: profile.Name,
+			Labels: map[string]string{TEMPLATE_LABEL: ""}},
+		Data: map[string]string{"team": "ml"},
+	}
+	r := newTestReconciler(profile, configMap, secret, existing)
+	r.Scheme.AddKnownTypeWithName(podDefaultGVK, &unstructured.Unstructured{})
+	r.Scheme.AddKnownTypeWithName(podDefaultGVK.GroupVersion().WithKind(podDefaultGVK.Kind+"List"),
+		&unstructured.UnstructuredList{})
+	r.SeedResourcesPath = filepath.Join(t.TempDir(), "seed-resources.yaml")
+	if err := ioutil.WriteFile(r.SeedResourcesPath, []byte(`
+- {kind: ConfigMap, namespace: kubeflow, name: pip-config}
+- {kind: Secret, namespace: kubeflow, name: registry, syncPolicy: CreateOnce}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if problems, err := r.reconcileProfileTemplateResources(ctx, profile, nil); err != nil || len(problems) > 0 {
+		t.Fatalf("Unexpected error: %v, %v", problems, err)
+	}
+
+	foundConfigMap := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "pip-config", Namespace: profile.Name}, foundConfigMap); err != nil {
+		t.Fatalf("Expected the ConfigMap to be seeded: %v", err)
+	}
+	if foundConfigMap.Data["pip.conf"] != "[global]" || foundConfigMap.Labels[TEMPLATE_LABEL] != "" ||
+		foundConfigMap.Labels["a"] != "" || foundConfigMap.Annotations[COPY_SOURCE_ANNOTATION] != "kubeflow/pip-config" ||
+		len(foundConfigMap.OwnerReferences) != 1 {
+		t.Errorf("Unexpected seeded ConfigMap %+v", foundConfigMap)
+	}
+	foundSecret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "registry", Namespace: profile.Name}, foundSecret); err != nil {
+		t.Fatalf("Expected the Secret to be seeded: %v", err)
+	}
+	if foundSecret.Type != corev1.SecretTypeDockerConfigJson {
+		t.Errorf("Expected the type of the Secret to be copied, got %v", foundSecret.Type)
+	}
+
+	// The sources kept in sync are copied again, the others aren't.
+	configMap.Data = map[string]string{"pip.conf": "[install]"}
+	if err := r.Update(ctx, configMap); err != nil {
+		t.Fatal(err)
+	}
+	secret.Data = map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)}
+	if err := r.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if problems, err := r.reconcileProfileTemplateResources(ctx, profile, nil); err != nil || len(problems) > 0 {
+		t.Fatalf("Unexpected error: %v, %v", problems, err)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: "pip-config", Namespace: profile.Name}, foundConfigMap); err != nil {
+		t.Fatal(err)
+	}
+	if foundConfigMap.Data["pip.conf"] != "[install]" {
+		t.Errorf("Expected the ConfigMap to be kept in sync, got %+v", foundConfigMap)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: "registry", Namespace: profile.Name}, foundSecret); err != nil {
+		t.Fatal(err)
+	}
+	if string(foundSecret.Data[corev1.DockerConfigJsonKey]) != "{}" {
+		t.Errorf("Expected the Secret to be created once, got %s", foundSecret.Data[corev1.DockerConfigJsonKey])
+	}
+
+	// The seeds removed from the file are pruned, but not the objects of the users.
+	if err := ioutil.WriteFile(r.SeedResourcesPath, []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if problems, err := r.reconcileProfileTemplateResources(ctx, profile, nil); err != nil || len(problems) > 0 {
+		t.Fatalf("Unexpected error: %v, %v", problems, err)
+	}
+	err := r.Get(ctx, types.NamespacedName{Name: "pip-config", Namespace: profile.Name}, foundConfigMap)
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the ConfigMap to be pruned, got %v", err)
+	}
+	err = r.Get(ctx, types.NamespacedName{Name: "registry", Namespace: profile.Name}, foundSecret)
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the Secret to be pruned, got %v", err)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: "defaults", Namespace: profile.Name}, foundConfigMap); err != nil {
+		t.Errorf("Expected the ConfigMap of the users to be kept: %v", err)
+	}
+}
+
+func TestReconcileSeedResourcesConflict(t *testing.T) {
+	profile := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user", UID: "profile-uid"}}
+	source := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "defaults", Namespace: "kubeflow"},
+		Data:       map[string]string{"team": "default"},
+	}
+	// An object of the users with the name of a seed is a conflict, and is left as is.
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "defaults", Namespace: profile.Name},
+		Data:       map[string]string{"team": "ml"},
+	}
+	r := newTestReconciler(profile, source, existing)
+	r.Scheme.AddKnownTypeWithName(podDefaultGVK, &unstructured.Unstructured{})
+	r.Scheme.AddKnownTypeWithName(podDefaultGVK.GroupVersion().WithKind(podDefaultGVK.Kind+"List"),
+		&unstructured.UnstructuredList{})
+	r.SeedResourcesPath = filepath.Join(t.TempDir(), "seed-resources.yaml")
+	if err := ioutil.WriteFile(r.SeedResourcesPath,
+		[]byte("- {kind: ConfigMap, namespace: kubeflow, name: defaults}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	problems, err := r.reconcileProfileTemplateResources(ctx, profile, nil)
+	if err != nil || len(problems) != 1 || !strings.Contains(problems[0], "conflict") {
+		t.Errorf("Expected a conflict, got %v, %v", problems, err)
+	}
+	found := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "defaults", Namespace: profile.Name}, found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Data["team"] != "ml" {
+		t.Errorf("Expected the ConfigMap of the users to be left as is, got %v", found.Data)
+	}
+
+	// A seed object copied from the template too is only copied once.
+	template := &profilev1.ProfileTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "standard"},
+		Spec: profilev1.ProfileTemplateSpec{
+			ConfigMaps: []profilev1.ProfileTemplateSource{{Namespace: "kubeflow", Name: "defaults"}},
+		},
+	}
+	if err := r.Delete(ctx, existing); err != nil {
+		t.Fatal(err)
+	}
+	problems, err = r.reconcileProfileTemplateResources(ctx, profile, template)
+	if err != nil || len(problems) != 1 || !strings.Contains(problems[0], "twice") {
+		t.Errorf("Expected the object copied twice to be reported, got %v, %v", problems, err)
+	}
+}
+
+func TestMapCopySourceToRequests(t *testing.T) {
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user"},
+		Spec:       profilev1.ProfileSpec{Template: "standard"},
+	}
+	other := &profilev1.Profile{ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-other"}}
+	template := &profilev1.ProfileTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "standard"},
+		Spec: profilev1.ProfileTemplateSpec{
+			Secrets: []profilev1.ProfileTemplateSource{{Namespace: "kubeflow", Name: "registry"}},
+		},
+	}
+	r := newTestReconciler(profile, other, template)
+	r.SeedResourcesPath = filepath.Join(t.TempDir(), "seed-resources.yaml")
+	if err := ioutil.WriteFile(r.SeedResourcesPath,
+		[]byte("- {kind: ConfigMap, namespace: kubeflow, name: pip-config}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		obj      client.Object
+		expected int
+	}{
+		{&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "pip-config", Namespace: "kubeflow"}}, 2},
+		{&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "kubeflow"}}, 1},
+		// A Secret with the name of a seed ConfigMap isn't a source.
+		{&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "pip-config", Namespace: "kubeflow"}}, 0},
+		{&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "kubeflow"}}, 0},
+	} {
+		if requests := r.mapCopySourceToRequests(tc.obj); len(requests) != tc.expected {
+			t.Errorf("Expected %d requests for %T %s, got %v", tc.expected, tc.obj, tc.obj.GetName(), requests)
+		}
+	}
+}
+
+func TestReconcileMissingSeedSource(t *testing.T) {
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeflow-user", Finalizers: []string{PROFILEFINALIZER}},
+		Spec: profilev1.ProfileSpec{
+			Owner: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "user@example.com"},
+		},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "pip-config", Namespace: "kubeflow"},
+		Data:       map[string]string{"pip.conf": "[global]"},
+	}
+	r := newTestReconciler(profile, configMap)
+	r.Scheme.AddKnownTypeWithName(podDefaultGVK, &unstructured.Unstructured{})
+	r.Scheme.AddKnownTypeWithName(podDefaultGVK.GroupVersion().WithKind(podDefaultGVK.Kind+"List"),
+		&unstructured.UnstructuredList{})
+	r.DefaultNamespaceLabelsPath = newTestLabelsFile(t)
+	r.SeedResourcesPath = filepath.Join(t.TempDir(), "seed-resources.yaml")
+	if err := ioutil.WriteFile(r.SeedResourcesPath, []byte(`
+- {kind: ConfigMap, namespace: kubeflow, name: pip-config}
+- {kind: Secret, namespace: kubeflow, name: registry}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: profile.Name}}
+
+	// The missing source is reported, the other seeds are copied.
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	found := &profilev1.Profile{}
+	if err := r.Get(ctx, request.NamespacedName, found); err != nil {
+		t.Fatal(err)
+	}
+	ready := findCondition(found.Status.Conditions, profilev1.ProfileTemplateReady)
+	if ready.Status != "False" || ready.Reason != reasonTemplateResourcesFailed ||
+		!strings.Contains(ready.Message, "kubeflow/registry") {
+		t.Errorf("Expected the missing source to be reported, got %+v", ready)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: "pip-config", Namespace: profile.Name},
+		&corev1.ConfigMap{}); err != nil {
+		t.Errorf("Expected the ConfigMap to be seeded: %v", err)
+	}
+
+	// The Profile is deleted while the source is still missing.
+	if err := r.Delete(ctx, found); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Get(ctx, request.NamespacedName, found); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the finalizer to be removed, got %v", err)
+	}
+}
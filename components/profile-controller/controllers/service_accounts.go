@@ -0,0 +1,163 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// SERVICE_ACCOUNT_LABEL marks the ServiceAccounts and their RoleBindings
+// provisioned for a Profile, so that the ones removed from it are pruned.
+const SERVICE_ACCOUNT_LABEL = "profiles.kubeflow.org/service-account"
+
+// defaultServiceAccounts are provisioned when there is no cluster default
+// file.
+var defaultServiceAccounts = []profilev1.ProfileServiceAccount{
+	// "default-editor" would have kubeflowEdit permission: edit all resources in target namespace except rbac.
+	{Name: DEFAULT_EDITOR, ClusterRole: kubeflowEdit},
+	// "default-viewer" would have k8s default "view" permission: view all resources in target namespace.
+	{Name: DEFAULT_VIEWER, ClusterRole: kubeflowView},
+}
+
+// readDefaultServiceAccountsFromFile reads the service accounts of every
+// Profile. The built-in defaults are used if the file doesn't exist.
+func readDefaultServiceAccountsFromFile(path string) ([]profilev1.ProfileServiceAccount, error) {
+	if path == "" {
+		return defaultServiceAccounts, nil
+	}
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return defaultServiceAccounts, nil
+		}
+		return nil, err
+	}
+	serviceAccounts := []profilev1.ProfileServiceAccount{}
+	if err := yaml.UnmarshalStrict(dat, &serviceAccounts); err != nil {
+		return nil, errors.Wrapf(err, "Unable to parse default service accounts %s", path)
+	}
+	for _, sa := range serviceAccounts {
+		if sa.Name == "" {
+			return nil, errors.Errorf("Service account without name in %s", path)
+		}
+	}
+	return serviceAccounts, nil
+}
+
+// getProfileServiceAccounts returns the default service accounts, overridden
+// by name and completed by the ones of the Profile.
+func getProfileServiceAccounts(profileIns *profilev1.Profile,
+	defaults []profilev1.ProfileServiceAccount) []profilev1.ProfileServiceAccount {
+	serviceAccounts := []profilev1.ProfileServiceAccount{}
+	index := map[string]int{}
+	for _, sa := range append(append([]profilev1.ProfileServiceAccount{}, defaults...),
+		profileIns.Spec.ServiceAccounts...) {
+		if i, ok := index[sa.Name]; ok {
+			serviceAccounts[i] = sa
+			continue
+		}
+		index[sa.Name] = len(serviceAccounts)
+		serviceAccounts = append(serviceAccounts, sa)
+	}
+	return serviceAccounts
+}
+
+// reconcileServiceAccounts provisions the service accounts of the Profile
+// with their RoleBindings, and deletes the ones that were removed from it.
+func (r *ProfileReconciler) reconcileServiceAccounts(ctx context.Context, profileIns *profilev1.Profile) error {
+	logger := r.Log.WithValues("profile", profileIns.Name)
+	defaults, err := readDefaultServiceAccountsFromFile(r.DefaultServiceAccountsPath)
+	if err != nil {
+		return err
+	}
+
+	serviceAccountNames := map[string]bool{}
+	roleBindingNames := map[string]bool{}
+	for _, sa := range getProfileServiceAccounts(profileIns, defaults) {
+		if err := r.updateServiceAccount(profileIns, sa); err != nil {
+			return errors.Wrapf(err, "service account %s", sa.Name)
+		}
+		serviceAccountNames[sa.Name] = true
+		if sa.ClusterRole != "" {
+			roleBindingNames[sa.Name] = true
+		}
+	}
+
+	serviceAccounts := &corev1.ServiceAccountList{}
+	if err := r.List(ctx, serviceAccounts, client.InNamespace(profileIns.Name),
+		client.MatchingLabels{SERVICE_ACCOUNT_LABEL: "true"}); err != nil {
+		return err
+	}
+	for i := range serviceAccounts.Items {
+		sa := &serviceAccounts.Items[i]
+		if serviceAccountNames[sa.Name] {
+			continue
+		}
+		logger.Info("Deleting ServiceAccount removed from profile", "namespace", sa.Namespace, "name", sa.Name)
+		if err := r.Delete(ctx, sa); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := r.List(ctx, roleBindings, client.InNamespace(profileIns.Name),
+		client.MatchingLabels{SERVICE_ACCOUNT_LABEL: "true"}); err != nil {
+		return err
+	}
+	for i := range roleBindings.Items {
+		roleBinding := &roleBindings.Items[i]
+		if roleBindingNames[roleBinding.Name] {
+			continue
+		}
+		logger.Info("Deleting RoleBinding of ServiceAccount removed from profile", "namespace",
+			roleBinding.Namespace, "name", roleBinding.Name)
+		if err := r.Delete(ctx, roleBinding); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeImagePullSecrets adds the secrets of src missing in dst and returns
+// true if dst changed.
+func mergeImagePullSecrets(dst *[]corev1.LocalObjectReference, src []corev1.LocalObjectReference) bool {
+	changed := false
+	for _, secret := range src {
+		found := false
+		for _, existing := range *dst {
+			if existing.Name == secret.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*dst = append(*dst, secret)
+			changed = true
+		}
+	}
+	return changed
+}
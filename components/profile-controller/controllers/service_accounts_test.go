@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	profilev1 "github.com/kubeflow/kubeflow/components/profile-controller/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReadDefaultServiceAccountsFromFile(t *testing.T) {
+	dir := t.TempDir()
+	defaults, err := readDefaultServiceAccountsFromFile(filepath.Join(dir, "missing.yaml"))
+	if err != nil || len(defaults) != 2 {
+		t.Fatalf("Expected the built-in defaults, got %v, %v", defaults, err)
+	}
+
+	path := filepath.Join(dir, "service-accounts.yaml")
+	if err := ioutil.WriteFile(path, []byte(`
+- name: default-editor
+  clusterRole: kubeflow-admin
+  imagePullSecrets:
+  - name: registry
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defaults, err = readDefaultServiceAccountsFromFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(defaults) != 1 || defaults[0].ClusterRole != "kubeflow-admin" ||
+		defaults[0].ImagePullSecrets[0].Name != "registry" {
+		t.Errorf("Unexpected service accounts %+v", defaults)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`- clusterRole: kubeflow-admin`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readDefaultServiceAccountsFromFile(path); err == nil {
+		t.Errorf("Expected an error for a service account without name")
+	}
+}
+
+func TestGetProfileServiceAccounts(t *testing.T) {
+	profile := &profilev1.Profile{
+		Spec: profilev1.ProfileSpec{
+			ServiceAccounts: []profilev1.ProfileServiceAccount{
+				{Name: DEFAULT_VIEWER},
+				{Name: "pipeline-runner", ClusterRole: kubeflowEdit},
+			},
+		},
+	}
+	serviceAccounts := getProfileServiceAccounts(profile, defaultServiceAccounts)
+	expected := []profilev1.ProfileServiceAccount{
+		{Name: DEFAULT_EDITOR, ClusterRole: kubeflowEdit},
+		{Name: DEFAULT_VIEWER},
+		{Name: "pipeline-runner", ClusterRole: kubeflowEdit},
+	}
+	if len(serviceAccounts) != len(expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, serviceAccounts)
+	}
+	for i := range expected {
+		if serviceAccounts[i].Name != expected[i].Name || serviceAccounts[i].ClusterRole != expected[i].ClusterRole {
+			t.Errorf("Expected %+v, got %+v", expected[i], serviceAccounts[i])
+		}
+	}
+}
+
+func TestReconcileServiceAccounts(t *testing.T) {
+	namespace := "kubeflow-user"
+	profile := &profilev1.Profile{
+		ObjectMeta: metav1.ObjectMeta{Name: namespace},
+		Spec: profilev1.ProfileSpec{
+			ServiceAccounts: []profilev1.ProfileServiceAccount{{
+				Name:             "pipeline-runner",
+				ClusterRole:      kubeflowView,
+				Annotations:      map[string]string{"example.com/team": "ml"},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+			}},
+		},
+	}
+	// The annotation of a plugin must be kept.
+	r := newTestReconciler(profile, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        DEFAULT_EDITOR,
+			Namespace:   namespace,
+			Annotations: map[string]string{GCP_ANNOTATION_KEY: "gsa@project.iam.gserviceaccount.com"},
+		},
+	})
+	ctx := context.Background()
+	if err := r.reconcileServiceAccounts(ctx, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, name := range []string{DEFAULT_EDITOR, DEFAULT_VIEWER, "pipeline-runner"} {
+		sa := &corev1.ServiceAccount{}
+		if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, sa); err != nil {
+			t.Fatalf("Expected ServiceAccount %s: %v", name, err)
+		}
+		if sa.Labels[SERVICE_ACCOUNT_LABEL] != "true" {
+			t.Errorf("Expected ServiceAccount %s to be labeled, got %v", name, sa.Labels)
+		}
+		roleBinding := &rbacv1.RoleBinding{}
+		if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, roleBinding); err != nil {
+			t.Fatalf("Expected RoleBinding %s: %v", name, err)
+		}
+	}
+	editor := &corev1.ServiceAccount{}
+	if err := r.Get(ctx, types.NamespacedName{Name: DEFAULT_EDITOR, Namespace: namespace}, editor); err != nil {
+		t.Fatal(err)
+	}
+	if editor.Annotations[GCP_ANNOTATION_KEY] == "" {
+		t.Errorf("Expected the annotation of the plugin to be kept, got %v", editor.Annotations)
+	}
+	runner := &corev1.ServiceAccount{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "pipeline-runner", Namespace: namespace}, runner); err != nil {
+		t.Fatal(err)
+	}
+	if runner.Annotations["example.com/team"] != "ml" || len(runner.ImagePullSecrets) != 1 ||
+		runner.ImagePullSecrets[0].Name != "registry" {
+		t.Errorf("Unexpected ServiceAccount %+v", runner)
+	}
+
+	// Removing the service account from the Profile deletes it with its RoleBinding.
+	profile.Spec.ServiceAccounts = nil
+	if err := r.reconcileServiceAccounts(ctx, profile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: "pipeline-runner", Namespace: namespace},
+		&corev1.ServiceAccount{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the ServiceAccount to be deleted, got %v", err)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: "pipeline-runner", Namespace: namespace},
+		&rbacv1.RoleBinding{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the RoleBinding to be deleted, got %v", err)
+	}
+}
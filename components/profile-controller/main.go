@@ -19,6 +19,8 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -27,6 +29,7 @@ import (
 	istioSecurityClient "istio.io/client-go/pkg/apis/security/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
@@ -40,8 +43,22 @@ import (
 
 const USERIDHEADER = "userid-header"
 const USERIDPREFIX = "userid-prefix"
+const GROUPSHEADER = "groups-header"
 const WORKLOADIDENTITY = "workload-identity"
 const DEFAULTNAMESPACELABELSPATH = "namespace-labels-path"
+const DEFAULTSERVICEACCOUNTSPATH = "service-accounts-path"
+const DEFAULTLIMITRANGEPATH = "limit-range-path"
+const NETWORKPOLICIESPATH = "network-policies-path"
+const EXPIRATIONWARNING = "expiration-warning"
+const EXPIRATIONGRACEPERIOD = "expiration-grace-period"
+const STOPNOTEBOOKSONEXPIRATION = "stop-notebooks-on-expiration"
+const DEFAULTPODSECURITYPATH = "pod-security-path"
+const SEEDRESOURCESPATH = "seed-resources-path"
+const COPYSOURCENAMESPACES = "copy-source-namespaces"
+const NAMESPACELABELSQPS = "namespace-labels-qps"
+const NAMESPACELABELSBURST = "namespace-labels-burst"
+const PLUGINDRIFTINTERVAL = "plugin-drift-interval"
+const ENABLECONVERSIONWEBHOOK = "enable-conversion-webhook"
 
 var (
 	scheme   = runtime.NewScheme()
@@ -63,8 +80,22 @@ func main() {
 	var probeAddr string
 	var userIdHeader string
 	var userIdPrefix string
+	var groupsHeader string
 	var workloadIdentity string
 	var defaultNamespaceLabelsPath string
+	var defaultServiceAccountsPath string
+	var defaultLimitRangePath string
+	var networkPoliciesPath string
+	var defaultPodSecurityPath string
+	var seedResourcesPath string
+	var copySourceNamespaces string
+	var namespaceLabelsQPS float64
+	var namespaceLabelsBurst int
+	var pluginDriftInterval time.Duration
+	var expirationWarning time.Duration
+	var expirationGracePeriod time.Duration
+	var stopNotebooksOnExpiration bool
+	var enableConversionWebhook bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":9876", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -74,8 +105,22 @@ func main() {
 		"Determines the namespace in which the leader election configmap will be created.")
 	flag.StringVar(&userIdHeader, USERIDHEADER, "x-goog-authenticated-user-email", "Key of request header containing user id")
 	flag.StringVar(&userIdPrefix, USERIDPREFIX, "accounts.google.com:", "Request header user id common prefix")
+	flag.StringVar(&groupsHeader, GROUPSHEADER, "", "Key of request header containing the comma separated groups of the user. Group owners and contributors get no AuthorizationPolicy if empty")
 	flag.StringVar(&workloadIdentity, WORKLOADIDENTITY, "", "Default identity (GCP service account) for workload_identity plugin")
 	flag.StringVar(&defaultNamespaceLabelsPath, DEFAULTNAMESPACELABELSPATH, "/etc/profile-controller/namespace-labels.yaml", "A YAML file with a map of labels to be set on every Profile namespace")
+	flag.StringVar(&defaultServiceAccountsPath, DEFAULTSERVICEACCOUNTSPATH, "/etc/profile-controller/service-accounts.yaml", "A YAML file with the list of service accounts to create in every Profile namespace. default-editor and default-viewer if it doesn't exist")
+	flag.StringVar(&defaultLimitRangePath, DEFAULTLIMITRANGEPATH, "/etc/profile-controller/limit-range.yaml", "A YAML file with the LimitRangeSpec of the Profile namespaces without spec.limitRange")
+	flag.StringVar(&networkPoliciesPath, NETWORKPOLICIESPATH, "/etc/profile-controller/network-policies.yaml", "A YAML file with the list of NetworkPolicies to create in every Profile namespace. A built-in set isolating the namespaces if it doesn't exist")
+	flag.StringVar(&defaultPodSecurityPath, DEFAULTPODSECURITYPATH, "/etc/profile-controller/pod-security.yaml", "A YAML file with the Pod Security Admission levels (enforce, audit, warn and version) of the Profile namespaces without spec.podSecurity")
+	flag.StringVar(&seedResourcesPath, SEEDRESOURCESPATH, "/etc/profile-controller/seed-resources.yaml", "A YAML file with the list of PodDefaults, ConfigMaps and Secrets (kind, namespace, name and syncPolicy) to copy into every Profile namespace")
+	flag.StringVar(&copySourceNamespaces, COPYSOURCENAMESPACES, "kubeflow", "Comma separated list of namespaces whose PodDefaults, ConfigMaps and Secrets are watched as sources of the copied objects. None are watched if empty")
+	flag.Float64Var(&namespaceLabelsQPS, NAMESPACELABELSQPS, 10, "How many namespaces per second are updated when the namespace labels file changes")
+	flag.IntVar(&namespaceLabelsBurst, NAMESPACELABELSBURST, 100, "How many namespaces are updated at once when the namespace labels file changes")
+	flag.DurationVar(&pluginDriftInterval, PLUGINDRIFTINTERVAL, 24*time.Hour, "How often the plugins of the Profiles are checked for drift, or applied again if they can't be checked. They are only applied when their spec changes if 0")
+	flag.DurationVar(&expirationWarning, EXPIRATIONWARNING, 72*time.Hour, "How long before the expiration of a Profile its users are warned")
+	flag.DurationVar(&expirationGracePeriod, EXPIRATIONGRACEPERIOD, 24*time.Hour, "How long after its expiration a Profile is deleted")
+	flag.BoolVar(&stopNotebooksOnExpiration, STOPNOTEBOOKSONEXPIRATION, false, "Stop the Notebooks of the Profiles once they expire")
+	flag.BoolVar(&enableConversionWebhook, ENABLECONVERSIONWEBHOOK, true, "Serve the webhook converting the Profiles between v1beta1 and v1. It needs the serving certificates in /tmp/k8s-webhook-server/serving-certs")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -84,7 +129,8 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	cfg := ctrl.GetConfigOrDie()
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		HealthProbeBindAddress: probeAddr,
@@ -95,6 +141,11 @@ func main() {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		setupLog.Error(err, "unable to create clientset")
+		os.Exit(1)
+	}
 
 	if err = (&controllers.ProfileReconciler{
 		Client:                     mgr.GetClient(),
@@ -102,12 +153,33 @@ func main() {
 		Log:                        ctrl.Log.WithName("controllers").WithName("Profile"),
 		UserIdHeader:               userIdHeader,
 		UserIdPrefix:               userIdPrefix,
+		GroupsHeader:               groupsHeader,
 		WorkloadIdentity:           workloadIdentity,
 		DefaultNamespaceLabelsPath: defaultNamespaceLabelsPath,
+		DefaultServiceAccountsPath: defaultServiceAccountsPath,
+		DefaultLimitRangePath:      defaultLimitRangePath,
+		NetworkPoliciesPath:        networkPoliciesPath,
+		DefaultPodSecurityPath:     defaultPodSecurityPath,
+		SeedResourcesPath:          seedResourcesPath,
+		CopySourceNamespaces:       splitNamespaces(copySourceNamespaces),
+		NamespaceLabelsQPS:         namespaceLabelsQPS,
+		NamespaceLabelsBurst:       namespaceLabelsBurst,
+		PluginDriftInterval:        pluginDriftInterval,
+		ExpirationWarning:          expirationWarning,
+		ExpirationGracePeriod:      expirationGracePeriod,
+		StopNotebooksOnExpiration:  stopNotebooksOnExpiration,
+		Recorder:                   mgr.GetEventRecorderFor("profile-controller"),
+		CoreRESTClient:             clientset.CoreV1().RESTClient(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Profile")
 		os.Exit(1)
 	}
+	if enableConversionWebhook {
+		if err = ctrl.NewWebhookManagedBy(mgr).For(&profilev1.Profile{}).Complete(); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Profile")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -125,3 +197,14 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// splitNamespaces returns the non-empty namespaces of a comma separated list.
+func splitNamespaces(list string) []string {
+	var namespaces []string
+	for _, ns := range strings.Split(list, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
@@ -17,10 +17,8 @@ limitations under the License.
 package v1alpha1
 
 import (
-	"bytes"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"os"
 	"reflect"
 
 	"k8s.io/apimachinery/pkg/runtime"
@@ -29,56 +27,58 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
-	"k8s.io/apimachinery/pkg/util/yaml"
-
 	corev1 "k8s.io/api/core/v1"
-)
 
-const DefaultPodSpecPathEnvName = "DEFAULT_POD_SPEC_PATH"
+	"github.com/kubeflow/kubeflow/components/pvc-viewer/pkg/config"
+)
 
 // log is for logging in this package.
 var pvcviewerlog = logf.Log.WithName("pvcviewer-resource")
 
-func (r *PVCViewer) SetupWebhookWithManager(mgr ctrl.Manager) error {
+// SetupWebhookWithManager registers the webhooks of the PVCViewers. They are
+// defaulted with the current configuration of store.
+func (r *PVCViewer) SetupWebhookWithManager(mgr ctrl.Manager, store *config.Store) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
+		WithDefaulter(&pvcViewerDefaulter{config: store}).
 		Complete()
 }
 
 //+kubebuilder:webhook:path=/mutate-kubeflow-org-v1alpha1-pvcviewer,mutating=true,failurePolicy=fail,sideEffects=None,groups=kubeflow.org,resources=pvcviewers,verbs=create;update,versions=v1alpha1,name=mpvcviewer.kb.io,admissionReviewVersions=v1
 
-var _ webhook.Defaulter = &PVCViewer{}
+// pvcViewerDefaulter defaults the PVCViewers with the configuration of the
+// controller, which can change while it is running.
+type pvcViewerDefaulter struct {
+	config *config.Store
+}
 
-// Loads a PodSpec from a filename
-func loadPodSpecDefaultsFromFile(filename string) (corev1.PodSpec, error) {
-	data, err := ioutil.ReadFile(filename)
-	if err != nil {
-		pvcviewerlog.Error(err, "Failed to read podSpec defaults from file "+filename)
-		return corev1.PodSpec{}, err
-	}
+var _ admission.CustomDefaulter = &pvcViewerDefaulter{}
 
-	var podSpec corev1.PodSpec
-	if err := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 100).Decode(&podSpec); err != nil {
-		pvcviewerlog.Error(err, "Failed to unmarshal podSpec defaults file "+filename)
-		return corev1.PodSpec{}, err
+// Default implements admission.CustomDefaulter so a webhook will be registered for the type
+func (d *pvcViewerDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	viewer, ok := obj.(*PVCViewer)
+	if !ok {
+		return fmt.Errorf("expected a PVCViewer, got %T", obj)
 	}
-
-	return podSpec, nil
+	viewer.setDefaults(d.config.Get().DefaultPodSpecPath)
+	return nil
 }
 
-// Default implements webhook.Defaulter so a webhook will be registered for the type
-func (r *PVCViewer) Default() {
+// setDefaults infers the podSpec of the PVCViewer if it has none, from the
+// file at defaultPodSpecPath if set.
+func (r *PVCViewer) setDefaults(defaultPodSpecPath string) {
 	pvcviewerlog.Info("default", "name", r.Name)
 
 	if reflect.DeepEqual(r.Spec.PodSpec, corev1.PodSpec{}) {
 		pvcviewerlog.Info("Inferring default podSpec", "name", r.Name)
 
-		// Load default podSpec from file if environment variable is set
+		// Load default podSpec from file if the configuration sets one
 		var defaultPodSpec corev1.PodSpec
-		if defaultPodSpecPath := os.Getenv(DefaultPodSpecPathEnvName); defaultPodSpecPath != "" {
+		if defaultPodSpecPath != "" {
 			var err error
-			defaultPodSpec, err = loadPodSpecDefaultsFromFile(defaultPodSpecPath)
+			defaultPodSpec, err = config.LoadDefaultPodSpec(defaultPodSpecPath)
 			if err != nil {
+				pvcviewerlog.Error(err, "Failed to load podSpec defaults", "path", defaultPodSpecPath)
 				// We can't throw an error here, so we return
 				// This lets the validating webhook catch the error
 				return
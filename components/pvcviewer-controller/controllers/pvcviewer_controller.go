@@ -19,7 +19,6 @@ package controllers
 import (
 	"context"
 	"fmt"
-	"os"
 
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
@@ -31,16 +30,24 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	kubefloworgv1alpha1 "github.com/kubeflow/kubeflow/components/pvc-viewer/api/v1alpha1"
+	"github.com/kubeflow/kubeflow/components/pvc-viewer/pkg/config"
 )
 
 // PVCViewerReconciler reconciles a PVCViewer object
 type PVCViewerReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// Config holds the configuration of the controller, which can change
+	// while the controller is running.
+	Config *config.Store
+	// NamespaceFilter, if set, restricts the namespaces this instance manages.
+	NamespaceFilter *NamespaceFilter
 }
 
 const (
@@ -52,9 +59,7 @@ const (
 	partOfLabelKey   = "app.kubernetes.io/part-of"
 	partOfLabelValue = "pvc-viewer"
 
-	servicePort         = int32(80)
-	istioGatewayEnvKey  = "ISTIO_GATEWAY"
-	defaultIstioGateway = "kubeflow/kubeflow-gateway"
+	servicePort = int32(80)
 )
 
 var (
@@ -79,16 +84,45 @@ var (
 // Add permissions to read external resources
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PVCViewerReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&kubefloworgv1alpha1.PVCViewer{}).
 		// This controller manages, i.e. creates these kinds for a PVCViewer
 		Owns(&appsv1.Deployment{}).
 		Owns(&corev1.Service{}).
 		Owns(virtualServiceTemplate).
-		Complete(r)
+		// Reconcile every PVCViewer when the configuration changes
+		WatchesRawSource(
+			&source.Channel{Source: r.Config.Subscribe()},
+			handler.EnqueueRequestsFromMapFunc(r.mapConfigToRequests))
+	if r.NamespaceFilter != nil {
+		builder.WithEventFilter(r.NamespaceFilter.Predicate(mgr.GetClient()))
+	}
+	return builder.Complete(r)
+}
+
+// mapConfigToRequests returns a reconciliation request for every PVCViewer
+// managed by this controller instance.
+func (r *PVCViewerReconciler) mapConfigToRequests(ctx context.Context, _ client.Object) []reconcile.Request {
+	viewers := &kubefloworgv1alpha1.PVCViewerList{}
+	if err := r.List(ctx, viewers); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list PVCViewers")
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, viewer := range viewers.Items {
+		if r.NamespaceFilter != nil && !r.NamespaceFilter.Manages(ctx, r.Client, viewer.Namespace) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: viewer.Name, Namespace: viewer.Namespace},
+		})
+	}
+	return requests
 }
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -284,11 +318,7 @@ func (r *PVCViewerReconciler) reconcileVirtualService(ctx context.Context, log l
 		timeout = &viewer.Spec.Networking.Timeout
 	}
 
-	// Get the istio gateway from the environment variable or use the default
-	istioGateway := os.Getenv(istioGatewayEnvKey)
-	if istioGateway == "" {
-		istioGateway = defaultIstioGateway
-	}
+	istioGateway := r.Config.Get().IstioGateway
 
 	virtualService.Object["spec"] = map[string]interface{}{
 		"hosts": []string{"*"},
@@ -2,12 +2,12 @@ package controllers
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 
 	// "strconv"
 
 	kubefloworgv1alpha1 "github.com/kubeflow/kubeflow/components/pvc-viewer/api/v1alpha1"
+	"github.com/kubeflow/kubeflow/components/pvc-viewer/pkg/config"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	appsv1 "k8s.io/api/apps/v1"
@@ -80,10 +80,12 @@ var _ = Describe("PVCViewer controller", func() {
 			Expect(pvcViewer.Spec.PodSpec.Volumes[0].PersistentVolumeClaim.ClaimName).Should(Equal("test-pvc"))
 		})
 
-		It("Should use defaults if environment variable is set", func() {
+		It("Should use defaults if the configuration sets them", func() {
 			filePath, _ := filepath.Abs("testdata/podspec_default.yaml")
-			os.Setenv(kubefloworgv1alpha1.DefaultPodSpecPathEnvName, filePath)
-			defer os.Unsetenv(kubefloworgv1alpha1.DefaultPodSpecPathEnvName)
+			writeConfig("apiVersion: "+config.APIVersion+"\nkind: "+config.Kind+"\ndefaultPodSpecPath: "+filePath+"\n",
+				func(c *config.Config) bool { return c.DefaultPodSpecPath == filePath })
+			defer writeConfig("apiVersion: "+config.APIVersion+"\nkind: "+config.Kind+"\n",
+				func(c *config.Config) bool { return c.DefaultPodSpecPath == "" })
 
 			pvcViewer := testHelper.CreateViewer(&kubefloworgv1alpha1.PVCViewerSpec{
 				PVC: "test-pvc",
@@ -21,6 +21,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"os"
 	"time"
 
 	"path/filepath"
@@ -41,6 +42,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	kubefloworgv1alpha1 "github.com/kubeflow/kubeflow/components/pvc-viewer/api/v1alpha1"
+	"github.com/kubeflow/kubeflow/components/pvc-viewer/pkg/config"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -54,8 +56,22 @@ var (
 
 	ctx    context.Context
 	cancel context.CancelFunc
+
+	// configPath is the configuration file of the controller, which the
+	// tests can change.
+	configPath  string
+	configStore *config.Store
 )
 
+// writeConfig writes the configuration file of the controller and waits
+// until it is reloaded.
+func writeConfig(content string, loaded func(c *config.Config) bool) {
+	Expect(os.WriteFile(configPath, []byte(content), 0644)).Should(Succeed())
+	Eventually(func() bool {
+		return loaded(configStore.Get())
+	}).Should(BeTrue())
+}
+
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
 
@@ -107,12 +123,20 @@ var _ = BeforeSuite(func() {
 	})
 	Expect(err).ToNot(HaveOccurred())
 
-	err = (&kubefloworgv1alpha1.PVCViewer{}).SetupWebhookWithManager(k8sManager)
+	configPath = filepath.Join(GinkgoT().TempDir(), "config.yaml")
+	Expect(os.WriteFile(configPath, []byte("apiVersion: "+config.APIVersion+"\nkind: "+config.Kind+"\n"),
+		0644)).Should(Succeed())
+	configStore, err = config.NewStore(configPath, ctrl.Log.WithName("config"))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(k8sManager.Add(configStore)).Should(Succeed())
+
+	err = (&kubefloworgv1alpha1.PVCViewer{}).SetupWebhookWithManager(k8sManager, configStore)
 	Expect(err).NotTo(HaveOccurred())
 
 	err = (&PVCViewerReconciler{
 		Client: k8sManager.GetClient(),
 		Scheme: k8sManager.GetScheme(),
+		Config: configStore,
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
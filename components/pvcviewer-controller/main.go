@@ -18,6 +18,7 @@ package main
 
 import (
 	"flag"
+	"net/http"
 	"os"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -36,6 +37,7 @@ import (
 
 	kubefloworgv1alpha1 "github.com/kubeflow/kubeflow/components/pvc-viewer/api/v1alpha1"
 	"github.com/kubeflow/kubeflow/components/pvc-viewer/controllers"
+	"github.com/kubeflow/kubeflow/components/pvc-viewer/pkg/config"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -55,11 +57,24 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var namespaces, namespaceSelector string
+	var shardCount, shardID int
+	var configFile string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&namespaces, "namespaces", "",
+		"Comma separated list of namespaces to manage. If empty, all namespaces are managed.")
+	flag.StringVar(&namespaceSelector, "namespace-selector", "",
+		"Label selector for the namespaces to manage. If empty, all namespaces are managed.")
+	flag.IntVar(&shardCount, "shard-count", 1,
+		"Number of shards the managed namespaces are split into. Each shard runs its own leader election.")
+	flag.IntVar(&shardID, "shard-id", -1,
+		"Shard managed by this instance. If negative, it is taken from the ordinal of the pod hostname.")
+	flag.StringVar(&configFile, "config", "",
+		"Path of the configuration file, reloaded when it changes. If empty, the configuration is read from the environment.")
 	opts := zap.Options{
 		Development: true,
 		TimeEncoder: zapcore.RFC3339TimeEncoder,
@@ -69,17 +84,34 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	configStore, err := config.NewStore(configFile, ctrl.Log.WithName("config"))
+	if err != nil {
+		setupLog.Error(err, "invalid configuration")
+		os.Exit(1)
+	}
+
+	namespaceFilter, err := controllers.NewNamespaceFilter(namespaces, namespaceSelector, shardCount, shardID)
+	if err != nil {
+		setupLog.Error(err, "invalid namespace filter")
+		os.Exit(1)
+	}
+	if namespaceFilter.Sharded() {
+		setupLog.Info("Running as a shard", "shard", namespaceFilter.ShardID, "shards", namespaceFilter.ShardCount)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
 		Metrics: server.Options{
 			BindAddress: metricsAddr,
+			// The effective configuration, for debugging
+			ExtraHandlers: map[string]http.Handler{"/debug/config": configStore},
 		},
 		WebhookServer: webhook.NewServer(webhook.Options{
 			Port: 9443,
 		}),
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "57a72bdf.kubeflow.org",
+		LeaderElectionID:       namespaceFilter.LeaderElectionID("57a72bdf.kubeflow.org"),
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -97,14 +129,21 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := mgr.Add(configStore); err != nil {
+		setupLog.Error(err, "unable to watch the configuration file")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.PVCViewerReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Config:          configStore,
+		NamespaceFilter: namespaceFilter,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PVCViewer")
 		os.Exit(1)
 	}
-	if err = (&kubefloworgv1alpha1.PVCViewer{}).SetupWebhookWithManager(mgr); err != nil {
+	if err = (&kubefloworgv1alpha1.PVCViewer{}).SetupWebhookWithManager(mgr, configStore); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "PVCViewer")
 		os.Exit(1)
 	}
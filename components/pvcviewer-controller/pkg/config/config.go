@@ -0,0 +1,126 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+const (
+	// APIVersion is the version of the configuration file format.
+	APIVersion = "pvcviewer.kubeflow.org/v1alpha1"
+	// Kind is the kind of the configuration file.
+	Kind = "PVCViewerControllerConfig"
+)
+
+// DefaultIstioGateway is used when neither the configuration file nor the
+// ISTIO_GATEWAY environment variable set the gateway.
+const DefaultIstioGateway = "kubeflow/kubeflow-gateway"
+
+// Config is the configuration of the pvcviewer controller.
+type Config struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	// IstioGateway is the gateway of the VirtualService created for every
+	// PVCViewer.
+	IstioGateway string `json:"istioGateway"`
+	// DefaultPodSpecPath is a YAML or JSON file with the podSpec of the
+	// PVCViewers created without one. A built-in filebrowser podSpec is used
+	// if empty.
+	DefaultPodSpecPath string `json:"defaultPodSpecPath"`
+}
+
+// FromEnv returns the configuration defined by the legacy environment
+// variables.
+func FromEnv() *Config {
+	c := &Config{
+		APIVersion:         APIVersion,
+		Kind:               Kind,
+		IstioGateway:       DefaultIstioGateway,
+		DefaultPodSpecPath: os.Getenv("DEFAULT_POD_SPEC_PATH"),
+	}
+	if gateway := os.Getenv("ISTIO_GATEWAY"); gateway != "" {
+		c.IstioGateway = gateway
+	}
+	return c
+}
+
+// Load reads the configuration file at path on top of the configuration
+// defined by the environment, and validates the result. If path is empty,
+// only the environment is used.
+func Load(path string) (*Config, error) {
+	c := FromEnv()
+	if path != "" {
+		if err := c.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readFile overrides the fields of c that are set in the file at path.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	c.APIVersion, c.Kind = "", ""
+	if err := sigsyaml.UnmarshalStrict(data, c); err != nil {
+		return fmt.Errorf("unable to parse %s: %v", path, err)
+	}
+	if c.APIVersion != APIVersion || c.Kind != Kind {
+		return fmt.Errorf("%s: expected apiVersion %s and kind %s, got %q and %q",
+			path, APIVersion, Kind, c.APIVersion, c.Kind)
+	}
+	return nil
+}
+
+// Validate returns an error if the configuration can't be used.
+func (c *Config) Validate() error {
+	if c.IstioGateway == "" {
+		return fmt.Errorf("istioGateway must not be empty")
+	}
+	if c.DefaultPodSpecPath != "" {
+		if _, err := LoadDefaultPodSpec(c.DefaultPodSpecPath); err != nil {
+			return fmt.Errorf("defaultPodSpecPath (or the DEFAULT_POD_SPEC_PATH env var): %v", err)
+		}
+	}
+	return nil
+}
+
+// LoadDefaultPodSpec reads the podSpec of the file at path.
+func LoadDefaultPodSpec(path string) (corev1.PodSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return corev1.PodSpec{}, err
+	}
+	var podSpec corev1.PodSpec
+	if err := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 100).Decode(&podSpec); err != nil {
+		return corev1.PodSpec{}, fmt.Errorf("unable to parse %s: %v", path, err)
+	}
+	return podSpec, nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	podSpecPath, _ := filepath.Abs("../../controllers/testdata/podspec_default.yaml")
+	invalidPodSpecPath := filepath.Join(t.TempDir(), "podspec.yaml")
+	if err := os.WriteFile(invalidPodSpecPath, []byte("containers: {"), 0644); err != nil {
+		t.Fatalf("Unable to write %s: %v", invalidPodSpecPath, err)
+	}
+
+	testCases := []struct {
+		testName string
+		content  string
+		env      map[string]string
+		valid    bool
+		check    func(c *Config) bool
+	}{
+		{
+			testName: "Defaults",
+			valid:    true,
+			check: func(c *Config) bool {
+				return c.IstioGateway == DefaultIstioGateway && c.DefaultPodSpecPath == ""
+			},
+		},
+		{
+			testName: "Environment only",
+			env:      map[string]string{"ISTIO_GATEWAY": "istio-system/gateway", "DEFAULT_POD_SPEC_PATH": podSpecPath},
+			valid:    true,
+			check: func(c *Config) bool {
+				return c.IstioGateway == "istio-system/gateway" && c.DefaultPodSpecPath == podSpecPath
+			},
+		},
+		{
+			testName: "Missing pod spec file",
+			env:      map[string]string{"DEFAULT_POD_SPEC_PATH": "/does/not/exist.yaml"},
+		},
+		{
+			testName: "File overrides the environment",
+			env:      map[string]string{"ISTIO_GATEWAY": "istio-system/gateway"},
+			content: `apiVersion: pvcviewer.kubeflow.org/v1alpha1
+kind: PVCViewerControllerConfig
+istioGateway: kubeflow/other-gateway
+defaultPodSpecPath: ` + podSpecPath + `
+`,
+			valid: true,
+			check: func(c *Config) bool {
+				return c.IstioGateway == "kubeflow/other-gateway" && c.DefaultPodSpecPath == podSpecPath
+			},
+		},
+		{
+			testName: "Invalid pod spec file",
+			content: `apiVersion: pvcviewer.kubeflow.org/v1alpha1
+kind: PVCViewerControllerConfig
+defaultPodSpecPath: ` + invalidPodSpecPath + `
+`,
+		},
+		{
+			testName: "Unknown field",
+			content: `apiVersion: pvcviewer.kubeflow.org/v1alpha1
+kind: PVCViewerControllerConfig
+gateway: kubeflow/other-gateway
+`,
+		},
+		{
+			testName: "Wrong kind",
+			content: `apiVersion: pvcviewer.kubeflow.org/v1alpha1
+kind: TensorboardControllerConfig
+`,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			for envVar, val := range c.env {
+				t.Setenv(envVar, val)
+			}
+			path := ""
+			if c.content != "" {
+				path = filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(c.content), 0644); err != nil {
+					t.Fatalf("Unable to write %s: %v", path, err)
+				}
+			}
+			cfg, err := Load(path)
+			if !c.valid {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", cfg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !c.check(cfg) {
+				t.Errorf("Unexpected configuration: %+v", cfg)
+			}
+		})
+	}
+}
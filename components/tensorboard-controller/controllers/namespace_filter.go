@@ -0,0 +1,160 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// NamespaceFilter restricts a controller instance to a subset of the
+// namespaces in the cluster. A namespace is managed when it is part of
+// Namespaces (if set), its labels match Selector (if set) and it hashes to
+// ShardID out of ShardCount. The zero value manages every namespace.
+//
+// The notebook, tensorboard and pvcviewer controllers have the same copy of
+// this file, since they don't share a module. Keep them identical.
+type NamespaceFilter struct {
+	Namespaces map[string]bool
+	Selector   labels.Selector
+	ShardCount int
+	ShardID    int
+}
+
+// NewNamespaceFilter builds a NamespaceFilter from its command line form.
+// namespaces is a comma separated list of namespace names and selector a
+// label selector matched against the labels of the Namespace object. A
+// negative shardID is derived from the ordinal suffix of the pod hostname, so
+// the replicas of a StatefulSet split the namespaces between them.
+func NewNamespaceFilter(namespaces, selector string, shardCount, shardID int) (*NamespaceFilter, error) {
+	f := &NamespaceFilter{ShardCount: shardCount, ShardID: shardID}
+
+	for _, ns := range strings.Split(namespaces, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			if f.Namespaces == nil {
+				f.Namespaces = map[string]bool{}
+			}
+			f.Namespaces[ns] = true
+		}
+	}
+
+	if selector != "" {
+		s, err := labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace selector %q: %v", selector, err)
+		}
+		f.Selector = s
+	}
+
+	if f.ShardCount < 1 {
+		return nil, fmt.Errorf("shard count must be at least 1, got %d", f.ShardCount)
+	}
+	if f.ShardCount > 1 && f.ShardID < 0 {
+		id, err := shardOrdinalFromHostname()
+		if err != nil {
+			return nil, err
+		}
+		f.ShardID = id
+	}
+	if f.ShardCount > 1 && f.ShardID >= f.ShardCount {
+		return nil, fmt.Errorf("shard id %d is out of range for %d shards", f.ShardID, f.ShardCount)
+	}
+	return f, nil
+}
+
+// shardOrdinalFromHostname returns the ordinal of a StatefulSet pod, which is
+// the number after the last dash of its hostname.
+func shardOrdinalFromHostname() (int, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return 0, err
+	}
+	idx := strings.LastIndex(hostname, "-")
+	id, err := strconv.Atoi(hostname[idx+1:])
+	if err != nil {
+		return 0, fmt.Errorf("cannot derive shard id from hostname %q", hostname)
+	}
+	return id, nil
+}
+
+// Sharded returns true if the namespaces are split between several shards.
+func (f *NamespaceFilter) Sharded() bool {
+	return f.ShardCount > 1
+}
+
+// LeaderElectionID returns the leader election lock to use for this instance.
+// Every shard elects its own leader, so replicas of different shards never
+// compete for the same lock.
+func (f *NamespaceFilter) LeaderElectionID(base string) string {
+	if !f.Sharded() {
+		return base
+	}
+	return fmt.Sprintf("%s-shard-%d", base, f.ShardID)
+}
+
+// shardFor returns the shard a namespace is assigned to.
+func (f *NamespaceFilter) shardFor(namespace string) int {
+	h := fnv.New32a()
+	h.Write([]byte(namespace))
+	return int(h.Sum32() % uint32(f.ShardCount))
+}
+
+// Manages returns true if the objects of the namespace should be reconciled
+// by this controller instance.
+func (f *NamespaceFilter) Manages(ctx context.Context, c client.Reader, namespace string) bool {
+	if f.Namespaces != nil && !f.Namespaces[namespace] {
+		return false
+	}
+	if f.Sharded() && f.shardFor(namespace) != f.ShardID {
+		return false
+	}
+	if f.Selector == nil || f.Selector.Empty() {
+		return true
+	}
+
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logf.Log.WithName("namespace-filter").Error(err, "unable to fetch Namespace", "namespace", namespace)
+		}
+		return false
+	}
+	return f.Selector.Matches(labels.Set(ns.Labels))
+}
+
+// Predicate filters out events for objects in namespaces that are not
+// managed by this controller instance. Events without an object, like
+// configuration changes, are let through.
+func (f *NamespaceFilter) Predicate(c client.Reader) predicate.Funcs {
+	return predicate.NewPredicateFuncs(func(object client.Object) bool {
+		if object == nil {
+			return true
+		}
+		return f.Manages(context.Background(), c, object.GetNamespace())
+	})
+}
@@ -19,7 +19,6 @@ package controllers
 import (
 	"context"
 	"fmt"
-	"os"
 	"reflect"
 	"strings"
 
@@ -35,16 +34,24 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	reconcilehelper "github.com/kubeflow/kubeflow/components/common/reconcilehelper"
 	tensorboardv1alpha1 "github.com/kubeflow/kubeflow/components/tensorboard-controller/api/v1alpha1"
+	"github.com/kubeflow/kubeflow/components/tensorboard-controller/pkg/config"
 )
 
 // TensorboardReconciler reconciles a Tensorboard object
 type TensorboardReconciler struct {
 	client.Client
 	Log logr.Logger
+	// Config holds the configuration of the controller, which can change
+	// while the controller is running.
+	Config *config.Store
+	// NamespaceFilter, if set, restricts the namespaces this instance manages.
+	NamespaceFilter *NamespaceFilter
 }
 
 //+kubebuilder:rbac:groups=tensorboard.kubeflow.org,resources=tensorboards,verbs=get;list;watch;create;update;patch;delete
@@ -55,6 +62,7 @@ type TensorboardReconciler struct {
 // +kubebuilder:rbac:groups=networking.istio.io,resources=virtualservices,verbs=get;list;watch;create;update
 // +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -88,8 +96,12 @@ func (r *TensorboardReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, nil
 	}
 
+	// Use the same configuration for the whole reconciliation, even if it
+	// is reloaded in the meantime.
+	cfg := r.Config.Get()
+
 	// Reconcile k8s deployment.
-	deployment, err := generateDeployment(instance, logger, r)
+	deployment, err := generateDeployment(instance, cfg, logger, r)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
@@ -110,7 +122,10 @@ func (r *TensorboardReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 
 	// Reconcile istio virtual service.
-	virtualService, err := generateVirtualService(instance)
+	virtualService, err := generateVirtualService(instance, cfg)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 	if err := ctrl.SetControllerReference(instance, virtualService, r.Scheme()); err != nil {
 		return ctrl.Result{}, err
 	}
@@ -158,21 +173,49 @@ func (r *TensorboardReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *TensorboardReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	// Map function to reconcile every Tensorboard when the configuration changes
+	mapConfigToRequests := func(object client.Object) []reconcile.Request {
+		return r.tensorboardRequests(mgr.GetClient())
+	}
+
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&tensorboardv1alpha1.Tensorboard{}).
 		Owns(&appsv1.Deployment{}).
-		Complete(r)
+		Watches(
+			&source.Channel{Source: r.Config.Subscribe()},
+			handler.EnqueueRequestsFromMapFunc(mapConfigToRequests))
+	if r.NamespaceFilter != nil {
+		builder.WithEventFilter(r.NamespaceFilter.Predicate(mgr.GetClient()))
+	}
+	return builder.Complete(r)
+}
+
+// tensorboardRequests returns a reconciliation request for every Tensorboard
+// managed by this controller instance.
+func (r *TensorboardReconciler) tensorboardRequests(c client.Client) []reconcile.Request {
+	tensorboards := &tensorboardv1alpha1.TensorboardList{}
+	if err := c.List(context.Background(), tensorboards); err != nil {
+		r.Log.Error(err, "unable to list Tensorboards")
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, tb := range tensorboards.Items {
+		if r.NamespaceFilter != nil && !r.NamespaceFilter.Manages(context.Background(), c, tb.Namespace) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: tb.Name, Namespace: tb.Namespace},
+		})
+	}
+	return requests
 }
 
-func generateDeployment(tb *tensorboardv1alpha1.Tensorboard, log logr.Logger, r *TensorboardReconciler) (*appsv1.Deployment, error) {
+func generateDeployment(tb *tensorboardv1alpha1.Tensorboard, cfg *config.Config, log logr.Logger, r *TensorboardReconciler) (*appsv1.Deployment, error) {
 	var volumeMounts []corev1.VolumeMount
 	var volumes []corev1.Volume
 	var mountpath, subpath string = tb.Spec.LogsPath, ""
 	var affinity = &corev1.Affinity{}
-	tensorboardImage, err := getEnvVariable("TENSORBOARD_IMAGE")
-	if err != nil {
-		return nil, err
-	}
 
 	//In this case, a PVC is used as a log storage for the Tensorboard server.
 	if !isCloudPath(tb.Spec.LogsPath) {
@@ -204,10 +247,8 @@ func generateDeployment(tb *tensorboardv1alpha1.Tensorboard, log logr.Logger, r
 			},
 		})
 
-		if err, sch := rwoPVCScheduling(); err != nil {
-			return nil, err
-		} else if sch {
-			//If 'RWO_PVC_SCHEDULING' env var is set to "true", an extra scheduling functionality is added,
+		if cfg.RWOPVCScheduling {
+			//If 'rwoPVCScheduling' is enabled, an extra scheduling functionality is added,
 			//for the case that the Tensorboard Server is using a RWO PVC (as a log storage)
 			//and the PVC is already mounted by another pod.
 
@@ -275,7 +316,7 @@ func generateDeployment(tb *tensorboardv1alpha1.Tensorboard, log logr.Logger, r
 					Containers: []corev1.Container{
 						{
 							Name:            "tensorboard",
-							Image:           tensorboardImage,
+							Image:           cfg.TensorboardImage,
 							ImagePullPolicy: "IfNotPresent",
 							Command:         []string{"/usr/local/bin/tensorboard"},
 							WorkingDir:      "/",
@@ -318,19 +359,12 @@ func generateService(tb *tensorboardv1alpha1.Tensorboard) *corev1.Service {
 	}
 }
 
-func generateVirtualService(tb *tensorboardv1alpha1.Tensorboard) (*unstructured.Unstructured, error) {
+func generateVirtualService(tb *tensorboardv1alpha1.Tensorboard, cfg *config.Config) (*unstructured.Unstructured, error) {
 	prefix := fmt.Sprintf("/tensorboard/%s/%s/", tb.Namespace, tb.Name)
 	rewrite := "/"
-	service := fmt.Sprintf("%s.%s.svc.cluster.local", tb.Name, tb.Namespace)
-	istioGateway, err := getEnvVariable("ISTIO_GATEWAY")
-	if err != nil {
-		return nil, err
-	}
-
-	istioHost, err := getEnvVariable("ISTIO_HOST")
-	if err != nil {
-		return nil, err
-	}
+	service := fmt.Sprintf("%s.%s.svc.%s", tb.Name, tb.Namespace, cfg.ClusterDomain)
+	istioGateway := cfg.Istio.Gateway
+	istioHost := cfg.Istio.Host
 
 	vsvc := &unstructured.Unstructured{}
 	vsvc.SetAPIVersion("networking.istio.io/v1alpha3")
@@ -470,21 +504,6 @@ func generateNodeAffinity(affinity *corev1.Affinity, pvcname string, r *Tensorbo
 	return nil
 }
 
-//Checks the value of 'RWO_PVC_SCHEDULING' env var (if present in the environment) and returns
-//'true' or 'false' accordingly. If 'RWO_PVC_SCHEDULING' is NOT present, then the value of the
-//returned boolean is set to 'false', so that the scheduling functionality is off by default.
-func rwoPVCScheduling() (error, bool) {
-	if value, exists := os.LookupEnv("RWO_PVC_SCHEDULING"); !exists || value == "false" || value == "False" || value == "FALSE" {
-		return nil, false
-	} else if value == "true" || value == "True" || value == "TRUE" {
-		return nil, true
-	}
-
-	//If 'RWO_PVC_SCHEDULING' is present in the environment but has an invalid value,
-	//then an error is returned.
-	return fmt.Errorf("Invalid value for 'RWO_PVC_SCEDULING' env var."), false
-}
-
 func Deployment(ctx context.Context, r client.Client, deployment *appsv1.Deployment, log logr.Logger) error {
 	foundDeployment := &appsv1.Deployment{}
 	justCreated := false
@@ -533,11 +552,3 @@ func CopyDeploymentSetFields(from, to *appsv1.Deployment) bool {
 
 	return requireUpdate
 }
-
-func getEnvVariable(envVar string) (string, error) {
-	if lookupEnv, exists := os.LookupEnv(envVar); exists {
-		return lookupEnv, nil
-	} else {
-		return "", fmt.Errorf("environment variable %v is not set", envVar)
-	}
-}
@@ -36,6 +36,7 @@ import (
 
 	tensorboardv1alpha1 "github.com/kubeflow/kubeflow/components/tensorboard-controller/api/v1alpha1"
 	"github.com/kubeflow/kubeflow/components/tensorboard-controller/controllers"
+	"github.com/kubeflow/kubeflow/components/tensorboard-controller/pkg/config"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -55,11 +56,24 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var namespaces, namespaceSelector string
+	var shardCount, shardID int
+	var configFile string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&namespaces, "namespaces", "",
+		"Comma separated list of namespaces to manage. If empty, all namespaces are managed.")
+	flag.StringVar(&namespaceSelector, "namespace-selector", "",
+		"Label selector for the namespaces to manage. If empty, all namespaces are managed.")
+	flag.IntVar(&shardCount, "shard-count", 1,
+		"Number of shards the managed namespaces are split into. Each shard runs its own leader election.")
+	flag.IntVar(&shardID, "shard-id", -1,
+		"Shard managed by this instance. If negative, it is taken from the ordinal of the pod hostname.")
+	flag.StringVar(&configFile, "config", "",
+		"Path of the configuration file, reloaded when it changes. If empty, the configuration is read from the environment.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -68,19 +82,43 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	configStore, err := config.NewStore(configFile, ctrl.Log.WithName("config"))
+	if err != nil {
+		setupLog.Error(err, "invalid configuration")
+		os.Exit(1)
+	}
+
+	namespaceFilter, err := controllers.NewNamespaceFilter(namespaces, namespaceSelector, shardCount, shardID)
+	if err != nil {
+		setupLog.Error(err, "invalid namespace filter")
+		os.Exit(1)
+	}
+	if namespaceFilter.Sharded() {
+		setupLog.Info("Running as a shard", "shard", namespaceFilter.ShardID, "shards", namespaceFilter.ShardCount)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "kubeflow-tensorboard-controller",
+		LeaderElectionID:       namespaceFilter.LeaderElectionID("kubeflow-tensorboard-controller"),
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	if err := mgr.Add(configStore); err != nil {
+		setupLog.Error(err, "unable to watch the configuration file")
+		os.Exit(1)
+	}
+	if err := mgr.AddMetricsExtraHandler("/debug/config", configStore); err != nil {
+		setupLog.Error(err, "unable to serve the configuration")
+		os.Exit(1)
+	}
+
 	cache := mgr.GetCache()
 
 	//This Indexer Function returns a list of the raw ClaimName values of
@@ -105,8 +143,10 @@ func main() {
 	}
 
 	if err = (&controllers.TensorboardReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Tensorboard"),
+		Client:          mgr.GetClient(),
+		Log:             ctrl.Log.WithName("controllers").WithName("Tensorboard"),
+		Config:          configStore,
+		NamespaceFilter: namespaceFilter,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Tensorboard")
 		os.Exit(1)
@@ -0,0 +1,136 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// APIVersion is the version of the configuration file format.
+	APIVersion = "tensorboard.kubeflow.org/v1alpha1"
+	// Kind is the kind of the configuration file.
+	Kind = "TensorboardControllerConfig"
+)
+
+// DefaultClusterDomain is used when neither the configuration file nor the
+// CLUSTER_DOMAIN environment variable set the cluster domain.
+const DefaultClusterDomain = "cluster.local"
+
+// Config is the configuration of the tensorboard controller.
+type Config struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	// TensorboardImage is the image of the Tensorboard servers.
+	TensorboardImage string `json:"tensorboardImage"`
+	// Istio configures the VirtualService created for every Tensorboard.
+	Istio IstioConfig `json:"istio"`
+	// ClusterDomain is the DNS domain of the cluster.
+	ClusterDomain string `json:"clusterDomain"`
+	// RWOPVCScheduling schedules a Tensorboard server that reads its logs
+	// from a ReadWriteOnce PVC on the node where the PVC is already mounted.
+	RWOPVCScheduling bool `json:"rwoPVCScheduling"`
+}
+
+// IstioConfig configures the Istio integration.
+type IstioConfig struct {
+	Gateway string `json:"gateway"`
+	Host    string `json:"host"`
+}
+
+// FromEnv returns the configuration defined by the legacy environment
+// variables.
+func FromEnv() (*Config, error) {
+	c := &Config{
+		APIVersion:       APIVersion,
+		Kind:             Kind,
+		TensorboardImage: os.Getenv("TENSORBOARD_IMAGE"),
+		Istio: IstioConfig{
+			Gateway: os.Getenv("ISTIO_GATEWAY"),
+			Host:    os.Getenv("ISTIO_HOST"),
+		},
+		ClusterDomain: DefaultClusterDomain,
+	}
+	if domain := os.Getenv("CLUSTER_DOMAIN"); domain != "" {
+		c.ClusterDomain = domain
+	}
+
+	switch value := os.Getenv("RWO_PVC_SCHEDULING"); value {
+	case "", "false", "False", "FALSE":
+		c.RWOPVCScheduling = false
+	case "true", "True", "TRUE":
+		c.RWOPVCScheduling = true
+	default:
+		return nil, fmt.Errorf("invalid value for 'RWO_PVC_SCHEDULING' env var: %q", value)
+	}
+	return c, nil
+}
+
+// Load reads the configuration file at path on top of the configuration
+// defined by the environment, and validates the result. If path is empty,
+// only the environment is used.
+func Load(path string) (*Config, error) {
+	c, err := FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		if err := c.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readFile overrides the fields of c that are set in the file at path.
+func (c *Config) readFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	c.APIVersion, c.Kind = "", ""
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return fmt.Errorf("unable to parse %s: %v", path, err)
+	}
+	if c.APIVersion != APIVersion || c.Kind != Kind {
+		return fmt.Errorf("%s: expected apiVersion %s and kind %s, got %q and %q",
+			path, APIVersion, Kind, c.APIVersion, c.Kind)
+	}
+	return nil
+}
+
+// Validate returns an error if the configuration can't be used.
+func (c *Config) Validate() error {
+	if c.TensorboardImage == "" {
+		return fmt.Errorf("tensorboardImage (or the TENSORBOARD_IMAGE env var) must be set")
+	}
+	if c.Istio.Gateway == "" || c.Istio.Host == "" {
+		return fmt.Errorf("istio.gateway and istio.host (or the ISTIO_GATEWAY and ISTIO_HOST env vars) must be set")
+	}
+	if c.ClusterDomain == "" {
+		return fmt.Errorf("clusterDomain must not be empty")
+	}
+	return nil
+}
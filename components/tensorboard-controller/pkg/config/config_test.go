@@ -0,0 +1,110 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	os.Setenv("TENSORBOARD_IMAGE", "tensorflow/tensorflow:2.5.1")
+	os.Setenv("ISTIO_GATEWAY", "kubeflow/kubeflow-gateway")
+	os.Setenv("ISTIO_HOST", "*")
+	defer os.Unsetenv("TENSORBOARD_IMAGE")
+	defer os.Unsetenv("ISTIO_GATEWAY")
+	defer os.Unsetenv("ISTIO_HOST")
+
+	testCases := []struct {
+		testName string
+		content  string
+		env      map[string]string
+		valid    bool
+		check    func(c *Config) bool
+	}{
+		{
+			testName: "Environment only",
+			env:      map[string]string{"RWO_PVC_SCHEDULING": "True"},
+			valid:    true,
+			check: func(c *Config) bool {
+				return c.TensorboardImage == "tensorflow/tensorflow:2.5.1" && c.RWOPVCScheduling &&
+					c.ClusterDomain == DefaultClusterDomain
+			},
+		},
+		{
+			testName: "Invalid RWO_PVC_SCHEDULING",
+			env:      map[string]string{"RWO_PVC_SCHEDULING": "yes"},
+		},
+		{
+			testName: "File overrides the environment",
+			content: `apiVersion: tensorboard.kubeflow.org/v1alpha1
+kind: TensorboardControllerConfig
+tensorboardImage: tensorflow/tensorflow:2.11.0
+clusterDomain: example.local
+`,
+			valid: true,
+			check: func(c *Config) bool {
+				return c.TensorboardImage == "tensorflow/tensorflow:2.11.0" &&
+					c.ClusterDomain == "example.local" && c.Istio.Host == "*"
+			},
+		},
+		{
+			testName: "Missing image",
+			content: `apiVersion: tensorboard.kubeflow.org/v1alpha1
+kind: TensorboardControllerConfig
+tensorboardImage: ""
+`,
+		},
+		{
+			testName: "Wrong apiVersion",
+			content: `apiVersion: notebooks.kubeflow.org/v1alpha1
+kind: TensorboardControllerConfig
+`,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			for envVar, val := range c.env {
+				os.Setenv(envVar, val)
+				defer os.Unsetenv(envVar)
+			}
+			path := ""
+			if c.content != "" {
+				path = filepath.Join(t.TempDir(), "config.yaml")
+				if err := ioutil.WriteFile(path, []byte(c.content), 0644); err != nil {
+					t.Fatalf("Unable to write %s: %v", path, err)
+				}
+			}
+			cfg, err := Load(path)
+			if !c.valid {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", cfg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !c.check(cfg) {
+				t.Errorf("Unexpected configuration: %+v", cfg)
+			}
+		})
+	}
+}